@@ -0,0 +1,107 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"strings"
+
+	"github.com/blockloop/scan/v2"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	"github.com/wisdom-oss/service-consumers/globals"
+)
+
+// apiKeyPrefix is prepended to every generated api key to allow recognizing
+// leaked keys (e.g., in repositories or logs)
+const apiKeyPrefix = "wck_"
+
+// apiKeyDisplayLength contains the number of characters of a key that are
+// stored in plain text to allow identifying the key
+const apiKeyDisplayLength = 12
+
+// ErrInvalidAPIKey is returned if the api key is unknown, revoked or expired
+var ErrInvalidAPIKey = errors.New("invalid api key")
+
+// apiKey contains the parts of a stored api key required for building the
+// principal of a request
+type apiKey struct {
+	ID     uuid.UUID      `db:"id"`
+	Tenant string         `db:"tenant"`
+	Scopes pq.StringArray `db:"scopes"`
+	Areas  pq.StringArray `db:"areas"`
+}
+
+// GenerateAPIKey generates a new random api key. Next to the key, the prefix
+// used for identifying the key and the hash stored in the database are
+// returned
+func GenerateAPIKey() (key string, prefix string, hash string, err error) {
+	secret := make([]byte, 32)
+	_, err = rand.Read(secret)
+	if err != nil {
+		return "", "", "", err
+	}
+	key = apiKeyPrefix + hex.EncodeToString(secret)
+	return key, key[:apiKeyDisplayLength], HashAPIKey(key), nil
+}
+
+// HashAPIKey hashes the api key for storing and looking it up. Since the keys
+// are random and long, a fast hash is sufficient
+func HashAPIKey(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(hash[:])
+}
+
+// apiKeyPrincipal looks up the api key and returns the principal the key has
+// been issued for. The principal acts for the tenant the key has been created
+// in. Using the key updates the point in time it has been used last
+func apiKeyPrincipal(ctx context.Context, beginSystemTx func(context.Context) (*sql.Tx, error), key string) (Principal, error) {
+	tx, err := beginSystemTx(ctx)
+	if err != nil {
+		return Principal{}, err
+	}
+	defer tx.Rollback()
+
+	rows, err := globals.SqlQueries.QueryContext(ctx, tx, "use-api-key", HashAPIKey(key))
+	if err != nil {
+		return Principal{}, err
+	}
+	var k apiKey
+	err = scan.Row(&k, rows)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Principal{}, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return Principal{}, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return Principal{}, err
+	}
+
+	// the keys are identified by their id since their names are not unique
+	principal := Principal{
+		User:   "api-key:" + k.ID.String(),
+		Tenant: k.Tenant,
+		Scopes: k.Scopes,
+	}
+	// keys are only restricted to areas if areas have been set for them
+	if k.Areas != nil && !principal.HasScope(ScopeAdmin) {
+		principal.Areas = []string(k.Areas)
+	}
+	return principal, nil
+}
+
+// apiKeyFromHeader extracts the api key from the authorization header. If
+// the header does not use the api key scheme, false is returned
+func apiKeyFromHeader(authorizationHeader string) (string, bool) {
+	scheme, key, found := strings.Cut(strings.TrimSpace(authorizationHeader), " ")
+	if !found || !strings.EqualFold(scheme, "ApiKey") {
+		return "", false
+	}
+	return strings.TrimSpace(key), true
+}
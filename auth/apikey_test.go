@@ -0,0 +1,69 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGenerateAPIKey(t *testing.T) {
+	key, prefix, hash, err := GenerateAPIKey()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(key, apiKeyPrefix) || len(key) != len(apiKeyPrefix)+64 {
+		t.Errorf("unexpected key format %q", key)
+	}
+	if prefix != key[:apiKeyDisplayLength] {
+		t.Errorf("expected prefix %q, got %q", key[:apiKeyDisplayLength], prefix)
+	}
+	if hash != HashAPIKey(key) {
+		t.Error("expected the hash of the key")
+	}
+
+	otherKey, _, otherHash, err := GenerateAPIKey()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if otherKey == key || otherHash == hash {
+		t.Error("expected distinct keys")
+	}
+}
+
+func TestHashAPIKey(t *testing.T) {
+	tests := []struct {
+		key      string
+		expected string
+	}{
+		{key: "", expected: "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"},
+		{key: "wck_key", expected: "7d024a05fc906ee8eff36beed7f2cf073fbc32a83b9dda2b05f8037697824501"},
+	}
+
+	for _, test := range tests {
+		hash := HashAPIKey(test.key)
+		if hash != test.expected {
+			t.Errorf("key %q: expected hash %s, got %s", test.key, test.expected, hash)
+		}
+	}
+}
+
+func TestAPIKeyFromHeader(t *testing.T) {
+	tests := []struct {
+		header   string
+		expected string
+		isAPIKey bool
+	}{
+		{header: "ApiKey wck_key", expected: "wck_key", isAPIKey: true},
+		{header: "apikey  wck_key ", expected: "wck_key", isAPIKey: true},
+		{header: "ApiKey ", isAPIKey: false},
+		{header: "Bearer token", isAPIKey: false},
+		{header: "ApiKey", isAPIKey: false},
+		{header: "", isAPIKey: false},
+	}
+
+	for _, test := range tests {
+		key, isAPIKey := apiKeyFromHeader(test.header)
+		if isAPIKey != test.isAPIKey || key != test.expected {
+			t.Errorf("header %q: expected (%q, %v), got (%q, %v)", test.header, test.expected, test.isAPIKey, key, isAPIKey)
+		}
+	}
+}
@@ -0,0 +1,271 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"golang.org/x/sync/singleflight"
+)
+
+// ErrUnknownKey is returned if the key set does not contain a key with the
+// requested id
+var ErrUnknownKey = errors.New("unknown signing key")
+
+// errUnsupportedKey is returned for keys which cannot be used for verifying
+// the signatures of tokens
+var errUnsupportedKey = errors.New("unsupported key")
+
+// minimalRefreshInterval contains the minimal time between two refreshes of
+// a key set that are caused by unknown key ids. It prevents tokens with
+// arbitrary key ids from causing a request to the key set location each
+const minimalRefreshInterval = time.Minute
+
+// initialFetchBackoff and maximalFetchBackoff limit the time the key set is
+// not read again after reading it failed. The backoff doubles with every
+// consecutive failure
+const (
+	initialFetchBackoff = 5 * time.Second
+	maximalFetchBackoff = 5 * time.Minute
+)
+
+// jsonWebKey contains the fields of a JSON Web Key that are used for
+// building the public keys
+type jsonWebKey struct {
+	KeyID     string `json:"kid"`
+	KeyType   string `json:"kty"`
+	Use       string `json:"use"`
+	Curve     string `json:"crv"`
+	Modulus   string `json:"n"`
+	Exponent  string `json:"e"`
+	X         string `json:"x"`
+	Y         string `json:"y"`
+	Algorithm string `json:"alg"`
+}
+
+// KeySet contains the public keys read from a JSON Web Key Set. The keys are
+// read from a file or an url and cached for the configured duration
+type KeySet struct {
+	// Location contains the path or the http(s) url of the key set
+	Location string
+
+	// CacheDuration contains the time after which the keys are read again
+	CacheDuration time.Duration
+
+	client    *http.Client
+	fetches   singleflight.Group
+	mutex     sync.Mutex
+	keys      map[string]crypto.PublicKey
+	fetchedAt time.Time
+	failures  int
+	retryAt   time.Time
+}
+
+// NewKeySet creates a key set which reads the keys from the supplied
+// location
+func NewKeySet(location string, cacheDuration time.Duration) *KeySet {
+	return &KeySet{
+		Location:      location,
+		CacheDuration: cacheDuration,
+		client:        &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Key returns the public key with the supplied id. The keys are read again if
+// the cache expired or if the key is unknown, since the key may have been
+// rotated. Concurrent requests share a single read of the key set and the key
+// set is not read again for a backoff period after reading it failed
+func (s *KeySet) Key(ctx context.Context, keyID string) (crypto.PublicKey, error) {
+	s.mutex.Lock()
+	expired := time.Since(s.fetchedAt) > s.CacheDuration
+	key, known := s.keys[keyID]
+	retryAt := s.retryAt
+	fetchedAt := s.fetchedAt
+	s.mutex.Unlock()
+
+	if known && !expired {
+		return key, nil
+	}
+	if !expired && time.Since(fetchedAt) < minimalRefreshInterval {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKey, keyID)
+	}
+	if time.Now().Before(retryAt) {
+		if known {
+			// keep using the cached key while the key set is unavailable
+			return key, nil
+		}
+		return nil, errors.New("unable to read key set: key set unavailable")
+	}
+
+	// the read is shared between the waiting requests and therefore must not
+	// be canceled together with the request starting it
+	_, err, _ := s.fetches.Do("keys", func() (interface{}, error) {
+		keys, err := s.fetch(context.WithoutCancel(ctx))
+
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		if err != nil {
+			s.failures++
+			backoff := initialFetchBackoff
+			for i := 1; i < s.failures && backoff < maximalFetchBackoff; i++ {
+				backoff *= 2
+			}
+			s.retryAt = time.Now().Add(min(backoff, maximalFetchBackoff))
+			return nil, err
+		}
+		s.keys = keys
+		s.fetchedAt = time.Now()
+		s.failures = 0
+		s.retryAt = time.Time{}
+		return nil, nil
+	})
+	if err != nil && known {
+		// keep using the cached key while the key set is unavailable
+		return key, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read key set: %w", err)
+	}
+
+	s.mutex.Lock()
+	key, known = s.keys[keyID]
+	s.mutex.Unlock()
+	if !known {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKey, keyID)
+	}
+	return key, nil
+}
+
+// fetch reads the key set from its location and parses the signing keys
+func (s *KeySet) fetch(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	var content []byte
+	var err error
+	if strings.HasPrefix(s.Location, "http://") || strings.HasPrefix(s.Location, "https://") {
+		var request *http.Request
+		request, err = http.NewRequestWithContext(ctx, http.MethodGet, s.Location, nil)
+		if err != nil {
+			return nil, err
+		}
+		var response *http.Response
+		response, err = s.client.Do(request)
+		if err != nil {
+			return nil, err
+		}
+		defer response.Body.Close()
+		if response.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("key set location responded with status %d", response.StatusCode)
+		}
+		content, err = io.ReadAll(response.Body)
+	} else {
+		content, err = os.ReadFile(s.Location)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var keySet struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	err = json.Unmarshal(content, &keySet)
+	if err != nil {
+		return nil, err
+	}
+
+	// keys which cannot be used for verifying tokens are skipped to keep
+	// using the remaining keys of the set
+	keys := make(map[string]crypto.PublicKey)
+	for _, jwk := range keySet.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		if jwk.Algorithm != "" && !slices.Contains(signingMethods, jwk.Algorithm) {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if errors.Is(err, errUnsupportedKey) {
+			continue
+		}
+		if err != nil {
+			log.Warn().Err(err).Str("keyID", jwk.KeyID).Msg("skipping invalid key of key set")
+			continue
+		}
+		keys[jwk.KeyID] = key
+	}
+	return keys, nil
+}
+
+// publicKey builds the public key described by the JSON Web Key
+func (k jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch k.KeyType {
+	case "RSA":
+		modulus, err := decodeBigInt(k.Modulus)
+		if err != nil {
+			return nil, err
+		}
+		exponent, err := decodeBigInt(k.Exponent)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: modulus, E: int(exponent.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Curve {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("%w: curve %s", errUnsupportedKey, k.Curve)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "OKP":
+		if k.Curve != "Ed25519" {
+			return nil, fmt.Errorf("%w: curve %s", errUnsupportedKey, k.Curve)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid ed25519 key size")
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("%w: key type %s", errUnsupportedKey, k.KeyType)
+	}
+}
+
+// decodeBigInt decodes a base64url encoded unsigned big-endian integer
+func decodeBigInt(value string) (*big.Int, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(decoded), nil
+}
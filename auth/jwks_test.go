@@ -0,0 +1,164 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestKeySetFetch(t *testing.T) {
+	keys := newTestKeys(t)
+	server := newKeySetServer(t, keys)
+
+	file := filepath.Join(t.TempDir(), "jwks.json")
+	err := os.WriteFile(file, keys.keySet(), 0o600)
+	if err != nil {
+		t.Fatalf("unable to write key set: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		location string
+	}{
+		{name: "key set url", location: server.URL},
+		{name: "key set file", location: file},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fetched, err := NewKeySet(test.location, time.Hour).fetch(context.Background())
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			// only the signing keys with supported types and algorithms are
+			// kept
+			if len(fetched) != 2 || fetched["rsa"] == nil || fetched["ec"] == nil {
+				t.Fatalf("expected the rsa and ec keys, got %v", fetched)
+			}
+		})
+	}
+}
+
+func TestJSONWebKeyPublicKey(t *testing.T) {
+	tests := []struct {
+		name        string
+		key         jsonWebKey
+		unsupported bool
+		invalid     bool
+	}{
+		{name: "rsa key", key: jsonWebKey{KeyType: "RSA", Modulus: "AQAB", Exponent: "AQAB"}},
+		{name: "rsa key with invalid modulus", key: jsonWebKey{KeyType: "RSA", Modulus: "!", Exponent: "AQAB"}, invalid: true},
+		{name: "ec key", key: jsonWebKey{KeyType: "EC", Curve: "P-384", X: "AQ", Y: "AQ"}},
+		{name: "ec key with unsupported curve", key: jsonWebKey{KeyType: "EC", Curve: "secp256k1", X: "AQ", Y: "AQ"}, unsupported: true},
+		{name: "ed25519 key", key: jsonWebKey{KeyType: "OKP", Curve: "Ed25519", X: "11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo"}},
+		{name: "ed25519 key with invalid size", key: jsonWebKey{KeyType: "OKP", Curve: "Ed25519", X: "AQ"}, invalid: true},
+		{name: "x25519 key", key: jsonWebKey{KeyType: "OKP", Curve: "X25519", X: "AQ"}, unsupported: true},
+		{name: "symmetric key", key: jsonWebKey{KeyType: "oct"}, unsupported: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			key, err := test.key.publicKey()
+			switch {
+			case test.unsupported:
+				if !errors.Is(err, errUnsupportedKey) {
+					t.Fatalf("expected errUnsupportedKey, got %v", err)
+				}
+			case test.invalid:
+				if err == nil || errors.Is(err, errUnsupportedKey) {
+					t.Fatalf("expected an invalid key error, got %v", err)
+				}
+			default:
+				if err != nil || key == nil {
+					t.Fatalf("expected a key, got %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestKeySetKeyBackoff(t *testing.T) {
+	keys := newTestKeys(t)
+	server := newKeySetServer(t, keys)
+	server.fail.Store(true)
+	keySet := NewKeySet(server.URL, time.Hour)
+
+	_, err := keySet.Key(context.Background(), "rsa")
+	if err == nil {
+		t.Fatal("expected an error while the key set is unavailable")
+	}
+	// the key set is not read again during the backoff
+	_, err = keySet.Key(context.Background(), "rsa")
+	if err == nil {
+		t.Fatal("expected an error during the backoff")
+	}
+	if requests := server.requests.Load(); requests != 1 {
+		t.Fatalf("expected a single request, got %d", requests)
+	}
+
+	// the key set is read again after the backoff
+	server.fail.Store(false)
+	keySet.mutex.Lock()
+	keySet.retryAt = time.Now()
+	keySet.mutex.Unlock()
+	key, err := keySet.Key(context.Background(), "rsa")
+	if err != nil || key == nil {
+		t.Fatalf("expected the key after the backoff, got %v", err)
+	}
+}
+
+func TestKeySetKeyCached(t *testing.T) {
+	keys := newTestKeys(t)
+	server := newKeySetServer(t, keys)
+	keySet := NewKeySet(server.URL, time.Hour)
+
+	_, err := keySet.Key(context.Background(), "rsa")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// unknown keys do not cause reads within the minimal refresh interval
+	_, err = keySet.Key(context.Background(), "unknown")
+	if !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("expected ErrUnknownKey, got %v", err)
+	}
+	if requests := server.requests.Load(); requests != 1 {
+		t.Fatalf("expected a single request, got %d", requests)
+	}
+
+	// the cached key is used if the key set is unavailable after the cache
+	// expired
+	server.fail.Store(true)
+	keySet.mutex.Lock()
+	keySet.fetchedAt = time.Now().Add(-2 * time.Hour)
+	keySet.mutex.Unlock()
+	key, err := keySet.Key(context.Background(), "rsa")
+	if err != nil || key == nil {
+		t.Fatalf("expected the cached key, got %v", err)
+	}
+}
+
+func TestKeySetKeyConcurrent(t *testing.T) {
+	// the key set is read outside the lock, which is checked by running the
+	// test with the race detector
+	keys := newTestKeys(t)
+	server := newKeySetServer(t, keys)
+	keySet := NewKeySet(server.URL, time.Hour)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := keySet.Key(context.Background(), "ec")
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+}
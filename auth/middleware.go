@@ -0,0 +1,216 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	wisdomType "github.com/wisdom-oss/commonTypes"
+	wisdomMiddleware "github.com/wisdom-oss/microservice-middlewares/v3"
+
+	"github.com/wisdom-oss/service-consumers/globals"
+)
+
+// missingScopeError extends the predefined error with the scope that is
+// required for the request
+type missingScopeError struct {
+	wisdomType.WISdoMError
+	Scope string `json:"missingScope"`
+}
+
+// Configuration contains the settings used for identifying the users and
+// resolving their scopes and areas
+type Configuration struct {
+	// Authorization contains the authorization configuration of the service
+	Authorization wisdomType.AuthorizationConfiguration
+
+	// GroupScopes maps the user groups to the scopes granted to them
+	GroupScopes map[string][]string
+
+	// GroupAreas maps the user groups to the areas they are restricted to
+	GroupAreas map[string][]string
+
+	// GroupTenants maps the user groups to the tenants they belong to
+	GroupTenants map[string]string
+
+	// TenantHeader contains the name of the header used for requesting a
+	// tenant. If it is empty, the tenant is only derived from the groups
+	TenantHeader string
+
+	// Tokens contains the validator used for the bearer tokens. If it is
+	// set, the user is identified using the bearer token instead of the
+	// headers set by the api gateway
+	Tokens *TokenValidator
+
+	// BeginSystemTx starts the transaction the api keys are looked up in.
+	// Since the tenant of a request is only known after looking up its key,
+	// the transaction needs to be bound to the system tenant
+	BeginSystemTx func(ctx context.Context) (*sql.Tx, error)
+}
+
+// Authenticate identifies the user sending the request and stores the
+// resulting principal in the request context. Requests using the api key
+// scheme are identified by the key and receive the scopes and areas of the
+// key. Otherwise, the user is either read from the headers set by the api
+// gateway or from the bearer token of the request if the token validation is
+// configured.
+// The scopes and areas of the principal are resolved using the mappings of
+// the groups. If the authorization is disabled, every scope is granted. Staff
+// members are granted every scope as well. Users with the admin scope are not
+// restricted to any areas.
+// Afterwards, the tenant the user acts for is resolved from the groups of the
+// user and the tenant requested using the tenant header
+func Authenticate(c Configuration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// machine clients authenticate using api keys which carry their
+			// scopes and areas themselves
+			if key, isAPIKey := apiKeyFromHeader(r.Header.Get("Authorization")); isAPIKey {
+				principal, err := apiKeyPrincipal(r.Context(), c.BeginSystemTx, key)
+				if errors.Is(err, ErrInvalidAPIKey) {
+					w.Header().Set("WWW-Authenticate", "ApiKey")
+					_ = globals.Errors["INVALID_API_KEY"].Send(w)
+					return
+				}
+				if err != nil {
+					log.Error().Err(err).Msg("unable to look up api key")
+					_ = globals.Errors["API_KEY_LOOKUP_FAILED"].Send(w)
+					return
+				}
+				if !c.Authorization.Enabled {
+					principal.Scopes = Scopes
+					principal.Areas = nil
+				}
+				next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), principal)))
+				return
+			}
+
+			var principal Principal
+			if c.Tokens != nil {
+				// since the token is validated, the headers of the api
+				// gateway are not trusted
+				rawToken, err := bearerToken(r.Header.Get("Authorization"))
+				if err == nil {
+					principal.User, principal.Groups, err = c.Tokens.Validate(r.Context(), rawToken)
+				}
+				if err != nil {
+					w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+					_ = globals.Errors["INVALID_TOKEN"].Send(w)
+					return
+				}
+			} else {
+				principal.User = strings.TrimSpace(r.Header.Get("X-WISdoM-User"))
+				for _, group := range strings.Split(r.Header.Get("X-WISdoM-Groups"), ",") {
+					if strings.TrimSpace(group) != "" {
+						principal.Groups = append(principal.Groups, strings.TrimSpace(group))
+					}
+				}
+				principal.Staff, _ = strconv.ParseBool(strings.TrimSpace(r.Header.Get("X-Is-Staff")))
+			}
+
+			switch {
+			case !c.Authorization.Enabled, principal.Staff:
+				principal.Scopes = Scopes
+			case c.Authorization.RequireUserIdentification && principal.User == "":
+				_ = wisdomMiddleware.ErrorMissingUserInformation.Send(w)
+				return
+			case len(principal.Groups) == 0:
+				_ = wisdomMiddleware.ErrorMissingGroupsInformation.Send(w)
+				return
+			default:
+				principal.Scopes = resolveScopes(principal.Groups, c.GroupScopes)
+				if !principal.HasScope(ScopeAdmin) {
+					principal.Areas = resolveAreas(principal.Groups, c.GroupAreas)
+				}
+			}
+
+			var requestedTenant string
+			if c.TenantHeader != "" {
+				requestedTenant = r.Header.Get(c.TenantHeader)
+			}
+			var err error
+			principal.Tenant, err = resolveTenant(principal, requestedTenant, c.GroupTenants)
+			if err != nil {
+				_ = globals.Errors[tenantErrors[err]].Send(w)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), principal)))
+		})
+	}
+}
+
+// tenantErrors maps the errors returned while resolving the tenant to the
+// codes of the errors sent to the user
+var tenantErrors = map[error]string{
+	ErrInvalidTenant:    "INVALID_TENANT",
+	ErrTenantNotAllowed: "TENANT_NOT_ALLOWED",
+	ErrMissingTenant:    "MISSING_TENANT",
+	ErrAmbiguousTenant:  "AMBIGUOUS_TENANT",
+}
+
+// resolveScopes collects the scopes granted to the supplied groups
+func resolveScopes(groups []string, groupScopes map[string][]string) []string {
+	var scopes []string
+	for _, group := range groups {
+		for _, scope := range groupScopes[group] {
+			if !slices.Contains(scopes, scope) {
+				scopes = append(scopes, scope)
+			}
+		}
+	}
+	return scopes
+}
+
+// resolveAreas collects the areas the supplied groups are restricted to. If
+// none of the groups is restricted, nil is returned
+func resolveAreas(groups []string, groupAreas map[string][]string) []string {
+	var areas []string
+	for _, group := range groups {
+		groupArea, restricted := groupAreas[group]
+		if !restricted {
+			continue
+		}
+		if areas == nil {
+			areas = []string{}
+		}
+		for _, area := range groupArea {
+			if !slices.Contains(areas, area) {
+				areas = append(areas, area)
+			}
+		}
+	}
+	return areas
+}
+
+// RequireScope rejects requests of principals that have not been granted all
+// of the supplied scopes. The error response names the first missing scope
+func RequireScope(scopes ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal := PrincipalFromContext(r.Context())
+			for _, scope := range scopes {
+				if principal.HasScope(scope) {
+					continue
+				}
+				e := missingScopeError{
+					WISdoMError: globals.Errors["MISSING_SCOPE"],
+					Scope:       scope,
+				}
+				e.ErrorDescription = fmt.Sprintf("%s: %s", e.ErrorDescription, scope)
+				w.Header().Set("Content-Type", "text/json; charset=utf-8")
+				w.WriteHeader(e.HttpStatusCode)
+				_ = json.NewEncoder(w).Encode(e)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package auth
+
+import (
+	"context"
+	"slices"
+)
+
+// principalKey is the key under which the principal is stored in the request
+// context
+type principalKey struct{}
+
+// Principal contains the identity of the user sending a request and the
+// scopes granted to the user
+type Principal struct {
+	// User contains the identifier of the user
+	User string
+
+	// Groups contains the groups the user is a member of
+	Groups []string
+
+	// Staff indicates if the user is a staff member
+	Staff bool
+
+	// Scopes contains the scopes granted to the user
+	Scopes []string
+
+	// Areas contains the keys of the shapes the user is restricted to. The
+	// user may only access consumers located inside these shapes. If no
+	// areas are set, the user is not restricted
+	Areas []string
+
+	// Tenant contains the tenant the user acts for. The user may only access
+	// the consumers and usages of this tenant
+	Tenant string
+}
+
+// Restricted checks if the principal may only access consumers inside its
+// areas
+func (p Principal) Restricted() bool {
+	return p.Areas != nil
+}
+
+// HasScope checks if the scope has been granted to the principal. The admin
+// scope grants every other scope
+func (p Principal) HasScope(scope string) bool {
+	return slices.Contains(p.Scopes, scope) || slices.Contains(p.Scopes, ScopeAdmin)
+}
+
+// WithPrincipal returns a copy of the context containing the principal
+func WithPrincipal(ctx context.Context, principal Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFromContext returns the principal stored in the context. If no
+// principal has been stored, an anonymous principal without scopes is
+// returned
+func PrincipalFromContext(ctx context.Context) Principal {
+	principal, _ := ctx.Value(principalKey{}).(Principal)
+	return principal
+}
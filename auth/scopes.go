@@ -0,0 +1,27 @@
+// Package auth identifies the user sending a request and checks the scopes
+// granted to the user. The scopes are derived from the groups of the user
+// using the mapping configured in the authorization configuration.
+package auth
+
+// The scopes that may be granted to a user group
+const (
+	// ScopeRead allows reading consumers, usages and their derived data
+	ScopeRead = "consumers:read"
+
+	// ScopeWrite allows creating and changing consumers and usages
+	ScopeWrite = "consumers:write"
+
+	// ScopeDelete allows deleting consumers and usages
+	ScopeDelete = "consumers:delete"
+
+	// ScopePII allows reading the personal data of consumers (their names,
+	// addresses, exact locations and sensitive properties)
+	ScopePII = "consumers:pii"
+
+	// ScopeAdmin grants every other scope and allows the administration of
+	// the service (e.g., the audit log and webhooks)
+	ScopeAdmin = "consumers:admin"
+)
+
+// Scopes contains all known scopes
+var Scopes = []string{ScopeRead, ScopeWrite, ScopeDelete, ScopePII, ScopeAdmin}
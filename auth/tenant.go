@@ -0,0 +1,76 @@
+package auth
+
+import (
+	"errors"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// DefaultTenant contains the tenant used if the deployment does not host
+// multiple tenants. The rows created before the introduction of tenants
+// belong to this tenant
+const DefaultTenant = "default"
+
+// tenantPattern contains the pattern valid tenant names need to match. The
+// pattern excludes the system tenant used by the background jobs
+var tenantPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,62}$`)
+
+var (
+	// ErrInvalidTenant is returned if the requested tenant is not a valid
+	// tenant name
+	ErrInvalidTenant = errors.New("invalid tenant")
+
+	// ErrTenantNotAllowed is returned if the user does not belong to the
+	// requested tenant
+	ErrTenantNotAllowed = errors.New("tenant not allowed")
+
+	// ErrMissingTenant is returned if the user does not belong to any tenant
+	ErrMissingTenant = errors.New("missing tenant")
+
+	// ErrAmbiguousTenant is returned if the user belongs to multiple tenants
+	// and did not request one of them
+	ErrAmbiguousTenant = errors.New("ambiguous tenant")
+)
+
+// ValidTenant checks if the supplied name is a valid tenant name
+func ValidTenant(tenant string) bool {
+	return tenantPattern.MatchString(tenant)
+}
+
+// resolveTenant determines the tenant the principal acts for. The tenants of
+// a user are derived from the groups of the user. If the user belongs to
+// multiple tenants, the tenant needs to be requested. Only staff members may
+// request any tenant. The admin scope is granted per tenant and does not allow
+// acting for other tenants.
+// If no groups are mapped to tenants, the deployment only hosts the default
+// tenant
+func resolveTenant(principal Principal, requestedTenant string, groupTenants map[string]string) (string, error) {
+	requestedTenant = strings.TrimSpace(requestedTenant)
+	if requestedTenant != "" && !ValidTenant(requestedTenant) {
+		return "", ErrInvalidTenant
+	}
+
+	var tenants []string
+	for _, group := range principal.Groups {
+		tenant, mapped := groupTenants[group]
+		if mapped && !slices.Contains(tenants, tenant) {
+			tenants = append(tenants, tenant)
+		}
+	}
+
+	switch {
+	case requestedTenant != "" && (principal.Staff || slices.Contains(tenants, requestedTenant)):
+		return requestedTenant, nil
+	case requestedTenant != "":
+		return "", ErrTenantNotAllowed
+	case len(tenants) == 1:
+		return tenants[0], nil
+	case len(tenants) > 1:
+		return "", ErrAmbiguousTenant
+	case len(groupTenants) == 0 || principal.Staff:
+		return DefaultTenant, nil
+	default:
+		return "", ErrMissingTenant
+	}
+}
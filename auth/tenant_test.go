@@ -0,0 +1,129 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidTenant(t *testing.T) {
+	tests := []struct {
+		tenant string
+		valid  bool
+	}{
+		{tenant: "default", valid: true},
+		{tenant: "utility-1", valid: true},
+		{tenant: "0_utility", valid: true},
+		{tenant: "", valid: false},
+		{tenant: "*", valid: false},
+		{tenant: "Utility", valid: false},
+		{tenant: "-utility", valid: false},
+		{tenant: "utility'; --", valid: false},
+	}
+
+	for _, test := range tests {
+		if ValidTenant(test.tenant) != test.valid {
+			t.Errorf("tenant %q: expected valid to be %v", test.tenant, test.valid)
+		}
+	}
+}
+
+func TestResolveTenant(t *testing.T) {
+	groupTenants := map[string]string{
+		"utility-a":          "a",
+		"utility-a-analysts": "a",
+		"utility-b":          "b",
+	}
+
+	tests := []struct {
+		name            string
+		principal       Principal
+		requestedTenant string
+		groupTenants    map[string]string
+		expected        string
+		err             error
+	}{
+		{
+			name:         "single tenant deployment",
+			principal:    Principal{Groups: []string{"users"}},
+			groupTenants: map[string]string{},
+			expected:     DefaultTenant,
+		},
+		{
+			name:         "tenant of the groups",
+			principal:    Principal{Groups: []string{"utility-a", "utility-a-analysts"}},
+			groupTenants: groupTenants,
+			expected:     "a",
+		},
+		{
+			name:            "requested tenant of the groups",
+			principal:       Principal{Groups: []string{"utility-a", "utility-b"}},
+			requestedTenant: " b ",
+			groupTenants:    groupTenants,
+			expected:        "b",
+		},
+		{
+			name:         "multiple tenants without request",
+			principal:    Principal{Groups: []string{"utility-a", "utility-b"}},
+			groupTenants: groupTenants,
+			err:          ErrAmbiguousTenant,
+		},
+		{
+			name:            "requested foreign tenant",
+			principal:       Principal{Groups: []string{"utility-a"}},
+			requestedTenant: "b",
+			groupTenants:    groupTenants,
+			err:             ErrTenantNotAllowed,
+		},
+		{
+			name:            "requested invalid tenant",
+			principal:       Principal{Groups: []string{"utility-a"}},
+			requestedTenant: "*",
+			groupTenants:    groupTenants,
+			err:             ErrInvalidTenant,
+		},
+		{
+			name:         "user without tenant",
+			principal:    Principal{Groups: []string{"users"}},
+			groupTenants: groupTenants,
+			err:          ErrMissingTenant,
+		},
+		{
+			name:            "staff member requesting any tenant",
+			principal:       Principal{Staff: true},
+			requestedTenant: "c",
+			groupTenants:    groupTenants,
+			expected:        "c",
+		},
+		{
+			name:         "staff member without tenant",
+			principal:    Principal{Staff: true},
+			groupTenants: groupTenants,
+			expected:     DefaultTenant,
+		},
+		{
+			name:            "admin requesting a foreign tenant",
+			principal:       Principal{Groups: []string{"utility-a"}, Scopes: []string{ScopeAdmin}},
+			requestedTenant: "b",
+			groupTenants:    groupTenants,
+			err:             ErrTenantNotAllowed,
+		},
+		{
+			name:         "admin without tenant",
+			principal:    Principal{Groups: []string{"users"}, Scopes: []string{ScopeAdmin}},
+			groupTenants: groupTenants,
+			err:          ErrMissingTenant,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tenant, err := resolveTenant(test.principal, test.requestedTenant, test.groupTenants)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("expected error %v, got %v", test.err, err)
+			}
+			if tenant != test.expected {
+				t.Fatalf("expected tenant %q, got %q", test.expected, tenant)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ErrMissingToken is returned if the request does not contain a bearer token
+var ErrMissingToken = errors.New("missing bearer token")
+
+// tokenLeeway contains the tolerated clock skew while validating the time
+// based claims of a token
+const tokenLeeway = 30 * time.Second
+
+// signingMethods contains the asymmetric signing methods accepted for tokens
+var signingMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}
+
+// TokenValidator validates the bearer tokens sent with the requests and
+// reads the identity of the user from their claims
+type TokenValidator struct {
+	// Keys contains the key set used for verifying the signatures
+	Keys *KeySet
+
+	// Issuer contains the required issuer of the tokens
+	Issuer string
+
+	// Audience contains the required audience of the tokens
+	Audience string
+
+	// UserClaim contains the name of the claim identifying the user
+	UserClaim string
+
+	// GroupsClaim contains the path of the claim containing the groups of the
+	// user. Nested claims are separated by dots (e.g., "realm_access.roles")
+	GroupsClaim string
+}
+
+// TokenValidatorFromEnvironment reads the configuration of the token
+// validation from the supplied environment. If no key set is configured, the
+// validation is disabled and nil is returned
+func TokenValidatorFromEnvironment(environment map[string]string) (*TokenValidator, error) {
+	location := strings.TrimSpace(environment["JWT_JWKS_LOCATION"])
+	if location == "" {
+		return nil, nil
+	}
+
+	cacheDuration, err := time.ParseDuration(environment["JWT_JWKS_CACHE_DURATION"])
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse key set cache duration: %w", err)
+	}
+
+	v := TokenValidator{
+		Keys:        NewKeySet(location, cacheDuration),
+		Issuer:      strings.TrimSpace(environment["JWT_ISSUER"]),
+		Audience:    strings.TrimSpace(environment["JWT_AUDIENCE"]),
+		UserClaim:   strings.TrimSpace(environment["JWT_USER_CLAIM"]),
+		GroupsClaim: strings.TrimSpace(environment["JWT_GROUPS_CLAIM"]),
+	}
+	if v.Issuer == "" || v.Audience == "" {
+		return nil, errors.New("the issuer and the audience are required for validating tokens")
+	}
+	return &v, nil
+}
+
+// Validate checks the signature, the issuer, the audience and the expiry of
+// the token and returns the user and the groups contained in its claims
+func (v *TokenValidator) Validate(ctx context.Context, rawToken string) (user string, groups []string, err error) {
+	claims := jwt.MapClaims{}
+	_, err = jwt.ParseWithClaims(rawToken, claims, func(token *jwt.Token) (interface{}, error) {
+		keyID, _ := token.Header["kid"].(string)
+		return v.Keys.Key(ctx, keyID)
+	},
+		jwt.WithValidMethods(signingMethods),
+		jwt.WithIssuer(v.Issuer),
+		jwt.WithAudience(v.Audience),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(tokenLeeway),
+	)
+	if err != nil {
+		return "", nil, err
+	}
+
+	user, _ = claims[v.UserClaim].(string)
+	return user, claimValues(claims, v.GroupsClaim), nil
+}
+
+// claimValues resolves the claim at the supplied path and returns its values.
+// Claims may either contain a list of strings or a single string with values
+// separated by spaces or commas
+func claimValues(claims jwt.MapClaims, path string) []string {
+	var claim interface{} = map[string]interface{}(claims)
+	for _, segment := range strings.Split(path, ".") {
+		object, isObject := claim.(map[string]interface{})
+		if !isObject {
+			return nil
+		}
+		claim = object[segment]
+	}
+
+	var values []string
+	switch c := claim.(type) {
+	case string:
+		values = strings.FieldsFunc(c, func(r rune) bool { return r == ' ' || r == ',' })
+	case []interface{}:
+		for _, value := range c {
+			if value, isString := value.(string); isString && value != "" {
+				values = append(values, value)
+			}
+		}
+	}
+	return values
+}
+
+// bearerToken extracts the token from the authorization header
+func bearerToken(authorizationHeader string) (string, error) {
+	scheme, token, found := strings.Cut(strings.TrimSpace(authorizationHeader), " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" {
+		return "", ErrMissingToken
+	}
+	return strings.TrimSpace(token), nil
+}
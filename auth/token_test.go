@@ -0,0 +1,221 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	testIssuer   = "https://idp.example.com"
+	testAudience = "consumers"
+)
+
+// testKeys contains the private keys the tokens in the tests are signed with
+type testKeys struct {
+	rsa *rsa.PrivateKey
+	ec  *ecdsa.PrivateKey
+}
+
+func newTestKeys(t *testing.T) testKeys {
+	t.Helper()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("unable to generate rsa key: %v", err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("unable to generate ec key: %v", err)
+	}
+	return testKeys{rsa: rsaKey, ec: ecKey}
+}
+
+func encodeBigInt(value *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(value.Bytes())
+}
+
+// keySet returns the public keys as JSON Web Key Set. Next to the usable keys,
+// the set contains keys the service needs to skip
+func (k testKeys) keySet() []byte {
+	keySet := map[string][]map[string]string{
+		"keys": {
+			{"kid": "rsa", "kty": "RSA", "use": "sig", "alg": "RS256", "n": encodeBigInt(k.rsa.N), "e": encodeBigInt(big.NewInt(int64(k.rsa.E)))},
+			{"kid": "ec", "kty": "EC", "crv": "P-256", "x": encodeBigInt(k.ec.X), "y": encodeBigInt(k.ec.Y)},
+			{"kid": "symmetric", "kty": "oct", "k": "c2VjcmV0"},
+			{"kid": "hmac", "kty": "RSA", "alg": "HS256", "n": encodeBigInt(k.rsa.N), "e": "AQAB"},
+			{"kid": "encryption", "kty": "RSA", "use": "enc", "n": encodeBigInt(k.rsa.N), "e": "AQAB"},
+			{"kid": "secp256k1", "kty": "EC", "crv": "secp256k1", "x": "AA", "y": "AA"},
+		},
+	}
+	content, _ := json.Marshal(keySet)
+	return content
+}
+
+// keySetServer serves the key set and counts the requests. If fail is set,
+// the server responds with an error
+type keySetServer struct {
+	*httptest.Server
+	requests atomic.Int32
+	fail     atomic.Bool
+}
+
+func newKeySetServer(t *testing.T, keys testKeys) *keySetServer {
+	t.Helper()
+	server := &keySetServer{}
+	server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		server.requests.Add(1)
+		if server.fail.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write(keys.keySet())
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func signToken(t *testing.T, method jwt.SigningMethod, keyID string, key interface{}, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = keyID
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("unable to sign token: %v", err)
+	}
+	return signed
+}
+
+func validClaims() jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss":    testIssuer,
+		"aud":    testAudience,
+		"sub":    "user",
+		"exp":    time.Now().Add(time.Hour).Unix(),
+		"groups": []string{"utility", "analysts"},
+	}
+}
+
+func withClaim(claims jwt.MapClaims, name string, value interface{}) jwt.MapClaims {
+	claims[name] = value
+	return claims
+}
+
+func TestTokenValidatorValidate(t *testing.T) {
+	keys := newTestKeys(t)
+	server := newKeySetServer(t, keys)
+	validator := &TokenValidator{
+		Keys:        NewKeySet(server.URL, time.Hour),
+		Issuer:      testIssuer,
+		Audience:    testAudience,
+		UserClaim:   "sub",
+		GroupsClaim: "groups",
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		valid bool
+	}{
+		{name: "rsa signed token", token: signToken(t, jwt.SigningMethodRS256, "rsa", keys.rsa, validClaims()), valid: true},
+		{name: "ec signed token", token: signToken(t, jwt.SigningMethodES256, "ec", keys.ec, validClaims()), valid: true},
+		{name: "token expired within the leeway", token: signToken(t, jwt.SigningMethodRS256, "rsa", keys.rsa, withClaim(validClaims(), "exp", time.Now().Add(-tokenLeeway/2).Unix())), valid: true},
+		{name: "expired token", token: signToken(t, jwt.SigningMethodRS256, "rsa", keys.rsa, withClaim(validClaims(), "exp", time.Now().Add(-time.Hour).Unix()))},
+		{name: "token without expiry", token: signToken(t, jwt.SigningMethodRS256, "rsa", keys.rsa, withClaim(validClaims(), "exp", nil))},
+		{name: "foreign issuer", token: signToken(t, jwt.SigningMethodRS256, "rsa", keys.rsa, withClaim(validClaims(), "iss", "https://other.example.com"))},
+		{name: "foreign audience", token: signToken(t, jwt.SigningMethodRS256, "rsa", keys.rsa, withClaim(validClaims(), "aud", "other"))},
+		{name: "unknown key", token: signToken(t, jwt.SigningMethodRS256, "unknown", keys.rsa, validClaims())},
+		{name: "key of another type", token: signToken(t, jwt.SigningMethodRS256, "ec", keys.rsa, validClaims())},
+		{name: "symmetric signature", token: signToken(t, jwt.SigningMethodHS256, "symmetric", []byte("secret"), validClaims())},
+		{name: "skipped key", token: signToken(t, jwt.SigningMethodRS256, "encryption", keys.rsa, validClaims())},
+		{name: "malformed token", token: "not-a-token"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			user, groups, err := validator.Validate(context.Background(), test.token)
+			if !test.valid {
+				if err == nil {
+					t.Fatal("expected the token to be rejected")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if user != "user" {
+				t.Errorf("expected user %q, got %q", "user", user)
+			}
+			if !slices.Equal(groups, []string{"utility", "analysts"}) {
+				t.Errorf("unexpected groups %v", groups)
+			}
+		})
+	}
+}
+
+func TestClaimValues(t *testing.T) {
+	claims := jwt.MapClaims{
+		"groups":       []interface{}{"a", "", "b", 1},
+		"scope":        "a b,c",
+		"realm_access": map[string]interface{}{"roles": []interface{}{"admin"}},
+		"number":       5,
+	}
+
+	tests := []struct {
+		path     string
+		expected []string
+	}{
+		{path: "groups", expected: []string{"a", "b"}},
+		{path: "scope", expected: []string{"a", "b", "c"}},
+		{path: "realm_access.roles", expected: []string{"admin"}},
+		{path: "realm_access.missing", expected: nil},
+		{path: "scope.nested", expected: nil},
+		{path: "number", expected: nil},
+		{path: "missing", expected: nil},
+	}
+
+	for _, test := range tests {
+		values := claimValues(claims, test.path)
+		if !slices.Equal(values, test.expected) {
+			t.Errorf("path %q: expected %v, got %v", test.path, test.expected, values)
+		}
+	}
+}
+
+func TestBearerToken(t *testing.T) {
+	tests := []struct {
+		header   string
+		expected string
+		err      error
+	}{
+		{header: "Bearer token", expected: "token"},
+		{header: "bearer  token ", expected: "token"},
+		{header: "Bearer", err: ErrMissingToken},
+		{header: "Bearer  ", err: ErrMissingToken},
+		{header: "Basic dXNlcjpwYXNz", err: ErrMissingToken},
+		{header: "", err: ErrMissingToken},
+	}
+
+	for _, test := range tests {
+		token, err := bearerToken(test.header)
+		if !errors.Is(err, test.err) {
+			t.Errorf("header %q: expected error %v, got %v", test.header, test.err, err)
+		}
+		if token != test.expected {
+			t.Errorf("header %q: expected token %q, got %q", test.header, test.expected, token)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package broker
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryPublisher keeps the published messages in memory. Messages with an
+// id that already has been published are discarded to mirror the
+// deduplication of the real brokers
+type MemoryPublisher struct {
+	mutex    sync.Mutex
+	messages []Message
+	ids      map[string]struct{}
+}
+
+// NewMemoryPublisher creates an empty in-memory publisher
+func NewMemoryPublisher() *MemoryPublisher {
+	return &MemoryPublisher{ids: make(map[string]struct{})}
+}
+
+// Publish stores the message unless a message with the same id has been
+// published before
+func (p *MemoryPublisher) Publish(ctx context.Context, message Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if _, published := p.ids[message.ID]; published {
+		return nil
+	}
+	p.ids[message.ID] = struct{}{}
+	p.messages = append(p.messages, message)
+	return nil
+}
+
+// Messages returns a copy of the messages published so far
+func (p *MemoryPublisher) Messages() []Message {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return append([]Message(nil), p.messages...)
+}
+
+// Close does nothing since the publisher holds no connection
+func (p *MemoryPublisher) Close() error {
+	return nil
+}
@@ -0,0 +1,98 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestMemoryPublisher(t *testing.T) {
+	tests := []struct {
+		name     string
+		messages []Message
+		expected []string
+	}{
+		{
+			name:     "no messages",
+			messages: nil,
+			expected: nil,
+		},
+		{
+			name: "distinct messages keep their order",
+			messages: []Message{
+				{ID: "1", Subject: "consumers.consumer.created"},
+				{ID: "2", Subject: "consumers.consumer.updated"},
+				{ID: "3", Subject: "consumers.consumer.deleted"},
+			},
+			expected: []string{"1", "2", "3"},
+		},
+		{
+			name: "repeated messages are discarded",
+			messages: []Message{
+				{ID: "1", Subject: "consumers.consumer.created"},
+				{ID: "2", Subject: "consumers.consumer.updated"},
+				{ID: "1", Subject: "consumers.consumer.created"},
+			},
+			expected: []string{"1", "2"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			publisher := NewMemoryPublisher()
+			for _, message := range test.messages {
+				err := publisher.Publish(context.Background(), message)
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+
+			messages := publisher.Messages()
+			if len(messages) != len(test.expected) {
+				t.Fatalf("expected %d messages, got %d", len(test.expected), len(messages))
+			}
+			for i, message := range messages {
+				if message.ID != test.expected[i] {
+					t.Errorf("expected message %d to have id %q, got %q", i, test.expected[i], message.ID)
+				}
+			}
+		})
+	}
+}
+
+func TestMemoryPublisherCanceledContext(t *testing.T) {
+	publisher := NewMemoryPublisher()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := publisher.Publish(ctx, Message{ID: "1"})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if len(publisher.Messages()) != 0 {
+		t.Fatal("expected no published messages")
+	}
+}
+
+func TestNewPublisher(t *testing.T) {
+	tests := []struct {
+		name     string
+		url      string
+		expected error
+	}{
+		{name: "memory broker", url: "memory://", expected: nil},
+		{name: "unsupported scheme", url: "amqp://localhost", expected: ErrUnsupportedBroker},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			publisher, err := NewPublisher(test.url)
+			if !errors.Is(err, test.expected) {
+				t.Fatalf("expected error %v, got %v", test.expected, err)
+			}
+			if err == nil {
+				_ = publisher.Close()
+			}
+		})
+	}
+}
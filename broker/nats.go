@@ -0,0 +1,41 @@
+package broker
+
+import (
+	"context"
+
+	"github.com/nats-io/nats.go"
+)
+
+// NATSPublisher publishes the messages to NATS JetStream. The id of each
+// message is sent in the Nats-Msg-Id header which lets the stream discard
+// messages that are published multiple times within its duplicate window
+type NATSPublisher struct {
+	connection *nats.Conn
+	jetStream  nats.JetStreamContext
+}
+
+// NewNATSPublisher connects to the NATS server using the supplied url. The
+// subjects the messages are published on need to be bound to a stream
+func NewNATSPublisher(url string) (*NATSPublisher, error) {
+	connection, err := nats.Connect(url, nats.Name("wisdom-service-consumers"), nats.MaxReconnects(-1))
+	if err != nil {
+		return nil, err
+	}
+	jetStream, err := connection.JetStream()
+	if err != nil {
+		connection.Close()
+		return nil, err
+	}
+	return &NATSPublisher{connection: connection, jetStream: jetStream}, nil
+}
+
+// Publish sends the message to the stream and waits for the acknowledgement
+func (p *NATSPublisher) Publish(ctx context.Context, message Message) error {
+	_, err := p.jetStream.Publish(message.Subject, message.Data, nats.MsgId(message.ID), nats.Context(ctx))
+	return err
+}
+
+// Close drains the connection to the NATS server
+func (p *NATSPublisher) Close() error {
+	return p.connection.Drain()
+}
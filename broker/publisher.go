@@ -0,0 +1,58 @@
+// Package broker publishes the events written to the outbox to a message
+// broker. The broker is selected using the scheme of the configured url which
+// allows replacing the broker without changing the relay of the events.
+package broker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+)
+
+// ErrUnsupportedBroker is returned if the scheme of the broker url does not
+// match a supported broker
+var ErrUnsupportedBroker = errors.New("unsupported message broker")
+
+// Message contains a single message sent to the broker
+type Message struct {
+	// ID contains the idempotency key of the message. Since messages are
+	// delivered at least once, receivers use the key to detect messages they
+	// already processed
+	ID string
+
+	// Subject contains the subject the message is published on
+	Subject string
+
+	// Data contains the body of the message
+	Data []byte
+}
+
+// Publisher is implemented by the supported message brokers
+type Publisher interface {
+	// Publish sends the message to the broker and returns after the broker
+	// acknowledged the message
+	Publish(ctx context.Context, message Message) error
+
+	// Close closes the connection to the broker
+	Close() error
+}
+
+// NewPublisher connects to the broker identified by the supplied url.
+// The following schemes are supported:
+//   - nats, tls: publishes the messages to a NATS JetStream stream
+//   - memory: keeps the messages in memory. Only intended for tests
+func NewPublisher(brokerURL string) (Publisher, error) {
+	parsedURL, err := url.Parse(brokerURL)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse message broker url: %w", err)
+	}
+	switch parsedURL.Scheme {
+	case "nats", "tls":
+		return NewNATSPublisher(brokerURL)
+	case "memory":
+		return NewMemoryPublisher(), nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedBroker, parsedURL.Scheme)
+	}
+}
@@ -1,10 +1,13 @@
 package main
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
@@ -13,8 +16,15 @@ import (
 	"github.com/rs/zerolog/log"
 	wisdomMiddleware "github.com/wisdom-oss/microservice-middlewares/v3"
 
+	"github.com/wisdom-oss/service-consumers/auth"
+	"github.com/wisdom-oss/service-consumers/events"
 	"github.com/wisdom-oss/service-consumers/globals"
+	"github.com/wisdom-oss/service-consumers/jobs"
+	"github.com/wisdom-oss/service-consumers/limits"
+	"github.com/wisdom-oss/service-consumers/metrics"
 	"github.com/wisdom-oss/service-consumers/routes"
+	"github.com/wisdom-oss/service-consumers/tenancy"
+	"github.com/wisdom-oss/service-consumers/tracing"
 )
 
 // the main function bootstraps the http server and handlers used for this
@@ -24,19 +34,187 @@ func main() {
 	l := log.With().Str("step", "main-service").Logger()
 	l.Info().Msgf("starting %s service", globals.ServiceName)
 
+	// create a context which is canceled when the service shuts down to stop
+	// the background jobs
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// now configure the tracing of the requests, the database statements and
+	// the serialization of the responses
+	shutdownTracing, err := tracing.Configure(ctx, globals.Environment, globals.ServiceName)
+	if err != nil {
+		l.Fatal().Err(err).Msg("unable to configure tracing")
+	}
+
+	// now start the anomaly detection in the background
+	anomalyDetection, err := jobs.AnomalyDetectionFromEnvironment(globals.Environment)
+	if err != nil {
+		l.Fatal().Err(err).Msg("unable to configure anomaly detection")
+	}
+	go anomalyDetection.Run(ctx)
+
+	// now start delivering the events written to the outbox to the webhooks
+	webhookDelivery, err := jobs.WebhookDeliveryFromEnvironment(globals.Environment)
+	if err != nil {
+		l.Fatal().Err(err).Msg("unable to configure webhook delivery")
+	}
+	go webhookDelivery.Run(ctx)
+
+	// now start publishing the events written to the outbox to the message
+	// broker
+	outboxRelay, err := jobs.OutboxRelayFromEnvironment(globals.Environment)
+	if err != nil {
+		l.Fatal().Err(err).Msg("unable to configure outbox relay")
+	}
+	go outboxRelay.Run(ctx)
+
+	// now start listening for the changes of consumers to publish them to
+	// the subscribed clients
+	consumerEvents := events.NewHub(databaseDSN)
+	go consumerEvents.Run(ctx)
+
+	// now configure the duplicate detection used while creating consumers
+	err = routes.ConfigureDuplicateDetection(globals.Environment)
+	if err != nil {
+		l.Fatal().Err(err).Msg("unable to configure duplicate detection")
+	}
+
+	// now configure the redaction of personal data for users without the
+	// pii scope
+	err = routes.ConfigureRedaction(globals.Environment)
+	if err != nil {
+		l.Fatal().Err(err).Msg("unable to configure redaction")
+	}
+
+	// now configure the validation of bearer tokens which replaces the user
+	// information set by the api gateway if enabled
+	tokenValidator, err := auth.TokenValidatorFromEnvironment(globals.Environment)
+	if err != nil {
+		l.Fatal().Err(err).Msg("unable to configure token validation")
+	}
+	if tokenValidator != nil {
+		l.Info().Str("issuer", tokenValidator.Issuer).Msg("validating bearer tokens")
+	}
+
+	// now configure the limits protecting the service from misbehaving
+	// clients
+	addressRateLimiter, err := limits.AddressRateLimiterFromEnvironment(globals.Environment)
+	if err != nil {
+		l.Fatal().Err(err).Msg("unable to configure rate limiting")
+	}
+	rateLimiter, err := limits.RateLimiterFromEnvironment(globals.Environment)
+	if err != nil {
+		l.Fatal().Err(err).Msg("unable to configure rate limiting")
+	}
+	trustedProxies, err := limits.TrustedProxiesFromEnvironment(globals.Environment)
+	if err != nil {
+		l.Fatal().Err(err).Msg("unable to configure trusted proxies")
+	}
+	maxBodySize, err := limits.BodySizeFromEnvironment(globals.Environment, "MAX_BODY_SIZE")
+	if err != nil {
+		l.Fatal().Err(err).Msg("unable to configure body size limit")
+	}
+	maxImportBodySize, err := limits.BodySizeFromEnvironment(globals.Environment, "MAX_IMPORT_BODY_SIZE")
+	if err != nil {
+		l.Fatal().Err(err).Msg("unable to configure body size limit")
+	}
+
 	// create a new router
 	router := chi.NewRouter()
 	// add some middlewares to the router to allow identifying requests
 	router.Use(wisdomMiddleware.ErrorHandler(globals.ServiceName, globals.Errors))
 	router.Use(chiMiddleware.RequestID)
-	router.Use(chiMiddleware.RealIP)
+	// the forwarded client addresses are only used if they have been set by
+	// a trusted proxy since the address rate limit relies on them
+	router.Use(limits.RealIP(trustedProxies))
+	router.Use(tracing.Middleware)
+	router.Use(metrics.Middleware)
 	router.Use(httplog.Handler(l))
+	// now limit the rate of the requests of every address before
+	// authenticating them to limit the attempts of guessing credentials
+	if addressRateLimiter != nil {
+		router.Use(addressRateLimiter.Middleware)
+	}
 	// now add the authorization middleware to the router
-	router.Use(wisdomMiddleware.Authorization(globals.AuthorizationConfiguration, globals.ServiceName))
-	// now mount the admin router
-	router.Get("/", routes.ConsumerList)
-	router.Get("/{consumer-id}", routes.SingleConsumer)
-	router.Post("/", routes.CreateNewConsumer)
+	router.Use(auth.Authenticate(auth.Configuration{
+		Authorization: globals.AuthorizationConfiguration,
+		GroupScopes:   globals.GroupScopes,
+		GroupAreas:    globals.GroupAreas,
+		GroupTenants:  globals.GroupTenants,
+		TenantHeader:  strings.TrimSpace(globals.Environment["TENANT_HEADER"]),
+		Tokens:        tokenValidator,
+		BeginSystemTx: func(ctx context.Context) (*sql.Tx, error) {
+			return tenancy.BeginSystemTx(ctx, globals.Db, nil)
+		},
+	}))
+	// now limit the rate of the requests of every user before the requests
+	// occupy any database connections
+	if rateLimiter != nil {
+		router.Use(rateLimiter.Middleware)
+	}
+	// now bind the database connections used by the requests to the tenant
+	// of the user
+	router.Use(tenancy.Bind(globals.Db))
+	// the scopes required by the routes
+	canRead := auth.RequireScope(auth.ScopeRead)
+	canWrite := auth.RequireScope(auth.ScopeWrite)
+	canDelete := auth.RequireScope(auth.ScopeDelete)
+	canMerge := auth.RequireScope(auth.ScopeWrite, auth.ScopeDelete)
+	isAdmin := auth.RequireScope(auth.ScopeAdmin)
+	// now mount the admin router. the default body size limit applies to
+	// every route except the import of usage records, which uses a larger
+	// limit
+	router.Group(func(r chi.Router) {
+		r.Use(limits.MaxBodySize(maxBodySize))
+		r.With(canRead).Get("/", routes.ConsumerList)
+		r.With(canRead).Get("/{consumer-id}", routes.SingleConsumer)
+		r.With(canWrite).Post("/", routes.CreateNewConsumer)
+		r.With(canWrite).Patch("/{consumer-id}", routes.UpdateConsumer)
+		r.With(canDelete).Delete("/{consumer-id}", routes.DeleteConsumer)
+		r.With(canRead).Get("/events", routes.ConsumerEvents(consumerEvents))
+		r.With(canMerge).Post("/merge", routes.MergeConsumers)
+		r.With(canRead).Get("/usages/aggregate", routes.AreaUsageAggregate)
+		r.With(canRead).Get("/statistics", routes.UsageStatistics)
+		r.With(isAdmin).Get("/audit", routes.AuditLog)
+		r.Route("/usage-types", func(r chi.Router) {
+			r.With(canRead).Get("/", routes.UsageTypeList)
+			r.With(canWrite).Post("/", routes.CreateUsageType)
+			r.With(canRead).Get("/{usage-type-id}", routes.SingleUsageType)
+			r.With(canWrite).Patch("/{usage-type-id}", routes.UpdateUsageType)
+			r.With(canDelete).Delete("/{usage-type-id}", routes.DeleteUsageType)
+		})
+		r.Route("/webhooks", func(r chi.Router) {
+			r.Use(isAdmin)
+			r.Get("/", routes.WebhookList)
+			r.Post("/", routes.CreateWebhook)
+			r.Get("/dead-letters", routes.DeadLetters)
+			r.Post("/deliveries/{delivery-id}/retry", routes.RetryWebhookDelivery)
+			r.Get("/{webhook-id}", routes.SingleWebhook)
+			r.Put("/{webhook-id}", routes.UpdateWebhook)
+			r.Delete("/{webhook-id}", routes.DeleteWebhook)
+			r.Get("/{webhook-id}/deliveries", routes.WebhookDeliveries)
+		})
+		r.Route("/api-keys", func(r chi.Router) {
+			r.Use(isAdmin)
+			r.Get("/", routes.APIKeyList)
+			r.Post("/", routes.CreateAPIKey)
+			r.Delete("/{api-key-id}", routes.RevokeAPIKey)
+		})
+		r.With(canRead).Get("/forecast", routes.AreaForecast)
+		r.With(canRead).Get("/anomalies", routes.AnomalyList)
+		r.With(canWrite).Post("/anomalies/{anomaly-id}/acknowledge", routes.AcknowledgeAnomaly)
+		r.With(canWrite).Post("/anomalies/{anomaly-id}/dismiss", routes.DismissAnomaly)
+		r.With(canRead).Get("/{consumer-id}/usages", routes.ConsumerUsages)
+		r.With(canRead).Get("/{consumer-id}/usages/aggregate", routes.ConsumerUsageAggregate)
+		r.With(canRead).Get("/{consumer-id}/anomalies", routes.ConsumerAnomalies)
+		r.With(canRead).Get("/{consumer-id}/forecast", routes.ConsumerForecast)
+		r.With(canRead).Get("/{consumer-id}/history", routes.ConsumerHistory)
+		r.With(canWrite).Post("/{consumer-id}/history/{version}/revert", routes.RevertConsumer)
+		r.With(isAdmin).Get("/{consumer-id}/export", routes.ExportConsumer)
+		r.With(isAdmin).Post("/{consumer-id}/erase", routes.EraseConsumer)
+		r.With(canDelete).Delete("/{consumer-id}/usages/{usage-id}", routes.DeleteUsageRecord)
+	})
+	router.With(canWrite, limits.MaxBodySize(maxImportBodySize)).Post("/{consumer-id}/usages", routes.CreateUsageRecords)
 
 	// now boot up the service
 	// Configure the HTTP server
@@ -55,6 +233,20 @@ func main() {
 		}
 	}()
 
+	// now expose the metrics on a separate port which is not routed through
+	// the api gateway. requests to other paths are answered with 404 Not Found
+	metricsRouter := http.NewServeMux()
+	metricsRouter.Handle("/metrics", metrics.Handler())
+	metricsServer := &http.Server{
+		Addr:    fmt.Sprintf("0.0.0.0:%s", globals.Environment["METRICS_LISTEN_PORT"]),
+		Handler: metricsRouter,
+	}
+	go func() {
+		if err := metricsServer.ListenAndServe(); err != nil {
+			l.Fatal().Err(err).Msg("An error occurred while starting the metrics server")
+		}
+	}()
+
 	// Set up the signal handling to allow the server to shut down gracefully
 
 	cancelSignal := make(chan os.Signal, 1)
@@ -63,4 +255,10 @@ func main() {
 	// Block further code execution until the shutdown signal was received
 	<-cancelSignal
 
+	// flush the remaining spans before shutting down
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelShutdown()
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		l.Warn().Err(err).Msg("unable to flush remaining spans")
+	}
 }
@@ -0,0 +1,164 @@
+// Package events distributes the changes of consumers to the clients that
+// subscribed to them. The changes are received from the database using
+// LISTEN/NOTIFY which allows every replica of the service to publish the
+// changes made through any other replica.
+package events
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/blockloop/scan/v2"
+	"github.com/lib/pq"
+	"github.com/qustavo/dotsql"
+	"github.com/rs/zerolog/log"
+
+	"github.com/wisdom-oss/service-consumers/globals"
+	"github.com/wisdom-oss/service-consumers/tenancy"
+	"github.com/wisdom-oss/service-consumers/types"
+)
+
+// notificationChannel contains the name of the channel the consumer history
+// trigger sends the recorded versions to
+const notificationChannel = "consumer_events"
+
+// subscriptionBuffer contains the number of events that are buffered for a
+// single subscriber. Subscribers which are not able to keep up are dropped
+// and need to resume using the id of the last received event
+const subscriptionBuffer = 64
+
+// Hub listens for the changes of consumers and publishes them to the
+// subscribers
+type Hub struct {
+	dsn         string
+	mutex       sync.Mutex
+	subscribers map[chan types.ConsumerEvent]struct{}
+}
+
+// NewHub creates a new hub that opens its own connection to the database
+// using the supplied connection string, since listening for notifications
+// requires a dedicated connection
+func NewHub(dsn string) *Hub {
+	return &Hub{dsn: dsn, subscribers: make(map[chan types.ConsumerEvent]struct{})}
+}
+
+// Subscribe registers a new subscriber. The returned channel is closed if the
+// subscriber is dropped or has been unsubscribed using the returned function
+func (h *Hub) Subscribe() (<-chan types.ConsumerEvent, func()) {
+	subscription := make(chan types.ConsumerEvent, subscriptionBuffer)
+	h.mutex.Lock()
+	h.subscribers[subscription] = struct{}{}
+	h.mutex.Unlock()
+
+	return subscription, func() {
+		h.mutex.Lock()
+		defer h.mutex.Unlock()
+		if _, subscribed := h.subscribers[subscription]; subscribed {
+			delete(h.subscribers, subscription)
+			close(subscription)
+		}
+	}
+}
+
+// Run listens for the notifications sent by the database and publishes the
+// changed consumers until the context is canceled
+func (h *Hub) Run(ctx context.Context) {
+	l := log.With().Str("job", "consumer-events").Logger()
+
+	listener := pq.NewListener(h.dsn, time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			l.Warn().Err(err).Msg("consumer event listener connection changed")
+		}
+	})
+	defer listener.Close()
+
+	err := listener.Listen(notificationChannel)
+	if err != nil {
+		l.Error().Err(err).Msg("unable to listen for consumer events")
+		return
+	}
+	l.Info().Msg("listening for consumer events")
+
+	// check the connection periodically since the listener only detects a
+	// lost connection while sending
+	pingTicker := time.NewTicker(90 * time.Second)
+	defer pingTicker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case notification := <-listener.Notify:
+			// a nil notification is sent after the connection has been
+			// reestablished. notifications sent in the meantime are lost, but
+			// clients are able to resume from the last event they received
+			if notification == nil {
+				l.Warn().Msg("reconnected to the database. consumer events may have been missed")
+				continue
+			}
+			version, err := strconv.ParseInt(notification.Extra, 10, 64)
+			if err != nil {
+				l.Error().Err(err).Str("payload", notification.Extra).Msg("unable to parse consumer event")
+				continue
+			}
+			event, err := loadEvent(ctx, version)
+			if err != nil {
+				l.Error().Err(err).Int64("version", version).Msg("unable to load consumer event")
+				continue
+			}
+			h.publish(event)
+		case <-pingTicker.C:
+			go listener.Ping()
+		}
+	}
+}
+
+// publish sends the event to all subscribers and drops the subscribers
+// which are not able to keep up
+func (h *Hub) publish(event types.ConsumerEvent) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	for subscription := range h.subscribers {
+		select {
+		case subscription <- event:
+		default:
+			delete(h.subscribers, subscription)
+			close(subscription)
+		}
+	}
+}
+
+// loadEvent reads the consumer version from the consumer history and builds
+// the event from it. Since the events of every tenant are published, the
+// version is read using the system tenant
+func loadEvent(ctx context.Context, version int64) (types.ConsumerEvent, error) {
+	var event types.ConsumerEvent
+	tx, err := tenancy.BeginSystemTx(ctx, globals.Db, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return event, err
+	}
+	defer tx.Rollback()
+	rows, err := globals.SqlQueries.QueryContext(ctx, tx, "get-consumer-event", version)
+	if err != nil {
+		return event, err
+	}
+	err = scan.Row(&event, rows)
+	return event, err
+}
+
+// EventsSince reads the events that have been recorded after the event with
+// the supplied id from the consumer history. This allows clients to resume
+// after losing their connection. Only the events visible using the supplied
+// connection are returned
+func EventsSince(ctx context.Context, db dotsql.QueryerContext, lastEventID int64) ([]types.ConsumerEvent, error) {
+	rows, err := globals.SqlQueries.QueryContext(ctx, db, "get-consumer-events-since", lastEventID)
+	if err != nil {
+		return nil, err
+	}
+	var events []types.ConsumerEvent
+	err = scan.Rows(&events, rows)
+	return events, err
+}
@@ -0,0 +1,56 @@
+// Package forecast contains simple seasonal forecasting models which are used
+// to estimate the future usages of consumers without depending on external
+// services.
+package forecast
+
+import (
+	"errors"
+	"math"
+)
+
+// ErrInsufficientHistory is returned if the supplied series is too short to
+// fit the requested model
+var ErrInsufficientHistory = errors.New("insufficient history for forecasting")
+
+// ErrInvalidLevel is returned if the confidence level of the prediction
+// intervals is not between zero and one
+var ErrInvalidLevel = errors.New("confidence level needs to be between zero and one")
+
+// Prediction contains a single point forecast and its prediction interval
+type Prediction struct {
+	// Value contains the point forecast
+	Value float64
+
+	// Lower contains the lower bound of the prediction interval
+	Lower float64
+
+	// Upper contains the upper bound of the prediction interval
+	Upper float64
+}
+
+// Model is implemented by all forecasting models in this package
+type Model interface {
+	// Forecast fits the model to the series and returns the point forecasts
+	// and prediction intervals for the supplied horizon. The prediction
+	// intervals are calculated for the supplied confidence level
+	Forecast(series []float64, horizon int, level float64) ([]Prediction, error)
+}
+
+// quantile returns the quantile of the standard normal distribution which
+// is used for symmetric prediction intervals with the supplied confidence
+// level
+func quantile(level float64) (float64, error) {
+	if level <= 0 || level >= 1 {
+		return 0, ErrInvalidLevel
+	}
+	return math.Sqrt2 * math.Erfinv(level), nil
+}
+
+// mean calculates the arithmetic mean of the supplied values
+func mean(values []float64) float64 {
+	var sum float64
+	for _, value := range values {
+		sum += value
+	}
+	return sum / float64(len(values))
+}
@@ -0,0 +1,186 @@
+package forecast
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+// tolerance contains the maximal absolute difference between an expected and
+// a forecasted value
+const tolerance = 1e-9
+
+// seasonalSeries generates a series repeating the pattern for the supplied
+// number of seasons. The trend is added once per period
+func seasonalSeries(pattern []float64, seasons int, trend float64) []float64 {
+	series := make([]float64, 0, len(pattern)*seasons)
+	for i := 0; i < len(pattern)*seasons; i++ {
+		series = append(series, pattern[i%len(pattern)]+float64(i)*trend)
+	}
+	return series
+}
+
+func TestQuantile(t *testing.T) {
+	tests := []struct {
+		level    float64
+		expected float64
+		err      error
+	}{
+		{level: 0.8, expected: 1.2815515655446004},
+		{level: 0.95, expected: 1.959963984540054},
+		{level: 0, err: ErrInvalidLevel},
+		{level: 1, err: ErrInvalidLevel},
+		{level: -0.5, err: ErrInvalidLevel},
+	}
+
+	for _, test := range tests {
+		z, err := quantile(test.level)
+		if !errors.Is(err, test.err) {
+			t.Errorf("level %v: expected error %v, got %v", test.level, test.err, err)
+			continue
+		}
+		if math.Abs(z-test.expected) > tolerance {
+			t.Errorf("level %v: expected quantile %v, got %v", test.level, test.expected, z)
+		}
+	}
+}
+
+func TestForecastErrors(t *testing.T) {
+	series := seasonalSeries([]float64{1, 2, 3, 4}, 3, 0)
+	tests := []struct {
+		name   string
+		model  Model
+		series []float64
+		level  float64
+		err    error
+	}{
+		{name: "seasonal naive with a single season", model: SeasonalNaive(4), series: series[:4], level: 0.8, err: ErrInsufficientHistory},
+		{name: "seasonal naive without season", model: SeasonalNaive(0), series: series, level: 0.8, err: ErrInsufficientHistory},
+		{name: "seasonal naive with invalid level", model: SeasonalNaive(4), series: series, level: 1.5, err: ErrInvalidLevel},
+		{name: "holt-winters with less than two seasons", model: HoltWinters(4), series: series[:7], level: 0.8, err: ErrInsufficientHistory},
+		{name: "holt-winters with a season of one period", model: HoltWinters(1), series: series, level: 0.8, err: ErrInsufficientHistory},
+		{name: "holt-winters with invalid level", model: HoltWinters(4), series: series, level: 0, err: ErrInvalidLevel},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := test.model.Forecast(test.series, 4, test.level)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("expected error %v, got %v", test.err, err)
+			}
+		})
+	}
+}
+
+func TestForecastExactSeries(t *testing.T) {
+	// series without noise are forecast exactly and without uncertainty
+	pattern := []float64{10, 20, 15, 5}
+	tests := []struct {
+		name     string
+		model    Model
+		series   []float64
+		expected []float64
+	}{
+		{
+			name:     "seasonal naive repeats the last season",
+			model:    SeasonalNaive(4),
+			series:   seasonalSeries(pattern, 3, 0),
+			expected: []float64{10, 20, 15, 5, 10, 20},
+		},
+		{
+			name:     "seasonal naive continues the drift of every season",
+			model:    SeasonalNaive(4),
+			series:   seasonalSeries(pattern, 3, 1),
+			expected: []float64{22, 33, 29, 20, 26, 37},
+		},
+		{
+			name:     "holt-winters repeats a seasonal series",
+			model:    HoltWinters(4),
+			series:   seasonalSeries(pattern, 3, 0),
+			expected: []float64{10, 20, 15, 5, 10, 20},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			predictions, err := test.model.Forecast(test.series, len(test.expected), 0.95)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(predictions) != len(test.expected) {
+				t.Fatalf("expected %d predictions, got %d", len(test.expected), len(predictions))
+			}
+			for i, prediction := range predictions {
+				if math.Abs(prediction.Value-test.expected[i]) > tolerance {
+					t.Errorf("prediction %d: expected %v, got %v", i, test.expected[i], prediction.Value)
+				}
+				if prediction.Upper-prediction.Lower > tolerance {
+					t.Errorf("prediction %d: expected no uncertainty, got [%v, %v]", i, prediction.Lower, prediction.Upper)
+				}
+			}
+		})
+	}
+}
+
+func TestForecastIntervals(t *testing.T) {
+	// the noise repeats with a period that does not match the season
+	noise := []float64{1.5, -2, 0.5, 2.5, -1, -1.5, 3, -3, 0}
+	series := seasonalSeries([]float64{100, 140, 120, 80}, 6, 0.5)
+	for i := range series {
+		series[i] += noise[i%len(noise)]
+	}
+
+	tests := []struct {
+		name  string
+		model Model
+	}{
+		{name: "seasonal naive", model: SeasonalNaive(4)},
+		{name: "holt-winters", model: HoltWinters(4)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			narrow, err := test.model.Forecast(series, 8, 0.8)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			wide, err := test.model.Forecast(series, 8, 0.95)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			var previousWidth float64
+			for i := range narrow {
+				width := narrow[i].Upper - narrow[i].Lower
+				if narrow[i].Lower >= narrow[i].Value || narrow[i].Upper <= narrow[i].Value {
+					t.Errorf("prediction %d: value %v outside of the interval [%v, %v]", i, narrow[i].Value, narrow[i].Lower, narrow[i].Upper)
+				}
+				if width < previousWidth-tolerance {
+					t.Errorf("prediction %d: interval narrower than the interval of the previous period", i)
+				}
+				if wide[i].Upper-wide[i].Lower <= width {
+					t.Errorf("prediction %d: higher confidence level does not widen the interval", i)
+				}
+				previousWidth = width
+			}
+		})
+	}
+}
+
+func TestHoltWintersTrend(t *testing.T) {
+	// the initial seasonal components contain the trend within the first
+	// season. the smoothing corrects them, which leaves a small deviation
+	pattern := []float64{100, 140, 120, 80}
+	series := seasonalSeries(pattern, 6, 2)
+	predictions, err := HoltWinters(4).Forecast(series, 8, 0.8)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for i, prediction := range predictions {
+		period := len(series) + i
+		expected := pattern[period%len(pattern)] + float64(period)*2
+		if math.Abs(prediction.Value-expected) > 0.5 {
+			t.Errorf("prediction %d: expected about %v, got %v", i, expected, prediction.Value)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package forecast
+
+import (
+	"math"
+)
+
+// smoothingGrid contains the values tried for each smoothing parameter while
+// fitting the Holt-Winters model
+var smoothingGrid = []float64{0.05, 0.1, 0.2, 0.3, 0.4, 0.5, 0.6, 0.7, 0.8, 0.9}
+
+// HoltWinters returns the additive Holt-Winters model (triple exponential
+// smoothing) for series with the supplied season length.
+// The model is smoothed in the error correction form (see Hyndman et al.,
+// 2008) and the smoothing parameters are chosen by minimizing the squared
+// one-step-ahead errors. The model requires at least two full seasons
+func HoltWinters(seasonLength int) Model {
+	return holtWinters{seasonLength: seasonLength}
+}
+
+type holtWinters struct {
+	seasonLength int
+}
+
+// holtWintersState contains the state of the model after smoothing a series
+type holtWintersState struct {
+	alpha, beta, gamma float64
+	level, trend       float64
+	seasonals          []float64
+	squaredErrors      float64
+}
+
+func (hw holtWinters) Forecast(series []float64, horizon int, level float64) ([]Prediction, error) {
+	m := hw.seasonLength
+	if m < 2 || len(series) < 2*m {
+		return nil, ErrInsufficientHistory
+	}
+	z, err := quantile(level)
+	if err != nil {
+		return nil, err
+	}
+
+	// fit the model by trying every combination of the smoothing parameters
+	var best *holtWintersState
+	// only combinations equivalent to smoothing parameters between zero and
+	// one in the conventional form are tried (0 < beta <= alpha and
+	// 0 < gamma <= 1 - alpha)
+	for _, alpha := range smoothingGrid {
+		for _, beta := range smoothingGrid {
+			if beta > alpha {
+				continue
+			}
+			for _, gamma := range smoothingGrid {
+				if gamma > 1-alpha {
+					continue
+				}
+				state := hw.smooth(series, alpha, beta, gamma)
+				if best == nil || state.squaredErrors < best.squaredErrors {
+					best = state
+				}
+			}
+		}
+	}
+
+	// the first season is only used for initializing the model and therefore
+	// does not contribute to the errors
+	evaluatedPoints := len(series) - m
+	sigma := math.Sqrt(best.squaredErrors / float64(evaluatedPoints))
+
+	predictions := make([]Prediction, horizon)
+	var varianceFactor float64 = 1
+	for h := 1; h <= horizon; h++ {
+		// the variance of the additive model grows with the sum of the squared
+		// coefficients of the previous steps (see Hyndman et al., 2008). The
+		// coefficients use the trend smoothing scaled by the level smoothing
+		if h > 1 {
+			j := float64(h - 1)
+			c := best.alpha * (1 + j*best.beta/best.alpha)
+			if (h-1)%m == 0 {
+				c += best.gamma
+			}
+			varianceFactor += c * c
+		}
+		value := best.level + float64(h)*best.trend + best.seasonals[(len(series)+h-1)%m]
+		margin := z * sigma * math.Sqrt(varianceFactor)
+		predictions[h-1] = Prediction{Value: value, Lower: value - margin, Upper: value + margin}
+	}
+	return predictions, nil
+}
+
+// smooth applies the Holt-Winters equations in the error correction form with
+// the supplied smoothing parameters to the series and collects the squared
+// one-step-ahead errors
+func (hw holtWinters) smooth(series []float64, alpha, beta, gamma float64) *holtWintersState {
+	m := hw.seasonLength
+	state := &holtWintersState{alpha: alpha, beta: beta, gamma: gamma}
+
+	// initialize the level, trend and seasonal components using the first two
+	// seasons
+	firstSeasonMean := mean(series[:m])
+	secondSeasonMean := mean(series[m : 2*m])
+	state.level = firstSeasonMean
+	state.trend = (secondSeasonMean - firstSeasonMean) / float64(m)
+	state.seasonals = make([]float64, m)
+	for i := 0; i < m; i++ {
+		state.seasonals[i] = series[i] - firstSeasonMean
+	}
+
+	for t := m; t < len(series); t++ {
+		seasonal := state.seasonals[t%m]
+		predicted := state.level + state.trend + seasonal
+		predictionError := series[t] - predicted
+		state.squaredErrors += predictionError * predictionError
+
+		state.level += state.trend + alpha*predictionError
+		state.trend += beta * predictionError
+		state.seasonals[t%m] = seasonal + gamma*predictionError
+	}
+	return state
+}
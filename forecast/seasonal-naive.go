@@ -0,0 +1,56 @@
+package forecast
+
+import (
+	"math"
+)
+
+// SeasonalNaive returns the seasonal naive model with trend for series with
+// the supplied season length.
+// The model repeats the values of the last season and adds the mean change
+// between two seasons for every season that lies in the future.
+// The model requires more than one full season
+func SeasonalNaive(seasonLength int) Model {
+	return seasonalNaive{seasonLength: seasonLength}
+}
+
+type seasonalNaive struct {
+	seasonLength int
+}
+
+func (sn seasonalNaive) Forecast(series []float64, horizon int, level float64) ([]Prediction, error) {
+	m := sn.seasonLength
+	if m < 1 || len(series) <= m {
+		return nil, ErrInsufficientHistory
+	}
+	z, err := quantile(level)
+	if err != nil {
+		return nil, err
+	}
+
+	// calculate the changes between the same periods of two consecutive
+	// seasons. their mean is used as the trend per season
+	differences := make([]float64, 0, len(series)-m)
+	for t := m; t < len(series); t++ {
+		differences = append(differences, series[t]-series[t-m])
+	}
+	drift := mean(differences)
+
+	// the residuals of the model are the seasonal differences without the
+	// drift
+	var squaredErrors float64
+	for _, difference := range differences {
+		squaredErrors += (difference - drift) * (difference - drift)
+	}
+	sigma := math.Sqrt(squaredErrors / float64(len(differences)))
+
+	predictions := make([]Prediction, horizon)
+	lastSeason := series[len(series)-m:]
+	for h := 1; h <= horizon; h++ {
+		// the number of seasons the forecasted period lies in the future
+		seasons := float64((h-1)/m + 1)
+		value := lastSeason[(h-1)%m] + seasons*drift
+		margin := z * sigma * math.Sqrt(seasons)
+		predictions[h-1] = Prediction{Value: value, Lower: value - margin, Upper: value + margin}
+	}
+	return predictions, nil
+}
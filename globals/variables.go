@@ -17,6 +17,16 @@ var SqlQueries *dotsql.DotSql
 // middleware for this microservice
 var AuthorizationConfiguration wisdomType.AuthorizationConfiguration
 
+// GroupScopes maps the user groups to the scopes granted to their members
+var GroupScopes map[string][]string
+
+// GroupAreas maps the user groups to the keys of the shapes their members are
+// restricted to
+var GroupAreas map[string][]string
+
+// GroupTenants maps the user groups to the tenants their members belong to
+var GroupTenants map[string]string
+
 // Environment contains a mapping between the environment variables and the values
 // they were set to. However, this variable only contains the configured environment
 // variables
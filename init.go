@@ -1,10 +1,12 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
 	"os"
+	"slices"
 	"strings"
 
 	wisdomType "github.com/wisdom-oss/commonTypes"
@@ -15,13 +17,21 @@ import (
 	"github.com/rs/zerolog/log"
 	"github.com/rs/zerolog/pkgerrors"
 
+	"github.com/wisdom-oss/service-consumers/auth"
 	"github.com/wisdom-oss/service-consumers/globals"
-
-	_ "github.com/lib/pq"
+	"github.com/wisdom-oss/service-consumers/metrics"
+	"github.com/wisdom-oss/service-consumers/observedsql"
+	"github.com/wisdom-oss/service-consumers/tenancy"
+	"github.com/wisdom-oss/service-consumers/tracing"
 )
 
 var l zerolog.Logger
 
+// databaseDSN contains the connection string used for connecting to the
+// database. it is kept since listening for notifications requires a dedicated
+// connection
+var databaseDSN string
+
 // defaultAuth contains the default authentication configuration if no file
 // is present (which shouldn't be the case). it only allows named users
 // access to this service who use the same group as the service name
@@ -31,6 +41,23 @@ var defaultAuth = wisdomType.AuthorizationConfiguration{
 	RequiredUserGroup:         globals.ServiceName,
 }
 
+// schemaQueries contains the names of the queries that create the tables
+// managed by this service. the queries are executed in the listed order
+var schemaQueries = []string{
+	"create-usage-types-table",
+	"create-usage-anomalies-table",
+	"extend-consumers-table",
+	"create-consumer-merges-table",
+	"create-consumer-history-table",
+	"create-consumer-history-trigger",
+	"create-audit-log-table",
+	"create-outbox-table",
+	"create-webhook-tables",
+	"extend-outbox-table",
+	"create-api-keys-table",
+	"create-tenancy",
+}
+
 // this init functions sets up the logger which is used for this microservice
 func init() {
 	// load the variables found in the .env file into the process environment
@@ -129,33 +156,84 @@ func init() {
 	l.Info().Msg("loaded predefined errors")
 }
 
+// authorizationFile contains the contents of the authorization configuration
+// file. next to the configuration of the authorization, the file maps the
+// user groups to the scopes granted to them, to the areas (the keys of the
+// shapes in geodata.shapes) their members are restricted to and to the
+// tenants (the utilities hosted by the deployment) their members belong to
+type authorizationFile struct {
+	wisdomType.AuthorizationConfiguration
+	GroupScopes  map[string][]string `json:"groupScopes"`
+	GroupAreas   map[string][]string `json:"groupAreas"`
+	GroupTenants map[string]string   `json:"groupTenants"`
+}
+
+// defaultGroupScopes returns the scopes used if the authorization
+// configuration does not map any groups. the group required by the
+// configuration is granted every scope except the admin scope
+func defaultGroupScopes(c wisdomType.AuthorizationConfiguration) map[string][]string {
+	return map[string][]string{
+		c.RequiredUserGroup: {auth.ScopeRead, auth.ScopeWrite, auth.ScopeDelete, auth.ScopePII},
+	}
+}
+
 // this function loads the externally defined authorization configuration
 // and overwrites the default options laid out here
 func init() {
 	l.Info().Msg("loading authorization configuration")
+	globals.AuthorizationConfiguration = defaultAuth
+	globals.GroupScopes = defaultGroupScopes(defaultAuth)
+
 	filePath, isSet := globals.Environment["AUTH_CONFIG_FILE_LOCATION"]
 	if !isSet {
 		l.Warn().Msg("no auth file location set in environment. using default")
-		globals.AuthorizationConfiguration = defaultAuth
 		return
 	}
 	// now check if the path is not empty
 	if filePath == "" || strings.TrimSpace(filePath) == "" {
 		l.Warn().Msg("empty path supplied for error file location. using default")
-		globals.AuthorizationConfiguration = defaultAuth
 		return
 	}
 
 	// since a file was found, read from the file path
-	var authConfig wisdomType.AuthorizationConfiguration
-	err := authConfig.PopulateFromFilePath(filePath)
+	file, err := os.Open(filePath)
+	if err != nil {
+		l.Error().Err(err).Msg("unable to open authorization configuration. using default")
+		return
+	}
+	defer file.Close()
+	var authConfig authorizationFile
+	err = json.NewDecoder(file).Decode(&authConfig)
 	if err != nil {
-		l.Error().Err(err).Msg("unable to parse authorization configuration. ussing default")
-		globals.AuthorizationConfiguration = defaultAuth
+		l.Error().Err(err).Msg("unable to parse authorization configuration. using default")
 		return
 	}
 
-	globals.AuthorizationConfiguration = authConfig
+	// now check that the groups are only mapped to known scopes
+	for group, scopes := range authConfig.GroupScopes {
+		for _, scope := range scopes {
+			if !slices.Contains(auth.Scopes, scope) {
+				l.Error().Str("group", group).Str("scope", scope).Msg("unknown scope in authorization configuration. using default")
+				return
+			}
+		}
+	}
+
+	// now check that the groups are only mapped to valid tenants
+	for group, tenant := range authConfig.GroupTenants {
+		if !auth.ValidTenant(tenant) {
+			l.Error().Str("group", group).Str("tenant", tenant).Msg("invalid tenant in authorization configuration. using default")
+			return
+		}
+	}
+
+	globals.AuthorizationConfiguration = authConfig.AuthorizationConfiguration
+	globals.GroupScopes = authConfig.GroupScopes
+	globals.GroupAreas = authConfig.GroupAreas
+	globals.GroupTenants = authConfig.GroupTenants
+	if len(authConfig.GroupScopes) == 0 {
+		globals.GroupScopes = defaultGroupScopes(authConfig.AuthorizationConfiguration)
+	}
 	l.Info().Msg("loaded authorization configuration")
 }
 
@@ -164,13 +242,15 @@ func init() {
 func init() {
 	l.Info().Msg("preparing global database connection")
 	// build a dsn from the environment variables
-	dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=wisdom sslmode=disable",
+	databaseDSN = fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=wisdom sslmode=disable",
 		globals.Environment["PG_HOST"], globals.Environment["PG_PORT"], globals.Environment["PG_USER"],
 		globals.Environment["PG_PASS"])
 
 	// now open the connection to the database
 	var err error
-	globals.Db, err = sql.Open("postgres", dsn)
+	// the connection uses the observed driver which reports the executed
+	// statements to the metrics
+	globals.Db, err = sql.Open(observedsql.DriverName, databaseDSN)
 	if err != nil {
 		l.Fatal().Err(err).Msg("failed to open database connection")
 	}
@@ -190,6 +270,99 @@ func init() {
 	if err != nil {
 		l.Fatal().Err(err).Msg("unable to load queries used by the service")
 	}
+
+	// now identify the executed statements by their query names, collect the
+	// durations of the statements and the statistics of the connection pool
+	// and trace the statements
+	observedsql.SetQueries(globals.SqlQueries.QueryMap())
+	observedsql.Observe(metrics.ObserveQuery)
+	observedsql.Observe(tracing.ObserveQuery)
+	err = metrics.RegisterDatabase(globals.Db)
+	if err != nil {
+		l.Fatal().Err(err).Msg("unable to collect database statistics")
+	}
+}
+
+// this function creates the tables that are managed by this service if they
+// do not exist yet
+func init() {
+	l.Info().Msg("preparing database schema")
+	// the schema is prepared in a single transaction holding an advisory lock
+	// to keep replicas started at the same time from preparing it
+	// concurrently. the transaction is bound to the system tenant to let the
+	// history backfill see the consumers of every tenant
+	ctx := context.Background()
+	tx, err := tenancy.BeginSystemTx(ctx, globals.Db, nil)
+	if err != nil {
+		l.Fatal().Err(err).Msg("unable to start the schema preparation")
+	}
+	_, err = globals.SqlQueries.Exec(tx, "lock-schema-migration")
+	if err != nil {
+		l.Fatal().Err(err).Msg("unable to lock the schema preparation")
+	}
+	for _, queryName := range schemaQueries {
+		_, err := globals.SqlQueries.Exec(tx, queryName)
+		if err != nil {
+			l.Fatal().Err(err).Str("query", queryName).Msg("unable to prepare database schema")
+		}
+	}
+	err = backfillConsumerHistory(ctx, tx)
+	if err != nil {
+		l.Fatal().Err(err).Msg("unable to record the history of existing consumers")
+	}
+	err = tx.Commit()
+	if err != nil {
+		l.Fatal().Err(err).Msg("unable to prepare database schema")
+	}
+	l.Info().Msg("prepared database schema")
+
+	// the separation of the tenants relies on the row level security which
+	// does not apply to superusers and roles bypassing it
+	var bypassesRowLevelSecurity bool
+	row, err := globals.SqlQueries.QueryRow(globals.Db, "bypasses-row-level-security")
+	if err == nil {
+		err = row.Scan(&bypassesRowLevelSecurity)
+	}
+	if err != nil {
+		l.Fatal().Err(err).Msg("unable to check the privileges of the database user")
+	}
+	if bypassesRowLevelSecurity {
+		l.Fatal().Msg("the database user bypasses row level security. use a database user without the superuser and bypassrls attributes to separate the tenants")
+	}
+}
+
+// backfillConsumerHistory records a snapshot of the consumers created before
+// their history has been recorded. the system tenant may not create rows, so
+// the transaction is bound to the tenant of the consumers while recording
+// their snapshots
+func backfillConsumerHistory(ctx context.Context, tx *sql.Tx) error {
+	rows, err := globals.SqlQueries.Query(tx, "consumer-history-backfill-tenants")
+	if err != nil {
+		return err
+	}
+	var tenants []string
+	for rows.Next() {
+		var tenant string
+		if err := rows.Scan(&tenant); err != nil {
+			rows.Close()
+			return err
+		}
+		tenants = append(tenants, tenant)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, tenant := range tenants {
+		if err := tenancy.BindTx(ctx, tx, tenant); err != nil {
+			return err
+		}
+		if _, err := globals.SqlQueries.Exec(tx, "backfill-consumer-history", tenant); err != nil {
+			return err
+		}
+	}
+	return tenancy.BindTx(ctx, tx, tenancy.System)
 }
 
 // this function just logs that the init process is finished
@@ -0,0 +1,152 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/wisdom-oss/service-consumers/globals"
+	"github.com/wisdom-oss/service-consumers/tenancy"
+)
+
+// ErrUnknownDetectionMethod is returned if the configured anomaly detection
+// method is not supported
+var ErrUnknownDetectionMethod = errors.New("unknown anomaly detection method")
+
+// detectionQueries maps the supported detection methods to the queries which
+// flag deviating usage records
+var detectionQueries = map[string]string{
+	"zscore":       "detect-deviations-zscore",
+	"seasonal-mad": "detect-deviations-seasonal-mad",
+}
+
+// AnomalyDetection contains the configuration of the anomaly detection job
+// which flags usage records deviating from the consumer's history and
+// consumers whose meters stopped reporting
+type AnomalyDetection struct {
+	// Interval contains the time between two detection runs. If the interval
+	// is zero, the detection is disabled
+	Interval time.Duration
+
+	// Method contains the detection method used for flagging deviating
+	// usage records (either "zscore" or "seasonal-mad")
+	Method string
+
+	// Threshold contains the score above which a usage record is flagged
+	Threshold float64
+
+	// MinimalHistory contains the minimal number of readings in the reference
+	// group of a usage record before it is evaluated. For the z-score, the
+	// reference group contains all readings of the consumer. For the seasonal
+	// median/MAD, it contains the readings in the same calendar month
+	MinimalHistory int
+
+	// MissingReadingsAfter contains the duration after the last reading of a
+	// consumer after which the meter is flagged as not reporting
+	MissingReadingsAfter time.Duration
+}
+
+// AnomalyDetectionFromEnvironment reads the configuration of the anomaly
+// detection from the supplied environment
+func AnomalyDetectionFromEnvironment(environment map[string]string) (*AnomalyDetection, error) {
+	var d AnomalyDetection
+	var err error
+
+	d.Interval, err = time.ParseDuration(environment["ANOMALY_DETECTION_INTERVAL"])
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse anomaly detection interval: %w", err)
+	}
+
+	d.Method = environment["ANOMALY_DETECTION_METHOD"]
+	if _, methodSupported := detectionQueries[d.Method]; !methodSupported {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownDetectionMethod, d.Method)
+	}
+
+	d.Threshold, err = strconv.ParseFloat(environment["ANOMALY_DETECTION_THRESHOLD"], 64)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse anomaly detection threshold: %w", err)
+	}
+
+	d.MinimalHistory, err = strconv.Atoi(environment["ANOMALY_DETECTION_MINIMAL_HISTORY"])
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse minimal history for anomaly detection: %w", err)
+	}
+
+	d.MissingReadingsAfter, err = time.ParseDuration(environment["ANOMALY_MISSING_READINGS_AFTER"])
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse duration after which readings are missing: %w", err)
+	}
+
+	return &d, nil
+}
+
+// Detect executes a single detection run.
+// Since multiple instances of the service may run at the same time, the run
+// is skipped if another instance currently executes a detection run
+func (d AnomalyDetection) Detect(ctx context.Context) error {
+	// the detection covers the usages of every tenant
+	tx, err := tenancy.BeginSystemTx(ctx, globals.Db, nil)
+	if err != nil {
+		return fmt.Errorf("unable to start database transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var lockAcquired bool
+	row, err := globals.SqlQueries.QueryRowContext(ctx, tx, "lock-anomaly-detection")
+	if err != nil {
+		return fmt.Errorf("unable to acquire anomaly detection lock: %w", err)
+	}
+	err = row.Scan(&lockAcquired)
+	if err != nil {
+		return fmt.Errorf("unable to acquire anomaly detection lock: %w", err)
+	}
+	if !lockAcquired {
+		log.Info().Msg("anomaly detection already running in another instance")
+		return nil
+	}
+
+	res, err := globals.SqlQueries.ExecContext(ctx, tx, detectionQueries[d.Method], d.Threshold, d.MinimalHistory)
+	if err != nil {
+		return fmt.Errorf("unable to detect deviating usages: %w", err)
+	}
+	deviations, _ := res.RowsAffected()
+
+	res, err = globals.SqlQueries.ExecContext(ctx, tx, "detect-missing-readings", d.MissingReadingsAfter.Seconds())
+	if err != nil {
+		return fmt.Errorf("unable to detect missing readings: %w", err)
+	}
+	missingReadings, _ := res.RowsAffected()
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("unable to commit detected anomalies: %w", err)
+	}
+	log.Info().Int64("deviations", deviations).Int64("missingReadings", missingReadings).Msg("finished anomaly detection")
+	return nil
+}
+
+// Run executes the anomaly detection in the configured interval until the
+// supplied context is canceled
+func (d AnomalyDetection) Run(ctx context.Context) {
+	if d.Interval <= 0 {
+		log.Warn().Msg("anomaly detection disabled")
+		return
+	}
+	ticker := time.NewTicker(d.Interval)
+	defer ticker.Stop()
+	for {
+		err := d.Detect(ctx)
+		if err != nil {
+			log.Error().Err(err).Msg("anomaly detection failed")
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/blockloop/scan/v2"
+	"github.com/lib/pq"
+	"github.com/rs/zerolog/log"
+
+	"github.com/wisdom-oss/service-consumers/broker"
+	"github.com/wisdom-oss/service-consumers/globals"
+	"github.com/wisdom-oss/service-consumers/tenancy"
+	"github.com/wisdom-oss/service-consumers/types"
+)
+
+// relayBatchSize contains the maximal number of events that are published in
+// a single relay run
+const relayBatchSize = 100
+
+// OutboxRelay contains the configuration of the job publishing the events
+// written to the outbox to the message broker.
+// Events are marked as published after the broker acknowledged them. If the
+// service stops in between, the events are published again. Therefore, the
+// id of the event is sent as idempotency key with every message
+type OutboxRelay struct {
+	// Interval contains the time between two relay runs
+	Interval time.Duration
+
+	// SubjectPrefix contains the prefix of the subjects the events are
+	// published on. The type of the event is appended to the prefix
+	SubjectPrefix string
+
+	// Publisher contains the connection to the message broker. If no
+	// publisher is set, the relay is disabled
+	Publisher broker.Publisher
+}
+
+// relayedEvent contains an event read from the outbox together with its
+// position in the outbox
+type relayedEvent struct {
+	Position int64 `db:"id"`
+	types.OutboxEvent
+}
+
+// OutboxRelayFromEnvironment reads the configuration of the outbox relay from
+// the supplied environment and connects to the configured message broker
+func OutboxRelayFromEnvironment(environment map[string]string) (*OutboxRelay, error) {
+	var relay OutboxRelay
+	var err error
+
+	relay.Interval, err = time.ParseDuration(environment["OUTBOX_RELAY_INTERVAL"])
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse outbox relay interval: %w", err)
+	}
+
+	relay.SubjectPrefix = strings.TrimSuffix(environment["MESSAGE_BROKER_SUBJECT_PREFIX"], ".")
+
+	brokerURL := strings.TrimSpace(environment["MESSAGE_BROKER_URL"])
+	if brokerURL == "" {
+		return &relay, nil
+	}
+	relay.Publisher, err = broker.NewPublisher(brokerURL)
+	if err != nil {
+		return nil, fmt.Errorf("unable to connect to message broker: %w", err)
+	}
+	return &relay, nil
+}
+
+// Relay publishes the unpublished events in the order they have been written
+// to the outbox. The relay stops at the first event the broker rejects to
+// keep the order of the events. The events of every tenant are relayed
+func (relay OutboxRelay) Relay(ctx context.Context) (int, error) {
+	tx, err := tenancy.BeginSystemTx(ctx, globals.Db, nil)
+	if err != nil {
+		return 0, fmt.Errorf("unable to start database transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := globals.SqlQueries.QueryContext(ctx, tx, "lock-unpublished-outbox-events", relayBatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("unable to lock unpublished outbox events: %w", err)
+	}
+	var events []relayedEvent
+	err = scan.Rows(&events, rows)
+	if err != nil {
+		return 0, fmt.Errorf("unable to scan unpublished outbox events: %w", err)
+	}
+
+	published, publishErr := relay.publish(ctx, events)
+	if len(published) > 0 {
+		_, err = globals.SqlQueries.ExecContext(ctx, tx, "mark-outbox-events-published", pq.Array(published))
+		if err != nil {
+			return 0, fmt.Errorf("unable to mark outbox events as published: %w", err)
+		}
+		err = tx.Commit()
+		if err != nil {
+			return 0, fmt.Errorf("unable to commit published outbox events: %w", err)
+		}
+	}
+	return len(published), publishErr
+}
+
+// publish sends the events to the broker in the supplied order and returns
+// the positions of the published events. Publishing stops at the first event
+// that cannot be published
+func (relay OutboxRelay) publish(ctx context.Context, events []relayedEvent) ([]int64, error) {
+	var published []int64
+	for _, event := range events {
+		data, err := json.Marshal(event.OutboxEvent)
+		if err != nil {
+			return published, fmt.Errorf("unable to encode outbox event: %w", err)
+		}
+		err = relay.Publisher.Publish(ctx, broker.Message{
+			ID:      event.ID.String(),
+			Subject: relay.SubjectPrefix + "." + event.Type,
+			Data:    data,
+		})
+		if err != nil {
+			return published, fmt.Errorf("unable to publish outbox event %s: %w", event.ID, err)
+		}
+		published = append(published, event.Position)
+	}
+	return published, nil
+}
+
+// Run executes the relay in the configured interval until the supplied
+// context is canceled. The connection to the broker is closed afterward
+func (relay OutboxRelay) Run(ctx context.Context) {
+	if relay.Publisher == nil || relay.Interval <= 0 {
+		log.Warn().Msg("outbox relay disabled")
+		return
+	}
+	defer relay.Publisher.Close()
+
+	ticker := time.NewTicker(relay.Interval)
+	defer ticker.Stop()
+	for {
+		published, err := relay.Relay(ctx)
+		if err != nil {
+			log.Error().Err(err).Msg("outbox relay failed")
+		}
+		if published > 0 {
+			log.Info().Int("events", published).Msg("published outbox events")
+		}
+		// continue without waiting if the batch was full since more events
+		// may be waiting
+		if err == nil && published == relayBatchSize {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
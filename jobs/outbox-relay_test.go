@@ -0,0 +1,122 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/wisdom-oss/service-consumers/broker"
+	"github.com/wisdom-oss/service-consumers/types"
+)
+
+// errRejected is returned by the rejectingPublisher for the rejected messages
+var errRejected = errors.New("rejected")
+
+// rejectingPublisher wraps the memory publisher and rejects the messages with
+// the configured id
+type rejectingPublisher struct {
+	*broker.MemoryPublisher
+	reject string
+}
+
+func (p rejectingPublisher) Publish(ctx context.Context, message broker.Message) error {
+	if message.ID == p.reject {
+		return errRejected
+	}
+	return p.MemoryPublisher.Publish(ctx, message)
+}
+
+func outboxEvent(position int64, eventType string) relayedEvent {
+	return relayedEvent{
+		Position: position,
+		OutboxEvent: types.OutboxEvent{
+			ID:         uuid.New(),
+			Type:       eventType,
+			Tenant:     "default",
+			OccurredAt: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			Payload:    &types.Map{"name": "Consumer"},
+		},
+	}
+}
+
+func TestOutboxRelayPublish(t *testing.T) {
+	events := []relayedEvent{
+		outboxEvent(1, "consumer.created"),
+		outboxEvent(2, "consumer.updated"),
+		outboxEvent(3, "consumer.deleted"),
+	}
+
+	tests := []struct {
+		name      string
+		reject    int
+		published []int64
+		err       error
+	}{
+		{name: "all events are published", reject: -1, published: []int64{1, 2, 3}},
+		{name: "relay stops at the first rejected event", reject: 1, published: []int64{1}, err: errRejected},
+		{name: "no event is published if the first is rejected", reject: 0, published: nil, err: errRejected},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			publisher := rejectingPublisher{MemoryPublisher: broker.NewMemoryPublisher()}
+			if test.reject >= 0 {
+				publisher.reject = events[test.reject].ID.String()
+			}
+			relay := OutboxRelay{SubjectPrefix: "consumers", Publisher: publisher}
+
+			published, err := relay.publish(context.Background(), events)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("expected error %v, got %v", test.err, err)
+			}
+			if !slices.Equal(published, test.published) {
+				t.Fatalf("expected positions %v, got %v", test.published, published)
+			}
+
+			messages := publisher.Messages()
+			if len(messages) != len(test.published) {
+				t.Fatalf("expected %d messages, got %d", len(test.published), len(messages))
+			}
+			for i, message := range messages {
+				event := events[i]
+				if message.ID != event.ID.String() {
+					t.Errorf("expected message id %s, got %s", event.ID, message.ID)
+				}
+				if expected := "consumers." + event.Type; message.Subject != expected {
+					t.Errorf("expected subject %q, got %q", expected, message.Subject)
+				}
+				var decoded types.OutboxEvent
+				err = json.Unmarshal(message.Data, &decoded)
+				if err != nil {
+					t.Fatalf("unable to decode message: %v", err)
+				}
+				if decoded.ID != event.ID || decoded.Type != event.Type || decoded.Tenant != event.Tenant {
+					t.Errorf("expected event %+v, got %+v", event.OutboxEvent, decoded)
+				}
+			}
+		})
+	}
+}
+
+func TestOutboxRelayPublishTwice(t *testing.T) {
+	// events are published again if marking them as published fails. the
+	// receivers detect them using the id of the event
+	events := []relayedEvent{outboxEvent(1, "consumer.created"), outboxEvent(2, "consumer.updated")}
+	publisher := broker.NewMemoryPublisher()
+	relay := OutboxRelay{SubjectPrefix: "consumers", Publisher: publisher}
+
+	for run := 0; run < 2; run++ {
+		_, err := relay.publish(context.Background(), events)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if len(publisher.Messages()) != len(events) {
+		t.Fatalf("expected %d messages, got %d", len(events), len(publisher.Messages()))
+	}
+}
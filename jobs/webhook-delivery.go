@@ -0,0 +1,238 @@
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/blockloop/scan/v2"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+
+	"github.com/wisdom-oss/service-consumers/globals"
+	"github.com/wisdom-oss/service-consumers/tenancy"
+	"github.com/wisdom-oss/service-consumers/types"
+)
+
+// deliveryBatchSize contains the maximal number of deliveries that are
+// claimed in a single delivery run
+const deliveryBatchSize = 50
+
+// maximalRetryBackoff contains the maximal time between two delivery
+// attempts
+const maximalRetryBackoff = 24 * time.Hour
+
+// WebhookDelivery contains the configuration of the job delivering the events
+// written to the outbox to the subscribed webhooks
+type WebhookDelivery struct {
+	// Interval contains the time between two delivery runs. If the interval
+	// is zero, the delivery is disabled
+	Interval time.Duration
+
+	// Timeout contains the time after which a delivery attempt is canceled
+	Timeout time.Duration
+
+	// MaxAttempts contains the number of failed attempts after which a
+	// delivery is moved to the dead letters
+	MaxAttempts int
+
+	// RetryBackoff contains the time between the first and the second
+	// delivery attempt. The time is doubled after every failed attempt
+	RetryBackoff time.Duration
+
+	client *http.Client
+}
+
+// pendingDelivery contains a delivery that has been claimed by a delivery run
+type pendingDelivery struct {
+	ID       uuid.UUID `db:"id"`
+	Attempts int       `db:"attempts"`
+	URL      string    `db:"url"`
+	Secret   string    `db:"secret"`
+	types.OutboxEvent
+}
+
+// WebhookDeliveryFromEnvironment reads the configuration of the webhook
+// delivery from the supplied environment
+func WebhookDeliveryFromEnvironment(environment map[string]string) (*WebhookDelivery, error) {
+	var d WebhookDelivery
+	var err error
+
+	d.Interval, err = time.ParseDuration(environment["WEBHOOK_DELIVERY_INTERVAL"])
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse webhook delivery interval: %w", err)
+	}
+
+	d.Timeout, err = time.ParseDuration(environment["WEBHOOK_DELIVERY_TIMEOUT"])
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse webhook delivery timeout: %w", err)
+	}
+
+	d.MaxAttempts, err = strconv.Atoi(environment["WEBHOOK_MAX_ATTEMPTS"])
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse maximal webhook delivery attempts: %w", err)
+	}
+
+	d.RetryBackoff, err = time.ParseDuration(environment["WEBHOOK_RETRY_BACKOFF"])
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse webhook retry backoff: %w", err)
+	}
+
+	d.client = &http.Client{Timeout: d.Timeout}
+	return &d, nil
+}
+
+// Deliver executes a single delivery run.
+// The due deliveries are claimed for the duration of the run which allows
+// multiple instances of the service to deliver events concurrently. The
+// deliveries of every tenant are executed
+func (d WebhookDelivery) Deliver(ctx context.Context) error {
+	deliveries, err := d.claim(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, delivery := range deliveries {
+		statusCode, deliveryErr := d.send(ctx, delivery)
+
+		status := "delivered"
+		nextAttempt := time.Now()
+		var lastError *string
+		if deliveryErr != nil {
+			errorMessage := deliveryErr.Error()
+			lastError = &errorMessage
+			status = "pending"
+			nextAttempt = nextAttempt.Add(d.backoff(delivery.Attempts + 1))
+			if delivery.Attempts+1 >= d.MaxAttempts {
+				status = "dead"
+			}
+		}
+
+		err = recordAttempt(ctx, delivery.ID, status, statusCode, lastError, nextAttempt)
+		if err != nil {
+			return fmt.Errorf("unable to record webhook delivery attempt: %w", err)
+		}
+	}
+	if len(deliveries) > 0 {
+		log.Info().Int("deliveries", len(deliveries)).Msg("finished webhook delivery")
+	}
+	return nil
+}
+
+// claim leases the due deliveries of every tenant for the duration of a
+// delivery run
+func (d WebhookDelivery) claim(ctx context.Context) ([]pendingDelivery, error) {
+	tx, err := tenancy.BeginSystemTx(ctx, globals.Db, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to start database transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	leaseEnd := time.Now().Add(deliveryBatchSize * d.Timeout)
+	rows, err := globals.SqlQueries.QueryContext(ctx, tx, "claim-webhook-deliveries", deliveryBatchSize, leaseEnd)
+	if err != nil {
+		return nil, fmt.Errorf("unable to claim webhook deliveries: %w", err)
+	}
+	var deliveries []pendingDelivery
+	err = scan.Rows(&deliveries, rows)
+	if err != nil {
+		return nil, fmt.Errorf("unable to scan claimed webhook deliveries: %w", err)
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, fmt.Errorf("unable to commit claimed webhook deliveries: %w", err)
+	}
+	return deliveries, nil
+}
+
+// recordAttempt stores the outcome of a delivery attempt
+func recordAttempt(ctx context.Context, delivery uuid.UUID, status string, statusCode *int, lastError *string, nextAttempt time.Time) error {
+	tx, err := tenancy.BeginSystemTx(ctx, globals.Db, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = globals.SqlQueries.ExecContext(ctx, tx, "record-webhook-delivery-attempt",
+		delivery, status, statusCode, lastError, nextAttempt)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// send posts the event to the webhook. The body is signed using the secret of
+// the webhook and the signature is sent in the X-WISdoM-Signature header
+func (d WebhookDelivery) send(ctx context.Context, delivery pendingDelivery) (statusCode *int, err error) {
+	body, err := json.Marshal(delivery.OutboxEvent)
+	if err != nil {
+		return nil, err
+	}
+
+	signature := hmac.New(sha256.New, []byte(delivery.Secret))
+	signature.Write(body)
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("X-WISdoM-Event", delivery.Type)
+	request.Header.Set("X-WISdoM-Delivery", delivery.ID.String())
+	request.Header.Set("X-WISdoM-Signature", "sha256="+hex.EncodeToString(signature.Sum(nil)))
+
+	response, err := d.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	_, _ = io.Copy(io.Discard, response.Body)
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return &response.StatusCode, fmt.Errorf("webhook responded with status %d", response.StatusCode)
+	}
+	return &response.StatusCode, nil
+}
+
+// backoff calculates the time until the next attempt after the supplied
+// number of failed attempts
+func (d WebhookDelivery) backoff(failedAttempts int) time.Duration {
+	backoff := d.RetryBackoff
+	for attempt := 1; attempt < failedAttempts && backoff < maximalRetryBackoff; attempt++ {
+		backoff *= 2
+	}
+	if backoff > maximalRetryBackoff {
+		return maximalRetryBackoff
+	}
+	return backoff
+}
+
+// Run executes the webhook delivery in the configured interval until the
+// supplied context is canceled
+func (d WebhookDelivery) Run(ctx context.Context) {
+	if d.Interval <= 0 {
+		log.Warn().Msg("webhook delivery disabled")
+		return
+	}
+	ticker := time.NewTicker(d.Interval)
+	defer ticker.Stop()
+	for {
+		err := d.Deliver(ctx)
+		if err != nil {
+			log.Error().Err(err).Msg("webhook delivery failed")
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
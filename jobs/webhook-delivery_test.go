@@ -0,0 +1,79 @@
+package jobs
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestWebhookDeliveryBackoff(t *testing.T) {
+	d := WebhookDelivery{RetryBackoff: time.Minute}
+	tests := []struct {
+		failedAttempts int
+		expected       time.Duration
+	}{
+		{failedAttempts: 1, expected: time.Minute},
+		{failedAttempts: 2, expected: 2 * time.Minute},
+		{failedAttempts: 3, expected: 4 * time.Minute},
+		{failedAttempts: 100, expected: maximalRetryBackoff},
+	}
+
+	for _, test := range tests {
+		backoff := d.backoff(test.failedAttempts)
+		if backoff != test.expected {
+			t.Errorf("expected backoff %s after %d failed attempts, got %s", test.expected, test.failedAttempts, backoff)
+		}
+	}
+}
+
+func TestWebhookDeliverySend(t *testing.T) {
+	tests := []struct {
+		name       string
+		statusCode int
+		fails      bool
+	}{
+		{name: "accepted delivery", statusCode: http.StatusNoContent},
+		{name: "rejected delivery", statusCode: http.StatusInternalServerError, fails: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			delivery := pendingDelivery{ID: uuid.New(), Secret: "secret", OutboxEvent: outboxEvent(1, "consumer.created").OutboxEvent}
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				signature := hmac.New(sha256.New, []byte(delivery.Secret))
+				signature.Write(body)
+				if r.Header.Get("X-WISdoM-Signature") != "sha256="+hex.EncodeToString(signature.Sum(nil)) {
+					t.Error("invalid signature")
+				}
+				if r.Header.Get("X-WISdoM-Event") != delivery.Type {
+					t.Errorf("expected event %q, got %q", delivery.Type, r.Header.Get("X-WISdoM-Event"))
+				}
+				if r.Header.Get("X-WISdoM-Delivery") != delivery.ID.String() {
+					t.Errorf("expected delivery %s, got %q", delivery.ID, r.Header.Get("X-WISdoM-Delivery"))
+				}
+				w.WriteHeader(test.statusCode)
+			}))
+			defer server.Close()
+			delivery.URL = server.URL
+
+			d := WebhookDelivery{client: server.Client()}
+			statusCode, err := d.send(context.Background(), delivery)
+			if (err != nil) != test.fails {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if statusCode == nil || *statusCode != test.statusCode {
+				t.Fatalf("expected status code %d, got %v", test.statusCode, statusCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package limits
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/wisdom-oss/service-consumers/globals"
+)
+
+// MaxBodySize limits the size of the request body to the supplied number of
+// bytes. Requests announcing a larger body are rejected with 413 Request
+// Entity Too Large right away. Other requests fail while reading the body
+// after exceeding the limit
+func MaxBodySize(limit int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > limit {
+				_ = globals.Errors["REQUEST_BODY_TOO_LARGE"].Send(w)
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, limit)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// BodySizeFromEnvironment reads the body size limit stored in the supplied
+// environment variable
+func BodySizeFromEnvironment(environment map[string]string, variable string) (int64, error) {
+	limit, err := strconv.ParseInt(environment[variable], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("unable to parse body size limit %s: %w", variable, err)
+	}
+	if limit < 1 {
+		return 0, fmt.Errorf("the body size limit %s needs to be positive", variable)
+	}
+	return limit, nil
+}
@@ -0,0 +1,67 @@
+package limits
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMaxBodySize(t *testing.T) {
+	// the handler reads the whole body like the routes decoding it
+	readingHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+
+	tests := []struct {
+		name          string
+		body          string
+		contentLength int64
+		expected      int
+	}{
+		{name: "body within the limit", body: "1234", contentLength: 4, expected: http.StatusOK},
+		{name: "announced body exceeding the limit", body: "12345678", contentLength: 9, expected: http.StatusRequestEntityTooLarge},
+		{name: "body exceeding the limit without length", body: "123456789", contentLength: -1, expected: http.StatusRequestEntityTooLarge},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(test.body))
+			r.ContentLength = test.contentLength
+			w := httptest.NewRecorder()
+			MaxBodySize(8)(readingHandler).ServeHTTP(w, r)
+			if w.Code != test.expected {
+				t.Fatalf("expected status %d, got %d", test.expected, w.Code)
+			}
+		})
+	}
+}
+
+func TestBodySizeFromEnvironment(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected int64
+		fails    bool
+	}{
+		{value: "1048576", expected: 1048576},
+		{value: "0", fails: true},
+		{value: "-1", fails: true},
+		{value: "1MiB", fails: true},
+	}
+
+	for _, test := range tests {
+		limit, err := BodySizeFromEnvironment(map[string]string{"MAX_BODY_SIZE": test.value}, "MAX_BODY_SIZE")
+		if (err != nil) != test.fails {
+			t.Errorf("value %q: unexpected error: %v", test.value, err)
+		}
+		if limit != test.expected {
+			t.Errorf("value %q: expected limit %d, got %d", test.value, test.expected, limit)
+		}
+	}
+}
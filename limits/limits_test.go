@@ -0,0 +1,28 @@
+package limits
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	wisdomType "github.com/wisdom-oss/commonTypes"
+
+	"github.com/wisdom-oss/service-consumers/globals"
+)
+
+// TestMain loads the predefined errors sent by the middlewares
+func TestMain(m *testing.M) {
+	content, err := os.ReadFile("../resources/errors.json")
+	if err != nil {
+		panic(err)
+	}
+	var errors []wisdomType.WISdoMError
+	err = json.Unmarshal(content, &errors)
+	if err != nil {
+		panic(err)
+	}
+	for _, e := range errors {
+		globals.Errors[e.ErrorCode] = e
+	}
+	os.Exit(m.Run())
+}
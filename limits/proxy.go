@@ -0,0 +1,104 @@
+package limits
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// TrustedProxiesFromEnvironment reads the networks of the proxies which are
+// trusted to forward the address of the client from the supplied
+// environment. The networks are separated by commas and may either be
+// written in the CIDR notation or as single addresses
+func TrustedProxiesFromEnvironment(environment map[string]string) ([]netip.Prefix, error) {
+	var trustedProxies []netip.Prefix
+	for _, network := range strings.Split(environment["TRUSTED_PROXIES"], ",") {
+		network = strings.TrimSpace(network)
+		if network == "" {
+			continue
+		}
+		if !strings.Contains(network, "/") {
+			address, err := netip.ParseAddr(network)
+			if err != nil {
+				return nil, fmt.Errorf("unable to parse trusted proxy %s: %w", network, err)
+			}
+			trustedProxies = append(trustedProxies, netip.PrefixFrom(address, address.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(network)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse trusted proxy network %s: %w", network, err)
+		}
+		trustedProxies = append(trustedProxies, prefix.Masked())
+	}
+	return trustedProxies, nil
+}
+
+// RealIP replaces the remote address of requests sent by a trusted proxy with
+// the address of the client forwarded in the X-Forwarded-For or X-Real-IP
+// header. The headers of requests sent by other peers are ignored since the
+// clients could otherwise choose the address used for limiting their
+// requests.
+// The X-Forwarded-For header is read from the right, skipping the trusted
+// proxies, since the entries left of the last trusted proxy may have been
+// set by the client
+func RealIP(trustedProxies []netip.Prefix) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if client, forwarded := forwardedClient(r, trustedProxies); forwarded {
+				r.RemoteAddr = client.String()
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// forwardedClient returns the address of the client forwarded by a trusted
+// proxy. If the request has not been sent by a trusted proxy or no valid
+// address has been forwarded, false is returned
+func forwardedClient(r *http.Request, trustedProxies []netip.Prefix) (netip.Addr, bool) {
+	peer, valid := parseAddress(r.RemoteAddr)
+	if !valid || !trusted(peer, trustedProxies) {
+		return netip.Addr{}, false
+	}
+
+	if header := r.Header.Get("X-Forwarded-For"); header != "" {
+		hops := strings.Split(header, ",")
+		for idx := len(hops) - 1; idx >= 0; idx-- {
+			hop, valid := parseAddress(strings.TrimSpace(hops[idx]))
+			if !valid {
+				// the entries left of an invalid entry cannot be attributed
+				// to any proxy
+				return netip.Addr{}, false
+			}
+			if !trusted(hop, trustedProxies) || idx == 0 {
+				return hop, true
+			}
+		}
+	}
+	return parseAddress(strings.TrimSpace(r.Header.Get("X-Real-IP")))
+}
+
+// parseAddress parses an address which may contain a port
+func parseAddress(address string) (netip.Addr, bool) {
+	if host, _, err := net.SplitHostPort(address); err == nil {
+		address = host
+	}
+	parsed, err := netip.ParseAddr(address)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return parsed.Unmap(), true
+}
+
+// trusted checks if the address belongs to a trusted proxy
+func trusted(address netip.Addr, trustedProxies []netip.Prefix) bool {
+	for _, network := range trustedProxies {
+		if network.Contains(address) {
+			return true
+		}
+	}
+	return false
+}
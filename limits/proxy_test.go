@@ -0,0 +1,104 @@
+package limits
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTrustedProxiesFromEnvironment(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected []string
+		fails    bool
+	}{
+		{value: "", expected: nil},
+		{value: "10.0.0.0/8, 192.0.2.1", expected: []string{"10.0.0.0/8", "192.0.2.1/32"}},
+		{value: "10.1.2.3/8,2001:db8::1", expected: []string{"10.0.0.0/8", "2001:db8::1/128"}},
+		{value: "gateway", fails: true},
+		{value: "10.0.0.0/33", fails: true},
+	}
+
+	for _, test := range tests {
+		trustedProxies, err := TrustedProxiesFromEnvironment(map[string]string{"TRUSTED_PROXIES": test.value})
+		if (err != nil) != test.fails {
+			t.Errorf("value %q: unexpected error: %v", test.value, err)
+			continue
+		}
+		if len(trustedProxies) != len(test.expected) {
+			t.Errorf("value %q: expected %v, got %v", test.value, test.expected, trustedProxies)
+			continue
+		}
+		for idx, network := range trustedProxies {
+			if network.String() != test.expected[idx] {
+				t.Errorf("value %q: expected %v, got %v", test.value, test.expected, trustedProxies)
+			}
+		}
+	}
+}
+
+func TestRealIP(t *testing.T) {
+	trustedProxies, err := TrustedProxiesFromEnvironment(map[string]string{"TRUSTED_PROXIES": "10.0.0.0/8"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		remoteAddr   string
+		forwardedFor string
+		realIP       string
+		expectedAddr string
+	}{
+		{name: "direct client", remoteAddr: "192.0.2.1:1000", expectedAddr: "192.0.2.1:1000"},
+		{name: "direct client forging headers", remoteAddr: "192.0.2.1:1000", forwardedFor: "198.51.100.1", realIP: "198.51.100.2", expectedAddr: "192.0.2.1:1000"},
+		{name: "trusted proxy", remoteAddr: "10.0.0.1:1000", forwardedFor: "192.0.2.1", expectedAddr: "192.0.2.1"},
+		{name: "trusted proxy chain", remoteAddr: "10.0.0.1:1000", forwardedFor: "192.0.2.1, 10.0.0.2", expectedAddr: "192.0.2.1"},
+		{name: "client prepending addresses", remoteAddr: "10.0.0.1:1000", forwardedFor: "198.51.100.1, 192.0.2.1", expectedAddr: "192.0.2.1"},
+		{name: "only trusted proxies", remoteAddr: "10.0.0.1:1000", forwardedFor: "10.0.0.3, 10.0.0.2", expectedAddr: "10.0.0.3"},
+		{name: "invalid forwarded address", remoteAddr: "10.0.0.1:1000", forwardedFor: "unknown", expectedAddr: "10.0.0.1:1000"},
+		{name: "real ip of trusted proxy", remoteAddr: "10.0.0.1:1000", realIP: "192.0.2.1", expectedAddr: "192.0.2.1"},
+		{name: "trusted proxy without headers", remoteAddr: "10.0.0.1:1000", expectedAddr: "10.0.0.1:1000"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = test.remoteAddr
+			if test.forwardedFor != "" {
+				r.Header.Set("X-Forwarded-For", test.forwardedFor)
+			}
+			if test.realIP != "" {
+				r.Header.Set("X-Real-IP", test.realIP)
+			}
+
+			var remoteAddr string
+			RealIP(trustedProxies)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				remoteAddr = r.RemoteAddr
+			})).ServeHTTP(httptest.NewRecorder(), r)
+			if remoteAddr != test.expectedAddr {
+				t.Fatalf("expected remote address %q, got %q", test.expectedAddr, remoteAddr)
+			}
+		})
+	}
+}
+
+func TestAddressRateLimiterIgnoresForgedHeaders(t *testing.T) {
+	limiter, err := AddressRateLimiterFromEnvironment(testAddressEnvironment(1))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	handler := RealIP(nil)(limiter.Middleware(okHandler))
+
+	expected := []int{http.StatusOK, http.StatusTooManyRequests}
+	for i, forwardedFor := range []string{"198.51.100.1", "198.51.100.2"} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = "192.0.2.1:1000"
+		r.Header.Set("X-Forwarded-For", forwardedFor)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != expected[i] {
+			t.Fatalf("request %d: expected status %d, got %d", i, expected[i], w.Code)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+// Package limits protects the service from misbehaving clients by limiting
+// the rate of the requests each client may send and the size of the request
+// bodies.
+package limits
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/wisdom-oss/service-consumers/auth"
+	"github.com/wisdom-oss/service-consumers/globals"
+)
+
+// idleClientTimeout contains the duration after which the bucket of a client
+// that did not send any requests is removed
+const idleClientTimeout = 10 * time.Minute
+
+// client contains the token bucket of a single client
+type client struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// RateLimiter limits the rate of the requests sent by every client using a
+// token bucket per client
+type RateLimiter struct {
+	// Rate contains the number of requests a client may send per second
+	Rate rate.Limit
+
+	// Burst contains the number of requests a client may send at once
+	Burst int
+
+	// key identifies the client sending a request
+	key func(r *http.Request) string
+
+	mutex     sync.Mutex
+	clients   map[string]*client
+	lastSweep time.Time
+}
+
+// RateLimiterFromEnvironment reads the configuration of the rate limiting
+// per user from the supplied environment. Clients are identified by their
+// user or api key and by their address if they are anonymous, which requires
+// the limiter to be applied after authenticating the requests.
+// If the rate is set to zero, the rate limiting is disabled and nil is
+// returned
+func RateLimiterFromEnvironment(environment map[string]string) (*RateLimiter, error) {
+	return rateLimiterFromEnvironment(environment, "RATE_LIMIT_REQUESTS_PER_SECOND", "RATE_LIMIT_BURST", clientKey)
+}
+
+// AddressRateLimiterFromEnvironment reads the configuration of the rate
+// limiting per address from the supplied environment. Clients are identified
+// by their address only, which allows applying the limiter before
+// authenticating the requests to limit the attempts of guessing credentials.
+// If the rate is set to zero, the rate limiting is disabled and nil is
+// returned
+func AddressRateLimiterFromEnvironment(environment map[string]string) (*RateLimiter, error) {
+	return rateLimiterFromEnvironment(environment, "RATE_LIMIT_ADDRESS_REQUESTS_PER_SECOND", "RATE_LIMIT_ADDRESS_BURST", addressKey)
+}
+
+// rateLimiterFromEnvironment reads the rate and the burst of a rate limiter
+// from the supplied environment variables
+func rateLimiterFromEnvironment(environment map[string]string, rateVariable, burstVariable string, key func(r *http.Request) string) (*RateLimiter, error) {
+	requestsPerSecond, err := strconv.ParseFloat(environment[rateVariable], 64)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse rate limit %s: %w", rateVariable, err)
+	}
+	if requestsPerSecond <= 0 {
+		return nil, nil
+	}
+
+	burst, err := strconv.Atoi(environment[burstVariable])
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse rate limit burst %s: %w", burstVariable, err)
+	}
+	if burst < 1 {
+		return nil, fmt.Errorf("the rate limit burst %s needs to be at least 1", burstVariable)
+	}
+
+	return &RateLimiter{
+		Rate:    rate.Limit(requestsPerSecond),
+		Burst:   burst,
+		key:     key,
+		clients: make(map[string]*client),
+	}, nil
+}
+
+// Middleware rejects the requests of clients that exceeded their rate limit
+// with 429 Too Many Requests and the number of seconds after which the client
+// may retry. The state of the bucket of the client is returned in the
+// RateLimit headers of every response
+func (l *RateLimiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		now := time.Now()
+		limiter := l.limiter(l.key(r), now)
+
+		reservation := limiter.ReserveN(now, 1)
+		delay := reservation.DelayFrom(now)
+		if delay > 0 {
+			// since the request is rejected, the token is given back
+			reservation.CancelAt(now)
+		}
+
+		// the reset contains the number of seconds until the bucket is full
+		tokens := math.Max(0, limiter.TokensAt(now))
+		reset := math.Ceil((float64(l.Burst) - tokens) / float64(l.Rate))
+		w.Header().Set("RateLimit-Limit", strconv.Itoa(l.Burst))
+		w.Header().Set("RateLimit-Remaining", strconv.FormatFloat(math.Floor(tokens), 'f', 0, 64))
+		w.Header().Set("RateLimit-Reset", strconv.FormatFloat(reset, 'f', 0, 64))
+
+		if delay > 0 {
+			w.Header().Set("Retry-After", strconv.FormatFloat(math.Ceil(delay.Seconds()), 'f', 0, 64))
+			_ = globals.Errors["RATE_LIMIT_EXCEEDED"].Send(w)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// limiter returns the token bucket of the client and creates it if the client
+// is unknown. The buckets of idle clients are removed periodically
+func (l *RateLimiter) limiter(key string, now time.Time) *rate.Limiter {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if now.Sub(l.lastSweep) > idleClientTimeout {
+		for clientKey, c := range l.clients {
+			if now.Sub(c.lastSeen) > idleClientTimeout {
+				delete(l.clients, clientKey)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	c, known := l.clients[key]
+	if !known {
+		c = &client{limiter: rate.NewLimiter(l.Rate, l.Burst)}
+		l.clients[key] = c
+	}
+	c.lastSeen = now
+	return c.limiter
+}
+
+// clientKey identifies the client sending the request. Users and api keys are
+// identified by their name within their tenant. Anonymous clients are
+// identified by their address
+func clientKey(r *http.Request) string {
+	principal := auth.PrincipalFromContext(r.Context())
+	if principal.User != "" {
+		return "user:" + principal.Tenant + "/" + principal.User
+	}
+	return addressKey(r)
+}
+
+// addressKey identifies the client sending the request by its address
+func addressKey(r *http.Request) string {
+	address, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		address = r.RemoteAddr
+	}
+	return "address:" + address
+}
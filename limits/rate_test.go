@@ -0,0 +1,175 @@
+package limits
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/wisdom-oss/service-consumers/auth"
+)
+
+var okHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+})
+
+func TestRateLimiterFromEnvironment(t *testing.T) {
+	tests := []struct {
+		name     string
+		rate     string
+		burst    string
+		disabled bool
+		fails    bool
+	}{
+		{name: "enabled", rate: "10", burst: "20"},
+		{name: "fractional rate", rate: "0.5", burst: "1"},
+		{name: "disabled", rate: "0", burst: "20", disabled: true},
+		{name: "invalid rate", rate: "many", burst: "20", fails: true},
+		{name: "invalid burst", rate: "10", burst: "many", fails: true},
+		{name: "empty bucket", rate: "10", burst: "0", fails: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			limiter, err := RateLimiterFromEnvironment(map[string]string{
+				"RATE_LIMIT_REQUESTS_PER_SECOND": test.rate,
+				"RATE_LIMIT_BURST":               test.burst,
+			})
+			if (err != nil) != test.fails {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !test.fails && (limiter == nil) != test.disabled {
+				t.Fatalf("expected disabled to be %v", test.disabled)
+			}
+		})
+	}
+}
+
+func TestRateLimiterMiddleware(t *testing.T) {
+	type request struct {
+		principal auth.Principal
+		address   string
+	}
+	alice := auth.Principal{User: "alice", Tenant: "a"}
+	bob := auth.Principal{User: "bob", Tenant: "a"}
+	aliceOfOtherTenant := auth.Principal{User: "alice", Tenant: "b"}
+
+	tests := []struct {
+		name     string
+		limiter  func() (*RateLimiter, error)
+		requests []request
+		expected []int
+	}{
+		{
+			name:     "bucket of a user is exhausted",
+			limiter:  func() (*RateLimiter, error) { return RateLimiterFromEnvironment(testEnvironment(2)) },
+			requests: []request{{principal: alice}, {principal: alice}, {principal: alice}},
+			expected: []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests},
+		},
+		{
+			name:     "users have separate buckets",
+			limiter:  func() (*RateLimiter, error) { return RateLimiterFromEnvironment(testEnvironment(1)) },
+			requests: []request{{principal: alice}, {principal: bob}, {principal: aliceOfOtherTenant}, {principal: alice}},
+			expected: []int{http.StatusOK, http.StatusOK, http.StatusOK, http.StatusTooManyRequests},
+		},
+		{
+			name:     "anonymous clients are identified by their address",
+			limiter:  func() (*RateLimiter, error) { return RateLimiterFromEnvironment(testEnvironment(1)) },
+			requests: []request{{address: "192.0.2.1:1000"}, {address: "192.0.2.1:2000"}, {address: "192.0.2.2:1000"}},
+			expected: []int{http.StatusOK, http.StatusTooManyRequests, http.StatusOK},
+		},
+		{
+			name:     "address limiter ignores the users",
+			limiter:  func() (*RateLimiter, error) { return AddressRateLimiterFromEnvironment(testAddressEnvironment(1)) },
+			requests: []request{{principal: alice, address: "192.0.2.1:1000"}, {principal: bob, address: "192.0.2.1:1000"}},
+			expected: []int{http.StatusOK, http.StatusTooManyRequests},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			limiter, err := test.limiter()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			handler := limiter.Middleware(okHandler)
+
+			for i, req := range test.requests {
+				r := httptest.NewRequest(http.MethodGet, "/", nil)
+				if req.address != "" {
+					r.RemoteAddr = req.address
+				}
+				r = r.WithContext(auth.WithPrincipal(r.Context(), req.principal))
+				w := httptest.NewRecorder()
+				handler.ServeHTTP(w, r)
+
+				if w.Code != test.expected[i] {
+					t.Fatalf("request %d: expected status %d, got %d", i, test.expected[i], w.Code)
+				}
+				if w.Header().Get("RateLimit-Limit") == "" || w.Header().Get("RateLimit-Remaining") == "" || w.Header().Get("RateLimit-Reset") == "" {
+					t.Errorf("request %d: missing rate limit headers", i)
+				}
+				if w.Code == http.StatusTooManyRequests && w.Header().Get("Retry-After") == "" {
+					t.Errorf("request %d: missing Retry-After header", i)
+				}
+			}
+		})
+	}
+}
+
+func TestRateLimiterHeaders(t *testing.T) {
+	limiter, err := RateLimiterFromEnvironment(testEnvironment(3))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	handler := limiter.Middleware(okHandler)
+
+	expectedRemaining := []string{"2", "1", "0", "0"}
+	for i, remaining := range expectedRemaining {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		if w.Header().Get("RateLimit-Limit") != "3" {
+			t.Errorf("request %d: expected limit 3, got %s", i, w.Header().Get("RateLimit-Limit"))
+		}
+		if w.Header().Get("RateLimit-Remaining") != remaining {
+			t.Errorf("request %d: expected %s remaining requests, got %s", i, remaining, w.Header().Get("RateLimit-Remaining"))
+		}
+	}
+}
+
+func TestRateLimiterSweep(t *testing.T) {
+	limiter, err := RateLimiterFromEnvironment(testEnvironment(1))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	now := time.Now()
+	limiter.limiter("idle", now)
+	limiter.limiter("active", now.Add(idleClientTimeout))
+	limiter.limiter("active", now.Add(2*idleClientTimeout))
+
+	if _, known := limiter.clients["idle"]; known {
+		t.Error("expected the bucket of the idle client to be removed")
+	}
+	if _, known := limiter.clients["active"]; !known {
+		t.Error("expected the bucket of the active client to be kept")
+	}
+}
+
+// testEnvironment returns the environment of a per user rate limiter that
+// refills slowly enough to not affect the tests
+func testEnvironment(burst int) map[string]string {
+	return map[string]string{
+		"RATE_LIMIT_REQUESTS_PER_SECOND": "0.001",
+		"RATE_LIMIT_BURST":               strconv.Itoa(burst),
+	}
+}
+
+// testAddressEnvironment returns the environment of an address based rate
+// limiter that refills slowly enough to not affect the tests
+func testAddressEnvironment(burst int) map[string]string {
+	return map[string]string{
+		"RATE_LIMIT_ADDRESS_REQUESTS_PER_SECOND": "0.001",
+		"RATE_LIMIT_ADDRESS_BURST":               strconv.Itoa(burst),
+	}
+}
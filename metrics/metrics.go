@@ -0,0 +1,141 @@
+// Package metrics collects the metrics of the service and exposes them in
+// the Prometheus exposition format.
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	chiMiddleware "github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// namespace contains the namespace of the metrics collected by the service
+const namespace = "consumers"
+
+// registry contains the metrics exposed by the service
+var registry = prometheus.NewRegistry()
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "Number of handled http requests by route pattern, method and status",
+	}, []string{"route", "method", "status"})
+
+	httpRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Duration of the handled http requests by route pattern, method and status",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+
+	queryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "database_query_duration_seconds",
+		Help:      "Duration of the executed database queries by query name and outcome",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"query", "outcome"})
+
+	listedConsumers = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "listed_consumers",
+		Help:      "Number of consumers returned by a single list request",
+		Buckets:   prometheus.ExponentialBuckets(1, 4, 10),
+	})
+
+	usageImports = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "usage_imports_total",
+		Help:      "Number of imports of usage records by outcome",
+	}, []string{"outcome"})
+
+	importedUsageRecords = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "imported_usage_records_total",
+		Help:      "Number of usage records created by imports",
+	})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests,
+		httpRequestDuration,
+		queryDuration,
+		listedConsumers,
+		usageImports,
+		importedUsageRecords,
+	)
+}
+
+// Handler returns the handler exposing the collected metrics
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{Registry: registry})
+}
+
+// RegisterDatabase collects the statistics of the connection pool of the
+// database
+func RegisterDatabase(db *sql.DB) error {
+	return registry.Register(collectors.NewDBStatsCollector(db, namespace))
+}
+
+// Middleware counts the handled requests and measures their durations. The
+// requests are labeled using the pattern of the route that handled them to
+// keep the number of label values bounded
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := chiMiddleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r)
+
+		route := "unmatched"
+		if routeContext := chi.RouteContext(r.Context()); routeContext != nil && routeContext.RoutePattern() != "" {
+			route = routeContext.RoutePattern()
+		}
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		labels := prometheus.Labels{"route": route, "method": r.Method, "status": strconv.Itoa(status)}
+		httpRequests.With(labels).Inc()
+		httpRequestDuration.With(labels).Observe(time.Since(start).Seconds())
+	})
+}
+
+// ObserveQuery measures the duration of a database query. It is registered as
+// observer of the database driver
+func ObserveQuery(_ context.Context, queryName string) func(error) {
+	start := time.Now()
+	return func(err error) {
+		outcome := "success"
+		if err != nil {
+			outcome = "error"
+		}
+		queryDuration.WithLabelValues(queryName, outcome).Observe(time.Since(start).Seconds())
+	}
+}
+
+// ObserveListedConsumers records the number of consumers returned by a list
+// request
+func ObserveListedConsumers(count int) {
+	listedConsumers.Observe(float64(count))
+}
+
+// CountUsageImport records the outcome of an import of usage records and the
+// number of created usage records
+func CountUsageImport(records int, succeeded bool) {
+	if !succeeded {
+		usageImports.WithLabelValues("failed").Inc()
+		return
+	}
+	usageImports.WithLabelValues("succeeded").Inc()
+	importedUsageRecords.Add(float64(records))
+}
@@ -0,0 +1,229 @@
+// Package observedsql registers a database driver wrapping the PostgreSQL
+// driver. The driver reports every executed statement to the registered
+// observers, which allows measuring the statements without changing the code
+// executing them. The statements are identified by the name of the query they
+// have been loaded from.
+package observedsql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"strings"
+	"sync"
+
+	"github.com/lib/pq"
+)
+
+// DriverName contains the name the driver is registered under
+const DriverName = "postgres-observed"
+
+// unnamedQuery is reported for statements that do not originate from a named
+// query
+const unnamedQuery = "unnamed"
+
+// Observer is notified before a statement is executed. The returned function
+// is called with the result of the statement after it has been executed
+type Observer func(ctx context.Context, queryName string) func(err error)
+
+var (
+	mutex         sync.RWMutex
+	observers     []Observer
+	queryTexts    map[string]string
+	resolvedNames sync.Map
+)
+
+func init() {
+	sql.Register(DriverName, observedDriver{})
+}
+
+// Observe registers a new observer. Observers should be registered before
+// the first statement is executed
+func Observe(observer Observer) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	observers = append(observers, observer)
+}
+
+// SetQueries sets the named queries used for identifying the statements. The
+// queries are expected to map the names of the queries to their texts
+func SetQueries(queries map[string]string) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	queryTexts = make(map[string]string, len(queries))
+	for name, query := range queries {
+		queryTexts[name] = normalizeQuery(query)
+	}
+	resolvedNames = sync.Map{}
+}
+
+// queryName resolves the name of the query the statement originates from.
+// Since statements may be built by extending a named query with filters, the
+// name of the longest query the statement starts with is used
+func queryName(statement string) string {
+	if name, resolved := resolvedNames.Load(statement); resolved {
+		return name.(string)
+	}
+
+	mutex.RLock()
+	defer mutex.RUnlock()
+	normalizedStatement := normalizeQuery(statement)
+	name, matchedLength := unnamedQuery, 0
+	for queryName, query := range queryTexts {
+		if len(query) > matchedLength && strings.HasPrefix(normalizedStatement, query) {
+			name, matchedLength = queryName, len(query)
+		}
+	}
+	resolvedNames.Store(statement, name)
+	return name
+}
+
+// normalizeQuery removes the whitespace and the terminating semicolon of the
+// query
+func normalizeQuery(query string) string {
+	return strings.TrimRight(strings.Join(strings.Fields(query), " "), "; ")
+}
+
+// observe notifies the observers about the statement and returns the function
+// that notifies them about the result
+func observe(ctx context.Context, statement string) func(error) {
+	mutex.RLock()
+	currentObservers := observers
+	mutex.RUnlock()
+	if len(currentObservers) == 0 {
+		return func(error) {}
+	}
+
+	name := queryName(statement)
+	finishers := make([]func(error), 0, len(currentObservers))
+	for _, observer := range currentObservers {
+		finishers = append(finishers, observer(ctx, name))
+	}
+	return func(err error) {
+		for _, finish := range finishers {
+			finish(err)
+		}
+	}
+}
+
+// observedDriver opens the connections using the PostgreSQL driver
+type observedDriver struct{}
+
+func (observedDriver) Open(dsn string) (driver.Conn, error) {
+	c, err := pq.Driver{}.Open(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return &observedConn{pqConn: c.(pqConn)}, nil
+}
+
+// pqConn contains the interfaces implemented by the connections of the
+// PostgreSQL driver
+type pqConn interface {
+	driver.Conn
+	driver.ConnBeginTx
+	driver.ConnPrepareContext
+	driver.ExecerContext
+	driver.QueryerContext
+	driver.Pinger
+	driver.SessionResetter
+	driver.Validator
+}
+
+// observedConn reports the statements executed on the connection
+type observedConn struct {
+	pqConn
+
+	// txContext contains the context the current transaction has been
+	// started with. Statements executed in the transaction without a context
+	// are reported using this context
+	txContext context.Context
+}
+
+// statementContext returns the context the statement is reported with
+func (c *observedConn) statementContext(ctx context.Context) context.Context {
+	if ctx == context.Background() && c.txContext != nil {
+		return c.txContext
+	}
+	return ctx
+}
+
+func (c *observedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	tx, err := c.pqConn.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	c.txContext = ctx
+	return &observedTx{tx, c}, nil
+}
+
+func (c *observedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	finish := observe(c.statementContext(ctx), query)
+	result, err := c.pqConn.ExecContext(ctx, query, args)
+	finish(err)
+	return result, err
+}
+
+func (c *observedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	finish := observe(c.statementContext(ctx), query)
+	rows, err := c.pqConn.QueryContext(ctx, query, args)
+	finish(err)
+	return rows, err
+}
+
+func (c *observedConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *observedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	stmt, err := c.pqConn.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return &observedStmt{stmt.(pqStmt), c, query}, nil
+}
+
+// observedTx ends the transaction of the connection
+type observedTx struct {
+	driver.Tx
+	conn *observedConn
+}
+
+func (tx *observedTx) Commit() error {
+	tx.conn.txContext = nil
+	return tx.Tx.Commit()
+}
+
+func (tx *observedTx) Rollback() error {
+	tx.conn.txContext = nil
+	return tx.Tx.Rollback()
+}
+
+// pqStmt contains the interfaces implemented by the prepared statements of
+// the PostgreSQL driver
+type pqStmt interface {
+	driver.Stmt
+	driver.StmtExecContext
+	driver.StmtQueryContext
+}
+
+// observedStmt reports the executions of a prepared statement
+type observedStmt struct {
+	pqStmt
+	conn  *observedConn
+	query string
+}
+
+func (s *observedStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	finish := observe(s.conn.statementContext(ctx), s.query)
+	result, err := s.pqStmt.ExecContext(ctx, args)
+	finish(err)
+	return result, err
+}
+
+func (s *observedStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	finish := observe(s.conn.statementContext(ctx), s.query)
+	rows, err := s.pqStmt.QueryContext(ctx, args)
+	finish(err)
+	return rows, err
+}
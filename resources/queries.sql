@@ -8,9 +8,229 @@ SELECT
     usage_type,
     additional_properties
 FROM
-    consumers.consumers;
+    consumers.consumers
+WHERE
+    deleted_at IS NULL;
 
 
+-- name: get-consumers-as-of
+-- the versions are valid in the half-open interval [valid_from, valid_until)
+SELECT
+    id,
+    name,
+    description,
+    address,
+    ST_AsGeoJSON(location) as location,
+    usage_type,
+    additional_properties
+FROM
+    consumers.consumer_history
+WHERE
+    valid_from <= $1
+    AND (valid_until IS NULL OR valid_until > $1)
+    AND operation <> 'delete'
+    AND deleted_at IS NULL;
+
+-- name: get-consumer-history
+-- the history of deleted and merged consumers is returned as well. if areas
+-- are supplied, the history is only returned if the last recorded location of
+-- the consumer is inside them
+SELECT
+    version,
+    id,
+    operation,
+    name,
+    description,
+    address,
+    ST_AsGeoJSON(location) as location,
+    usage_type,
+    additional_properties,
+    deleted_at,
+    merged_into,
+    valid_from,
+    valid_until,
+    changed_by
+FROM
+    consumers.consumer_history
+WHERE
+    id = $1
+    AND (
+        $2::text[] IS NULL
+        OR ST_CONTAINS(
+            ST_UNION(ARRAY((SELECT geom FROM geodata.shapes WHERE key = any($2)))),
+            (SELECT latest.location FROM consumers.consumer_history AS latest WHERE latest.id = $1 ORDER BY latest.version DESC LIMIT 1)
+        )
+    )
+ORDER BY
+    version;
+
+-- name: get-current-consumer-version
+SELECT
+    version,
+    id,
+    operation,
+    name,
+    description,
+    address,
+    ST_AsGeoJSON(location) as location,
+    usage_type,
+    additional_properties,
+    deleted_at,
+    merged_into,
+    valid_from,
+    valid_until,
+    changed_by
+FROM
+    consumers.consumer_history
+WHERE
+    id = $1
+    AND valid_until IS NULL;
+
+-- name: get-consumer-event
+SELECT
+    version,
+    id,
+    operation,
+    name,
+    description,
+    address,
+    ST_AsGeoJSON(location) as location,
+    usage_type,
+    additional_properties,
+    deleted_at,
+    merged_into,
+    valid_from,
+    valid_until,
+    changed_by,
+    tenant,
+    CASE
+        WHEN operation = 'create' THEN 'created'
+        WHEN operation = 'delete' OR deleted_at IS NOT NULL THEN 'deleted'
+        ELSE 'updated'
+    END AS event
+FROM
+    consumers.consumer_history
+WHERE
+    version = $1;
+
+-- name: get-consumer-events-since
+SELECT
+    version,
+    id,
+    operation,
+    name,
+    description,
+    address,
+    ST_AsGeoJSON(location) as location,
+    usage_type,
+    additional_properties,
+    deleted_at,
+    merged_into,
+    valid_from,
+    valid_until,
+    changed_by,
+    tenant,
+    CASE
+        WHEN operation = 'create' THEN 'created'
+        WHEN operation = 'delete' OR deleted_at IS NOT NULL THEN 'deleted'
+        ELSE 'updated'
+    END AS event
+FROM
+    consumers.consumer_history
+WHERE
+    version > $1
+    AND operation <> 'snapshot'
+ORDER BY
+    version;
+
+-- name: soft-delete-consumer
+UPDATE consumers.consumers
+SET
+    deleted_at = now()
+WHERE
+    id = $1
+    AND deleted_at IS NULL
+    AND ($2::text[] IS NULL OR ST_CONTAINS(ST_UNION(ARRAY((SELECT geom FROM geodata.shapes WHERE key = any($2)))), location));
+
+-- name: revert-consumer
+UPDATE consumers.consumers
+SET
+    name = history.name,
+    description = history.description,
+    address = history.address,
+    location = history.location,
+    usage_type = history.usage_type,
+    additional_properties = history.additional_properties,
+    deleted_at = history.deleted_at,
+    merged_into = history.merged_into
+FROM
+    consumers.consumer_history AS history
+WHERE
+    consumers.id = $1
+    AND history.id = $1
+    AND history.version = $2
+    AND history.operation <> 'delete'
+    AND (
+        $3::text[] IS NULL
+        OR (ST_CONTAINS(ST_UNION(ARRAY((SELECT geom FROM geodata.shapes WHERE key = any($3)))), consumers.location) AND ST_CONTAINS(ST_UNION(ARRAY((SELECT geom FROM geodata.shapes WHERE key = any($3)))), history.location))
+    );
+
+-- name: get-consumer-record
+-- in contrast to get-consumers, deleted consumers are returned as well
+SELECT
+    id,
+    name,
+    description,
+    address,
+    ST_AsGeoJSON(location) as location,
+    usage_type,
+    additional_properties
+FROM
+    consumers.consumers
+WHERE
+    id = $1;
+
+-- name: erase-consumer
+UPDATE consumers.consumers
+SET
+    name = $2,
+    description = NULL,
+    address = NULL,
+    location = ST_SnapToGrid(location, $3),
+    additional_properties = additional_properties::jsonb - $4::text[]
+WHERE
+    id = $1;
+
+-- name: erase-consumer-history
+UPDATE consumers.consumer_history
+SET
+    name = $2,
+    description = NULL,
+    address = NULL,
+    location = ST_SnapToGrid(location, $3),
+    additional_properties = additional_properties - $4::text[]
+WHERE
+    id = $1;
+
+-- name: erase-consumer-audit-entries
+-- the changes are stored using the json field names of the consumer
+UPDATE consumers.audit_log
+SET
+    changes = changes - ARRAY['name', 'description', 'address', 'location', 'additionalProperties']
+WHERE
+    consumer = $1;
+
+-- name: erase-consumer-outbox-events
+UPDATE consumers.outbox
+SET
+    payload = payload - ARRAY['name', 'description', 'address', 'location', 'additionalProperties']
+WHERE
+    consumer = $1;
+
+-- name: set-changing-user
+-- the user is read by the trigger writing the consumer history
+SELECT set_config('consumers.user', $1, true);
+
 -- name: insert-consumer
 INSERT INTO consumers.consumers(
        name,
@@ -22,8 +242,741 @@ INSERT INTO consumers.consumers(
 ) VALUES ($1, $2, $3,  ST_GeomFromGeoJSON($4), $5, $6)
 RETURNING id;
 
+-- name: update-consumer
+UPDATE consumers.consumers
+SET
+    name = $1,
+    description = $2,
+    address = $3,
+    location = ST_GeomFromGeoJSON($4),
+    usage_type = $5,
+    additional_properties = $6
+WHERE
+    id = $7;
+
+-- name: find-duplicate-consumers
+-- the name and address are normalized by ignoring the case, punctuation and
+-- whitespace before comparing them
+SELECT
+    id
+FROM
+    consumers.consumers
+WHERE
+    deleted_at IS NULL
+    AND (
+        (
+            trim(regexp_replace(lower(name), '[^[:alnum:]]+', ' ', 'g'))
+                = trim(regexp_replace(lower($1), '[^[:alnum:]]+', ' ', 'g'))
+            AND trim(regexp_replace(lower(coalesce(address, '')), '[^[:alnum:]]+', ' ', 'g'))
+                = trim(regexp_replace(lower(coalesce($2, '')), '[^[:alnum:]]+', ' ', 'g'))
+        )
+        OR (
+            $5::double precision > 0
+            AND usage_type = $3
+            AND ST_DWithin(location::geography, ST_GeomFromGeoJSON($4)::geography, $5)
+        )
+    )
+    -- if areas are supplied, only consumers located inside them are considered
+    AND (
+        $6::text[] IS NULL
+        OR ST_CONTAINS(ST_UNION(ARRAY((SELECT geom FROM geodata.shapes WHERE key = any($6)))), location)
+    );
+
+-- name: consumer-exists
+-- if areas are supplied, only consumers located inside them are considered
+SELECT EXISTS(
+    SELECT 1
+    FROM consumers.consumers
+    WHERE
+        id = $1
+        AND deleted_at IS NULL
+        AND (
+            $2::text[] IS NULL
+            OR ST_CONTAINS(ST_UNION(ARRAY((SELECT geom FROM geodata.shapes WHERE key = any($2)))), location)
+        )
+);
+
+-- name: location-in-area
+SELECT COALESCE(
+    ST_CONTAINS(ST_UNION(ARRAY((SELECT geom FROM geodata.shapes WHERE key = any($2)))), ST_GeomFromGeoJSON($1)),
+    false
+);
+
+-- name: lock-consumer
+-- returns the consumer and locks it until the end of the transaction. if
+-- areas are supplied, the consumer is only returned if it is inside them
+SELECT
+    id,
+    name,
+    description,
+    address,
+    ST_AsGeoJSON(location) as location,
+    usage_type,
+    additional_properties
+FROM
+    consumers.consumers
+WHERE
+    id = $1
+    AND deleted_at IS NULL
+    AND ($2::text[] IS NULL OR ST_CONTAINS(ST_UNION(ARRAY((SELECT geom FROM geodata.shapes WHERE key = any($2)))), location))
+FOR UPDATE;
+
+-- name: lock-merge-consumers
+SELECT
+    id,
+    additional_properties
+FROM
+    consumers.consumers
+WHERE
+    id = any($1)
+    AND deleted_at IS NULL
+    AND ($2::text[] IS NULL OR ST_CONTAINS(ST_UNION(ARRAY((SELECT geom FROM geodata.shapes WHERE key = any($2)))), location))
+FOR UPDATE;
+
+-- name: reassign-usage-records
+UPDATE water_usage.usages
+SET
+    consumer = $1
+WHERE
+    consumer = any($2);
+
+-- name: update-consumer-additional-properties
+UPDATE consumers.consumers
+SET
+    additional_properties = $2
+WHERE
+    id = $1;
+
+-- name: soft-delete-merged-consumers
+UPDATE consumers.consumers
+SET
+    deleted_at = now(),
+    merged_into = $1
+WHERE
+    id = any($2);
+
+-- name: insert-consumer-merge
+INSERT INTO consumers.merges(survivor, duplicates, strategy, reassigned_usage_records, merged_by)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING
+    id,
+    survivor,
+    duplicates,
+    strategy,
+    reassigned_usage_records,
+    merged_by,
+    merged_at;
+
+-- name: insert-audit-entry
+INSERT INTO consumers.audit_log(username, groups, request_id, action, consumer, changes)
+VALUES (NULLIF($1, ''), $2, NULLIF($3, ''), $4, $5, $6);
+
+-- name: get-audit-entries
+SELECT
+    id,
+    occurred_at,
+    username,
+    groups,
+    request_id,
+    action,
+    consumer,
+    changes
+FROM
+    consumers.audit_log;
+
+-- name: insert-outbox-event
+-- the deliveries for the subscribed webhooks are created together with the
+-- event to ensure that they are written in the same transaction
+WITH event AS (
+    INSERT INTO consumers.outbox(event_type, consumer, payload)
+    VALUES ($1, $2, $3)
+    RETURNING id, event_type
+)
+INSERT INTO consumers.webhook_deliveries(webhook, event)
+SELECT
+    webhooks.id,
+    event.id
+FROM
+    consumers.webhooks, event
+WHERE
+    webhooks.active
+    AND (cardinality(webhooks.event_types) = 0 OR event.event_type = any(webhooks.event_types));
+
+-- name: get-webhooks
+SELECT
+    id,
+    url,
+    event_types,
+    active,
+    created_at
+FROM
+    consumers.webhooks;
+
+-- name: order-webhooks
+ORDER BY created_at, id;
+
+-- name: insert-webhook
+INSERT INTO consumers.webhooks(url, secret, event_types, active)
+VALUES ($1, $2, $3, $4)
+RETURNING
+    id,
+    url,
+    event_types,
+    active,
+    created_at;
+
+-- name: update-webhook
+UPDATE consumers.webhooks
+SET
+    url = $2,
+    secret = COALESCE($3, secret),
+    event_types = $4,
+    active = $5
+WHERE
+    id = $1
+RETURNING
+    id,
+    url,
+    event_types,
+    active,
+    created_at;
+
+-- name: delete-webhook
+DELETE FROM consumers.webhooks WHERE id = $1;
+
+-- name: get-webhook-deliveries
+SELECT
+    deliveries.id,
+    deliveries.webhook,
+    outbox.event_id,
+    outbox.event_type,
+    outbox.consumer,
+    deliveries.status,
+    deliveries.attempts,
+    deliveries.next_attempt_at,
+    deliveries.last_attempt_at,
+    deliveries.last_status_code,
+    deliveries.last_error,
+    deliveries.created_at
+FROM
+    consumers.webhook_deliveries AS deliveries
+JOIN consumers.outbox
+    ON outbox.id = deliveries.event;
+
+-- name: filter-delivery-webhooks
+deliveries.webhook = any($1);
+
+-- name: filter-delivery-status
+deliveries.status = any($1);
+
+-- name: order-webhook-deliveries
+ORDER BY deliveries.created_at DESC, deliveries.id;
+
+-- name: retry-webhook-delivery
+UPDATE consumers.webhook_deliveries
+SET
+    status = 'pending',
+    attempts = 0,
+    next_attempt_at = now()
+WHERE
+    id = $1
+    AND status = 'dead';
+
+-- name: claim-webhook-deliveries
+-- the claimed deliveries are leased until the supplied point in time to
+-- prevent other instances from delivering them concurrently
+UPDATE consumers.webhook_deliveries AS deliveries
+SET
+    next_attempt_at = $2
+FROM
+    consumers.webhooks,
+    consumers.outbox
+WHERE
+    deliveries.id IN (
+        SELECT id
+        FROM consumers.webhook_deliveries
+        WHERE status = 'pending' AND next_attempt_at <= now()
+        ORDER BY next_attempt_at
+        LIMIT $1
+        FOR UPDATE SKIP LOCKED
+    )
+    AND webhooks.id = deliveries.webhook
+    AND outbox.id = deliveries.event
+RETURNING
+    deliveries.id,
+    deliveries.attempts,
+    webhooks.url,
+    webhooks.secret,
+    outbox.event_id,
+    outbox.event_type,
+    outbox.tenant,
+    outbox.consumer,
+    outbox.payload,
+    outbox.created_at;
+
+-- name: record-webhook-delivery-attempt
+UPDATE consumers.webhook_deliveries
+SET
+    status = $2,
+    attempts = attempts + 1,
+    next_attempt_at = $5,
+    last_attempt_at = now(),
+    last_status_code = $3,
+    last_error = $4
+WHERE
+    id = $1;
+
+-- name: lock-unpublished-outbox-events
+-- the events are locked until the surrounding transaction ends which allows
+-- multiple instances of the service to relay the events concurrently
+SELECT
+    id,
+    event_id,
+    event_type,
+    tenant,
+    consumer,
+    payload,
+    created_at
+FROM
+    consumers.outbox
+WHERE
+    published_at IS NULL
+ORDER BY id
+LIMIT $1
+FOR UPDATE SKIP LOCKED;
+
+-- name: mark-outbox-events-published
+UPDATE consumers.outbox
+SET
+    published_at = now()
+WHERE
+    id = any($1);
+
+-- name: get-usage-records
+SELECT
+    id,
+    consumer,
+    municipality,
+    date,
+    usage_type,
+    amount
+FROM
+    water_usage.usages
+WHERE
+    consumer = $1;
+
+-- name: insert-usage-records
+-- inserts the usage records of a consumer at once. the attributes of the
+-- records are passed as arrays of the same length
+INSERT INTO water_usage.usages(
+       id,
+       consumer,
+       municipality,
+       date,
+       usage_type,
+       amount
+)
+SELECT id, $1, municipality, date, usage_type, amount
+FROM unnest($2::uuid[], $3::text[], $4::timestamptz[], $5::uuid[], $6::double precision[])
+    AS records(id, municipality, date, usage_type, amount);
+
+-- name: delete-usage-record
+DELETE FROM water_usage.usages WHERE id = $1 AND consumer = $2;
+
+-- name: aggregate-consumer-usages
+WITH selected_usages AS (
+    SELECT date, amount
+    FROM water_usage.usages
+    WHERE consumer = $4
+),
+buckets AS (
+    SELECT generate_series(
+        date_trunc($1::text, COALESCE($2::timestamptz, (SELECT min(date) FROM selected_usages))),
+        date_trunc($1::text, COALESCE($3::timestamptz, (SELECT max(date) FROM selected_usages))),
+        -- postgres has no interval unit for quarters
+        CASE WHEN $1::text = 'quarter' THEN interval '3 months' ELSE ('1 ' || $1::text)::interval END
+    ) AS bucket
+)
+SELECT
+    buckets.bucket,
+    COALESCE(sum(selected_usages.amount), 0) AS total,
+    avg(selected_usages.amount) AS average,
+    count(selected_usages.amount) AS records
+FROM
+    buckets
+LEFT JOIN selected_usages
+    ON date_trunc($1::text, selected_usages.date) = buckets.bucket
+    AND ($2::timestamptz IS NULL OR selected_usages.date >= $2::timestamptz)
+    AND ($3::timestamptz IS NULL OR selected_usages.date <= $3::timestamptz)
+GROUP BY
+    buckets.bucket
+ORDER BY
+    buckets.bucket;
+
+-- name: aggregate-area-usages
+WITH selected_usages AS (
+    SELECT date, amount
+    FROM water_usage.usages
+    WHERE consumer IN (
+        SELECT id
+        FROM consumers.consumers
+        WHERE ST_CONTAINS(ST_UNION(ARRAY((SELECT geom FROM geodata.shapes WHERE key = any($4)))), location)
+        AND deleted_at IS NULL
+    )
+),
+buckets AS (
+    SELECT generate_series(
+        date_trunc($1::text, COALESCE($2::timestamptz, (SELECT min(date) FROM selected_usages))),
+        date_trunc($1::text, COALESCE($3::timestamptz, (SELECT max(date) FROM selected_usages))),
+        -- postgres has no interval unit for quarters
+        CASE WHEN $1::text = 'quarter' THEN interval '3 months' ELSE ('1 ' || $1::text)::interval END
+    ) AS bucket
+)
+SELECT
+    buckets.bucket,
+    COALESCE(sum(selected_usages.amount), 0) AS total,
+    avg(selected_usages.amount) AS average,
+    count(selected_usages.amount) AS records
+FROM
+    buckets
+LEFT JOIN selected_usages
+    ON date_trunc($1::text, selected_usages.date) = buckets.bucket
+    AND ($2::timestamptz IS NULL OR selected_usages.date >= $2::timestamptz)
+    AND ($3::timestamptz IS NULL OR selected_usages.date <= $3::timestamptz)
+GROUP BY
+    buckets.bucket
+ORDER BY
+    buckets.bucket;
+
+
+
+-- name: statistics-consumer-totals
+SELECT
+    consumers.id,
+    consumers.name,
+    consumers.usage_type,
+    COALESCE(sum(usages.amount), 0) AS total
+FROM
+    consumers.consumers
+LEFT JOIN water_usage.usages
+    ON usages.consumer = consumers.id
+    AND ($1::timestamptz IS NULL OR usages.date >= $1::timestamptz)
+    AND ($2::timestamptz IS NULL OR usages.date <= $2::timestamptz)
+WHERE
+    consumers.deleted_at IS NULL;
+
+-- name: group-statistics-consumer-totals
+GROUP BY consumers.id, consumers.name, consumers.usage_type;
+
+-- the following statistic queries are evaluated on the common table expression
+-- "consumer_totals" which is built from the "statistics-consumer-totals" query
+
+-- name: statistics-summary
+SELECT
+    count(*) AS consumers,
+    COALESCE(sum(total), 0) AS total,
+    avg(total) AS mean,
+    percentile_cont(ARRAY[0.25, 0.5, 0.75, 0.9, 0.95, 0.99]) WITHIN GROUP (ORDER BY total) AS percentiles
+FROM
+    consumer_totals;
+
+-- name: statistics-top-consumers
+SELECT
+    id AS consumer,
+    name,
+    usage_type,
+    total
+FROM
+    consumer_totals
+ORDER BY
+    total DESC, id
+LIMIT $1;
+
+-- name: statistics-usage-types
+SELECT
+    usage_type,
+    count(*) AS consumers,
+    sum(total) AS total
+FROM
+    consumer_totals
+GROUP BY
+    usage_type
+ORDER BY
+    consumers DESC;
+
+-- name: get-anomalies
+SELECT
+    id,
+    consumer,
+    usage_record,
+    kind,
+    reading_date,
+    amount,
+    expected,
+    score,
+    detected_at,
+    status,
+    status_changed_at,
+    status_changed_by
+FROM
+    consumers.usage_anomalies;
+
+-- name: order-anomalies
+ORDER BY detected_at DESC, id;
+
+-- name: update-anomaly-status
+UPDATE consumers.usage_anomalies
+SET
+    status = $2,
+    status_changed_at = now(),
+    status_changed_by = $3
+WHERE
+    id = $1
+    -- if areas are supplied, only anomalies of consumers located inside them
+    -- are considered
+    AND (
+        $4::text[] IS NULL
+        OR consumer IN (
+            SELECT id
+            FROM consumers.consumers
+            WHERE ST_CONTAINS(ST_UNION(ARRAY((SELECT geom FROM geodata.shapes WHERE key = any($4)))), location)
+        )
+    )
+RETURNING
+    id,
+    consumer,
+    usage_record,
+    kind,
+    reading_date,
+    amount,
+    expected,
+    score,
+    detected_at,
+    status,
+    status_changed_at,
+    status_changed_by;
+
+-- name: lock-anomaly-detection
+SELECT pg_try_advisory_xact_lock(hashtext('consumers.anomaly-detection'));
+
+-- name: detect-deviations-zscore
+WITH consumer_statistics AS (
+    SELECT
+        consumer,
+        avg(amount) AS mean,
+        stddev_samp(amount) AS deviation,
+        count(*) AS readings
+    FROM
+        water_usage.usages
+    WHERE
+        consumer IN (SELECT id FROM consumers.consumers WHERE deleted_at IS NULL)
+    GROUP BY
+        consumer
+)
+INSERT INTO consumers.usage_anomalies(consumer, usage_record, kind, fingerprint, reading_date, amount, expected, score)
+SELECT
+    usages.consumer,
+    usages.id,
+    'deviation',
+    'deviation:' || usages.id,
+    usages.date,
+    usages.amount,
+    consumer_statistics.mean,
+    (usages.amount - consumer_statistics.mean) / consumer_statistics.deviation
+FROM
+    water_usage.usages
+JOIN consumer_statistics
+    ON consumer_statistics.consumer = usages.consumer
+WHERE
+    consumer_statistics.readings >= $2
+    AND consumer_statistics.deviation > 0
+    AND abs(usages.amount - consumer_statistics.mean) / consumer_statistics.deviation > $1
+ON CONFLICT (fingerprint) DO NOTHING;
+
+-- name: detect-deviations-seasonal-mad
+WITH monthly_medians AS (
+    SELECT
+        consumer,
+        extract(MONTH FROM date) AS month,
+        percentile_cont(0.5) WITHIN GROUP (ORDER BY amount) AS median,
+        count(*) AS readings
+    FROM
+        water_usage.usages
+    WHERE
+        consumer IN (SELECT id FROM consumers.consumers WHERE deleted_at IS NULL)
+    GROUP BY
+        consumer, extract(MONTH FROM date)
+),
+monthly_deviations AS (
+    SELECT
+        usages.consumer,
+        monthly_medians.month,
+        percentile_cont(0.5) WITHIN GROUP (ORDER BY abs(usages.amount - monthly_medians.median)) AS mad
+    FROM
+        water_usage.usages
+    JOIN monthly_medians
+        ON monthly_medians.consumer = usages.consumer
+        AND monthly_medians.month = extract(MONTH FROM usages.date)
+    GROUP BY
+        usages.consumer, monthly_medians.month
+)
+INSERT INTO consumers.usage_anomalies(consumer, usage_record, kind, fingerprint, reading_date, amount, expected, score)
+SELECT
+    usages.consumer,
+    usages.id,
+    'deviation',
+    'deviation:' || usages.id,
+    usages.date,
+    usages.amount,
+    monthly_medians.median,
+    0.6745 * (usages.amount - monthly_medians.median) / monthly_deviations.mad
+FROM
+    water_usage.usages
+JOIN monthly_medians
+    ON monthly_medians.consumer = usages.consumer
+    AND monthly_medians.month = extract(MONTH FROM usages.date)
+JOIN monthly_deviations
+    ON monthly_deviations.consumer = usages.consumer
+    AND monthly_deviations.month = monthly_medians.month
+WHERE
+    monthly_medians.readings >= $2
+    AND monthly_deviations.mad > 0
+    AND 0.6745 * abs(usages.amount - monthly_medians.median) / monthly_deviations.mad > $1
+ON CONFLICT (fingerprint) DO NOTHING;
+
+-- name: detect-missing-readings
+INSERT INTO consumers.usage_anomalies(consumer, kind, fingerprint, reading_date)
+SELECT
+    consumer,
+    'missing_readings',
+    'missing_readings:' || consumer || ':' || max(date),
+    max(date)
+FROM
+    water_usage.usages
+WHERE
+    consumer IN (SELECT id FROM consumers.consumers WHERE deleted_at IS NULL)
+GROUP BY
+    consumer
+HAVING
+    max(date) < now() - make_interval(secs => $1)
+ON CONFLICT (fingerprint) DO NOTHING;
+
+-- name: get-usage-types
+SELECT
+    id,
+    name,
+    description,
+    external_code,
+    parent
+FROM
+    water_usage.usage_types;
+
+-- name: order-usage-types
+ORDER BY name, id;
+
+-- name: insert-usage-type
+INSERT INTO water_usage.usage_types(
+       name,
+       description,
+       external_code,
+       parent
+) VALUES ($1, $2, $3, $4)
+RETURNING id;
+
+-- name: update-usage-type
+UPDATE water_usage.usage_types
+SET
+    name = $2,
+    description = $3,
+    external_code = $4,
+    parent = $5
+WHERE
+    id = $1;
 
+-- name: delete-usage-type
+DELETE FROM water_usage.usage_types WHERE id = $1;
 
+-- name: usage-type-exists
+SELECT EXISTS(SELECT 1 FROM water_usage.usage_types WHERE id = $1);
+
+-- name: count-usage-types
+SELECT count(*) FROM water_usage.usage_types WHERE id = any($1);
+
+-- name: usage-type-in-use
+-- the usage types are shared by all tenants, so the check needs to be executed
+-- with the system tenant to see the consumers and usage records of every tenant
+SELECT
+    EXISTS(SELECT 1 FROM consumers.consumers WHERE usage_type = $1)
+    OR EXISTS(SELECT 1 FROM water_usage.usages WHERE usage_type = $1)
+    OR EXISTS(SELECT 1 FROM water_usage.usage_types WHERE parent = $1);
+
+-- name: usage-type-ancestors
+WITH RECURSIVE ancestors AS (
+    SELECT id, parent FROM water_usage.usage_types WHERE id = $1
+    UNION
+    SELECT usage_types.id, usage_types.parent
+    FROM water_usage.usage_types
+    JOIN ancestors ON usage_types.id = ancestors.parent
+)
+SELECT id FROM ancestors;
+
+-- name: get-api-keys
+SELECT
+    id,
+    name,
+    prefix,
+    tenant,
+    scopes,
+    areas,
+    expires_at,
+    last_used_at,
+    created_by,
+    created_at,
+    revoked_at
+FROM
+    consumers.api_keys;
+
+-- name: order-api-keys
+ORDER BY created_at, id;
+
+-- name: insert-api-key
+INSERT INTO consumers.api_keys(name, prefix, key_hash, scopes, areas, expires_at, created_by, tenant)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+RETURNING
+    id,
+    name,
+    prefix,
+    tenant,
+    scopes,
+    areas,
+    expires_at,
+    last_used_at,
+    created_by,
+    created_at,
+    revoked_at;
+
+-- name: revoke-api-key
+UPDATE consumers.api_keys
+SET
+    revoked_at = now()
+WHERE
+    id = $1
+    AND revoked_at IS NULL;
+
+-- name: use-api-key
+-- the key is only returned if it has neither been revoked nor expired
+UPDATE consumers.api_keys
+SET
+    last_used_at = now()
+WHERE
+    key_hash = $1
+    AND revoked_at IS NULL
+    AND (expires_at IS NULL OR expires_at > now())
+RETURNING
+    id,
+    tenant,
+    scopes,
+    areas;
 
 
 -- ========================================================================== --
@@ -35,4 +988,311 @@ id = any($1);
 id IN (SELECT consumer FROM water_usage.usages WHERE consumer IS NOT NULL AND usages.amount > $1);
 
 -- name: filter-location
-ST_CONTAINS(ST_UNION(ARRAY((SELECT geom FROM geodata.shapes WHERE key = any($1)))), location);
\ No newline at end of file
+ST_CONTAINS(ST_UNION(ARRAY((SELECT geom FROM geodata.shapes WHERE key = any($1)))), location);
+
+-- name: filter-usage-from
+date >= $1;
+
+-- name: filter-usage-to
+date <= $1;
+
+-- name: filter-usage-types
+usage_type = any($1);
+
+-- name: order-usage-records
+ORDER BY date, id;
+
+-- name: filter-audit-users
+username = any($1);
+
+-- name: filter-audit-consumers
+consumer = any($1);
+
+-- name: filter-audit-from
+occurred_at >= $1;
+
+-- name: filter-audit-to
+occurred_at <= $1;
+
+-- name: order-audit-entries
+ORDER BY occurred_at, id;
+
+-- name: filter-anomaly-consumers
+consumer = any($1);
+
+-- name: filter-anomaly-status
+status = any($1);
+
+-- name: filter-anomaly-kinds
+kind = any($1);
+
+-- name: filter-anomaly-area
+consumer IN (
+    SELECT id
+    FROM consumers.consumers
+    WHERE ST_CONTAINS(ST_UNION(ARRAY((SELECT geom FROM geodata.shapes WHERE key = any($1)))), location)
+);
+
+
+-- ========================================================================== --
+-- the following queries create the tables managed by this service if they
+-- do not exist yet. they are executed in the order defined in "init.go"
+
+-- name: create-usage-types-table
+CREATE TABLE IF NOT EXISTS water_usage.usage_types(
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    name text NOT NULL,
+    description text
+);
+ALTER TABLE water_usage.usage_types ADD COLUMN IF NOT EXISTS external_code text;
+ALTER TABLE water_usage.usage_types ADD COLUMN IF NOT EXISTS parent uuid REFERENCES water_usage.usage_types(id);
+CREATE UNIQUE INDEX IF NOT EXISTS usage_types_external_code_idx ON water_usage.usage_types(external_code);
+
+-- name: create-usage-anomalies-table
+CREATE TABLE IF NOT EXISTS consumers.usage_anomalies(
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    consumer uuid NOT NULL REFERENCES consumers.consumers(id) ON DELETE CASCADE,
+    usage_record uuid REFERENCES water_usage.usages(id) ON DELETE CASCADE,
+    kind text NOT NULL CHECK (kind IN ('deviation', 'missing_readings')),
+    fingerprint text NOT NULL UNIQUE,
+    reading_date timestamptz,
+    amount double precision,
+    expected double precision,
+    score double precision,
+    detected_at timestamptz NOT NULL DEFAULT now(),
+    status text NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'acknowledged', 'dismissed')),
+    status_changed_at timestamptz,
+    status_changed_by text
+);
+CREATE INDEX IF NOT EXISTS usage_anomalies_consumer_idx ON consumers.usage_anomalies(consumer);
+
+-- name: extend-consumers-table
+ALTER TABLE consumers.consumers ADD COLUMN IF NOT EXISTS deleted_at timestamptz;
+ALTER TABLE consumers.consumers ADD COLUMN IF NOT EXISTS merged_into uuid REFERENCES consumers.consumers(id);
+
+-- name: create-consumer-merges-table
+CREATE TABLE IF NOT EXISTS consumers.merges(
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    survivor uuid NOT NULL REFERENCES consumers.consumers(id),
+    duplicates uuid[] NOT NULL,
+    strategy text NOT NULL,
+    reassigned_usage_records bigint NOT NULL,
+    merged_by text,
+    merged_at timestamptz NOT NULL DEFAULT now()
+);
+
+-- name: create-consumer-history-table
+CREATE TABLE IF NOT EXISTS consumers.consumer_history(
+    version bigserial PRIMARY KEY,
+    id uuid NOT NULL,
+    operation text NOT NULL CHECK (operation IN ('snapshot', 'create', 'update', 'delete')),
+    name text,
+    description text,
+    address text,
+    location geometry,
+    usage_type uuid,
+    additional_properties jsonb,
+    deleted_at timestamptz,
+    merged_into uuid,
+    valid_from timestamptz NOT NULL DEFAULT now(),
+    valid_until timestamptz,
+    changed_by text
+);
+CREATE INDEX IF NOT EXISTS consumer_history_id_idx ON consumers.consumer_history(id, valid_from);
+
+-- name: create-consumer-history-trigger
+CREATE OR REPLACE FUNCTION consumers.record_consumer_history() RETURNS trigger AS $$
+DECLARE
+    recorded_version bigint;
+BEGIN
+    UPDATE consumers.consumer_history
+    SET valid_until = now()
+    WHERE id = COALESCE(NEW.id, OLD.id) AND valid_until IS NULL;
+
+    IF TG_OP = 'DELETE' THEN
+        INSERT INTO consumers.consumer_history(id, tenant, operation, name, description, address, location, usage_type, additional_properties, deleted_at, merged_into, valid_until, changed_by)
+        VALUES (OLD.id, OLD.tenant, 'delete', OLD.name, OLD.description, OLD.address, OLD.location, OLD.usage_type, OLD.additional_properties, OLD.deleted_at, OLD.merged_into, now(), current_setting('consumers.user', true))
+        RETURNING version INTO recorded_version;
+        PERFORM pg_notify('consumer_events', recorded_version::text);
+        RETURN OLD;
+    END IF;
+
+    INSERT INTO consumers.consumer_history(id, tenant, operation, name, description, address, location, usage_type, additional_properties, deleted_at, merged_into, changed_by)
+    VALUES (NEW.id, NEW.tenant, CASE TG_OP WHEN 'INSERT' THEN 'create' ELSE 'update' END, NEW.name, NEW.description, NEW.address, NEW.location, NEW.usage_type, NEW.additional_properties, NEW.deleted_at, NEW.merged_into, current_setting('consumers.user', true))
+    RETURNING version INTO recorded_version;
+    PERFORM pg_notify('consumer_events', recorded_version::text);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_trigger WHERE tgrelid = 'consumers.consumers'::regclass AND tgname = 'consumer_history_trigger') THEN
+        CREATE TRIGGER consumer_history_trigger
+            AFTER INSERT OR UPDATE OR DELETE ON consumers.consumers
+            FOR EACH ROW EXECUTE FUNCTION consumers.record_consumer_history();
+    END IF;
+END;
+$$;
+
+-- name: create-audit-log-table
+CREATE TABLE IF NOT EXISTS consumers.audit_log(
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    occurred_at timestamptz NOT NULL DEFAULT now(),
+    username text,
+    groups text[],
+    request_id text,
+    action text NOT NULL,
+    consumer uuid,
+    changes jsonb
+);
+CREATE INDEX IF NOT EXISTS audit_log_consumer_idx ON consumers.audit_log(consumer);
+CREATE INDEX IF NOT EXISTS audit_log_occurred_at_idx ON consumers.audit_log(occurred_at);
+
+-- name: create-outbox-table
+CREATE TABLE IF NOT EXISTS consumers.outbox(
+    id bigserial PRIMARY KEY,
+    event_id uuid NOT NULL UNIQUE DEFAULT gen_random_uuid(),
+    event_type text NOT NULL,
+    consumer uuid,
+    payload jsonb,
+    created_at timestamptz NOT NULL DEFAULT now()
+);
+
+-- name: create-webhook-tables
+CREATE TABLE IF NOT EXISTS consumers.webhooks(
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    url text NOT NULL,
+    secret text NOT NULL,
+    event_types text[] NOT NULL DEFAULT '{}',
+    active boolean NOT NULL DEFAULT true,
+    created_at timestamptz NOT NULL DEFAULT now()
+);
+CREATE TABLE IF NOT EXISTS consumers.webhook_deliveries(
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    webhook uuid NOT NULL REFERENCES consumers.webhooks(id) ON DELETE CASCADE,
+    event bigint NOT NULL REFERENCES consumers.outbox(id),
+    status text NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'delivered', 'dead')),
+    attempts integer NOT NULL DEFAULT 0,
+    next_attempt_at timestamptz NOT NULL DEFAULT now(),
+    last_attempt_at timestamptz,
+    last_status_code integer,
+    last_error text,
+    created_at timestamptz NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS webhook_deliveries_due_idx ON consumers.webhook_deliveries(next_attempt_at) WHERE status = 'pending';
+
+-- name: extend-outbox-table
+ALTER TABLE consumers.outbox ADD COLUMN IF NOT EXISTS published_at timestamptz;
+CREATE INDEX IF NOT EXISTS outbox_unpublished_idx ON consumers.outbox(id) WHERE published_at IS NULL;
+
+-- name: create-api-keys-table
+CREATE TABLE IF NOT EXISTS consumers.api_keys(
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    name text NOT NULL,
+    prefix text NOT NULL,
+    key_hash text NOT NULL UNIQUE,
+    scopes text[] NOT NULL,
+    areas text[],
+    expires_at timestamptz,
+    last_used_at timestamptz,
+    created_by text,
+    created_at timestamptz NOT NULL DEFAULT now(),
+    revoked_at timestamptz
+);
+
+-- name: create-tenancy
+-- every consumer, usage record, audit entry, event, webhook and api key
+-- belongs to a tenant. the tenant of new rows is taken from the tenant the
+-- connection is bound to. rows created before the introduction of tenants
+-- belong to the "default" tenant
+ALTER TABLE consumers.consumers ADD COLUMN IF NOT EXISTS tenant text NOT NULL DEFAULT 'default';
+ALTER TABLE consumers.consumers ALTER COLUMN tenant SET DEFAULT current_setting('consumers.tenant');
+CREATE INDEX IF NOT EXISTS consumers_tenant_idx ON consumers.consumers(tenant);
+ALTER TABLE water_usage.usages ADD COLUMN IF NOT EXISTS tenant text NOT NULL DEFAULT 'default';
+ALTER TABLE water_usage.usages ALTER COLUMN tenant SET DEFAULT current_setting('consumers.tenant');
+CREATE INDEX IF NOT EXISTS usages_tenant_idx ON water_usage.usages(tenant);
+ALTER TABLE consumers.consumer_history ADD COLUMN IF NOT EXISTS tenant text NOT NULL DEFAULT 'default';
+ALTER TABLE consumers.consumer_history ALTER COLUMN tenant SET DEFAULT current_setting('consumers.tenant');
+ALTER TABLE consumers.audit_log ADD COLUMN IF NOT EXISTS tenant text NOT NULL DEFAULT 'default';
+ALTER TABLE consumers.audit_log ALTER COLUMN tenant SET DEFAULT current_setting('consumers.tenant');
+CREATE INDEX IF NOT EXISTS audit_log_tenant_idx ON consumers.audit_log(tenant);
+ALTER TABLE consumers.outbox ADD COLUMN IF NOT EXISTS tenant text NOT NULL DEFAULT 'default';
+ALTER TABLE consumers.outbox ALTER COLUMN tenant SET DEFAULT current_setting('consumers.tenant');
+ALTER TABLE consumers.webhooks ADD COLUMN IF NOT EXISTS tenant text NOT NULL DEFAULT 'default';
+ALTER TABLE consumers.webhooks ALTER COLUMN tenant SET DEFAULT current_setting('consumers.tenant');
+CREATE INDEX IF NOT EXISTS webhooks_tenant_idx ON consumers.webhooks(tenant);
+ALTER TABLE consumers.webhook_deliveries ADD COLUMN IF NOT EXISTS tenant text NOT NULL DEFAULT 'default';
+ALTER TABLE consumers.webhook_deliveries ALTER COLUMN tenant SET DEFAULT current_setting('consumers.tenant');
+ALTER TABLE consumers.api_keys ADD COLUMN IF NOT EXISTS tenant text NOT NULL DEFAULT 'default';
+ALTER TABLE consumers.api_keys ALTER COLUMN tenant SET DEFAULT current_setting('consumers.tenant');
+-- the policies are only created if they do not exist yet, since replacing
+-- them would leave the tables unprotected for other connections
+CREATE OR REPLACE FUNCTION consumers.create_policy(policy_name text, table_name regclass, definition text) RETURNS void AS $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_policy WHERE polrelid = table_name AND polname = policy_name) THEN
+        EXECUTE format('CREATE POLICY %I ON %s %s', policy_name, table_name, definition);
+    END IF;
+END;
+$$ LANGUAGE plpgsql;
+-- the rows are only visible to connections bound to their tenant. the system
+-- tenant ("*") is only used by the background jobs and may read every row but
+-- not write any. the policies also apply to the owner of the tables
+ALTER TABLE consumers.consumers ENABLE ROW LEVEL SECURITY;
+ALTER TABLE consumers.consumers FORCE ROW LEVEL SECURITY;
+SELECT consumers.create_policy('tenant_isolation', 'consumers.consumers', $policy$USING (tenant = current_setting('consumers.tenant', true) OR current_setting('consumers.tenant', true) = '*') WITH CHECK (tenant = current_setting('consumers.tenant', true) AND tenant <> '*')$policy$);
+ALTER TABLE water_usage.usages ENABLE ROW LEVEL SECURITY;
+ALTER TABLE water_usage.usages FORCE ROW LEVEL SECURITY;
+SELECT consumers.create_policy('tenant_isolation', 'water_usage.usages', $policy$USING (tenant = current_setting('consumers.tenant', true) OR current_setting('consumers.tenant', true) = '*') WITH CHECK (tenant = current_setting('consumers.tenant', true) AND tenant <> '*')$policy$);
+ALTER TABLE consumers.consumer_history ENABLE ROW LEVEL SECURITY;
+ALTER TABLE consumers.consumer_history FORCE ROW LEVEL SECURITY;
+SELECT consumers.create_policy('tenant_isolation', 'consumers.consumer_history', $policy$USING (tenant = current_setting('consumers.tenant', true) OR current_setting('consumers.tenant', true) = '*') WITH CHECK (tenant = current_setting('consumers.tenant', true) AND tenant <> '*')$policy$);
+ALTER TABLE consumers.audit_log ENABLE ROW LEVEL SECURITY;
+ALTER TABLE consumers.audit_log FORCE ROW LEVEL SECURITY;
+SELECT consumers.create_policy('tenant_isolation', 'consumers.audit_log', $policy$USING (tenant = current_setting('consumers.tenant', true) OR current_setting('consumers.tenant', true) = '*') WITH CHECK (tenant = current_setting('consumers.tenant', true) AND tenant <> '*')$policy$);
+ALTER TABLE consumers.webhooks ENABLE ROW LEVEL SECURITY;
+ALTER TABLE consumers.webhooks FORCE ROW LEVEL SECURITY;
+SELECT consumers.create_policy('tenant_isolation', 'consumers.webhooks', $policy$USING (tenant = current_setting('consumers.tenant', true) OR current_setting('consumers.tenant', true) = '*') WITH CHECK (tenant = current_setting('consumers.tenant', true) AND tenant <> '*')$policy$);
+-- the events, deliveries and api keys are additionally updated by the
+-- background jobs and the authentication which use the system tenant. the
+-- system tenant may update these rows, but may still not create any
+ALTER TABLE consumers.outbox ENABLE ROW LEVEL SECURITY;
+ALTER TABLE consumers.outbox FORCE ROW LEVEL SECURITY;
+SELECT consumers.create_policy('tenant_isolation', 'consumers.outbox', $policy$USING (tenant = current_setting('consumers.tenant', true) OR current_setting('consumers.tenant', true) = '*') WITH CHECK (tenant = current_setting('consumers.tenant', true) AND tenant <> '*')$policy$);
+SELECT consumers.create_policy('system_maintenance', 'consumers.outbox', $policy$FOR UPDATE USING (current_setting('consumers.tenant', true) = '*')$policy$);
+ALTER TABLE consumers.webhook_deliveries ENABLE ROW LEVEL SECURITY;
+ALTER TABLE consumers.webhook_deliveries FORCE ROW LEVEL SECURITY;
+SELECT consumers.create_policy('tenant_isolation', 'consumers.webhook_deliveries', $policy$USING (tenant = current_setting('consumers.tenant', true) OR current_setting('consumers.tenant', true) = '*') WITH CHECK (tenant = current_setting('consumers.tenant', true) AND tenant <> '*')$policy$);
+SELECT consumers.create_policy('system_maintenance', 'consumers.webhook_deliveries', $policy$FOR UPDATE USING (current_setting('consumers.tenant', true) = '*')$policy$);
+ALTER TABLE consumers.api_keys ENABLE ROW LEVEL SECURITY;
+ALTER TABLE consumers.api_keys FORCE ROW LEVEL SECURITY;
+SELECT consumers.create_policy('tenant_isolation', 'consumers.api_keys', $policy$USING (tenant = current_setting('consumers.tenant', true) OR current_setting('consumers.tenant', true) = '*') WITH CHECK (tenant = current_setting('consumers.tenant', true) AND tenant <> '*')$policy$);
+SELECT consumers.create_policy('system_maintenance', 'consumers.api_keys', $policy$FOR UPDATE USING (current_setting('consumers.tenant', true) = '*')$policy$);
+-- anomalies and merges inherit the tenant of their consumers since the
+-- consumers are only visible to their tenant
+ALTER TABLE consumers.usage_anomalies ENABLE ROW LEVEL SECURITY;
+ALTER TABLE consumers.usage_anomalies FORCE ROW LEVEL SECURITY;
+SELECT consumers.create_policy('tenant_isolation', 'consumers.usage_anomalies', $policy$USING (consumer IN (SELECT id FROM consumers.consumers))$policy$);
+ALTER TABLE consumers.merges ENABLE ROW LEVEL SECURITY;
+ALTER TABLE consumers.merges FORCE ROW LEVEL SECURITY;
+SELECT consumers.create_policy('tenant_isolation', 'consumers.merges', $policy$USING (survivor IN (SELECT id FROM consumers.consumers))$policy$);
+
+-- name: lock-schema-migration
+-- replicas started at the same time prepare the schema one after another
+SELECT pg_advisory_xact_lock(hashtext('consumers.schema-migration'));
+
+-- name: consumer-history-backfill-tenants
+-- the tenants of the consumers without a recorded history
+SELECT DISTINCT tenant
+FROM consumers.consumers
+WHERE id NOT IN (SELECT id FROM consumers.consumer_history);
+
+-- name: backfill-consumer-history
+-- records a snapshot of the consumers of a tenant which were created before
+-- their history has been recorded
+INSERT INTO consumers.consumer_history(id, tenant, operation, name, description, address, location, usage_type, additional_properties, deleted_at, merged_into)
+SELECT id, tenant, 'snapshot', name, description, address, location, usage_type, additional_properties, deleted_at, merged_into
+FROM consumers.consumers
+WHERE tenant = $1 AND id NOT IN (SELECT id FROM consumers.consumer_history);
+
+-- name: bypasses-row-level-security
+SELECT rolsuper OR rolbypassrls FROM pg_roles WHERE rolname = current_user;
\ No newline at end of file
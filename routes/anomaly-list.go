@@ -0,0 +1,169 @@
+package routes
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/blockloop/scan/v2"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/rs/zerolog/log"
+	wisdomMiddleware "github.com/wisdom-oss/microservice-middlewares/v3"
+
+	"github.com/wisdom-oss/service-consumers/auth"
+	"github.com/wisdom-oss/service-consumers/types"
+)
+
+// AnomalyList returns the detected usage anomalies of all consumers.
+// The list can be filtered by using the following query parameters:
+//   - consumer
+//   - status
+//   - kind
+func AnomalyList(w http.ResponseWriter, r *http.Request) {
+	writeAnomalies(w, r, r.URL.Query()["consumer"])
+}
+
+// ConsumerAnomalies returns the detected usage anomalies of a single consumer.
+// The list can be filtered by using the following query parameters:
+//   - status
+//   - kind
+func ConsumerAnomalies(w http.ResponseWriter, r *http.Request) {
+	// get the error handler and the error handler status channel
+	errorHandler := r.Context().Value(wisdomMiddleware.ERROR_CHANNEL_NAME).(chan<- interface{})
+	statusChannel := r.Context().Value(wisdomMiddleware.STATUS_CHANNEL_NAME).(<-chan bool)
+
+	// now get the consumer id from the url and validate it
+	consumerID, err := uuid.Parse(chi.URLParam(r, "consumer-id"))
+	if err != nil {
+		errorHandler <- "INVALID_CONSUMER_ID"
+		<-statusChannel
+		return
+	}
+
+	exists, err := consumerExists(requestDB(r), r, consumerID)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to check if consumer exists")
+		errorHandler <- fmt.Errorf("unable to check if consumer exists: %w", err)
+		<-statusChannel
+		return
+	}
+	if !exists {
+		errorHandler <- "UNKNOWN_CONSUMER"
+		<-statusChannel
+		return
+	}
+
+	writeAnomalies(w, r, []string{consumerID.String()})
+}
+
+// writeAnomalies queries the anomalies matching the filters set in the
+// request and writes them into the response. If consumer ids are supplied,
+// only the anomalies of these consumers are returned
+func writeAnomalies(w http.ResponseWriter, r *http.Request, consumerIDs []string) {
+	// get the error handler and the error handler status channel
+	errorHandler := r.Context().Value(wisdomMiddleware.ERROR_CHANNEL_NAME).(chan<- interface{})
+	statusChannel := r.Context().Value(wisdomMiddleware.STATUS_CHANNEL_NAME).(<-chan bool)
+
+	statuses, statusesSet := r.URL.Query()["status"]
+	kinds, kindsSet := r.URL.Query()["kind"]
+
+	query, err := newQueryBuilder("get-anomalies")
+	if err != nil {
+		log.Error().Err(err).Msg("unable to build sql")
+		errorHandler <- fmt.Errorf("unable to build sql: %w", err)
+		<-statusChannel
+		return
+	}
+
+	if consumerIDs != nil {
+		for _, consumerID := range consumerIDs {
+			_, err = uuid.Parse(consumerID)
+			if err != nil {
+				errorHandler <- "INVALID_UUID_IN_FILTER"
+				<-statusChannel
+				return
+			}
+		}
+		err = query.addFilter("filter-anomaly-consumers", pq.Array(consumerIDs))
+		if err != nil {
+			log.Error().Err(err).Msg("unable to load filter sql")
+			errorHandler <- fmt.Errorf("unable to load filter sql: %w", err)
+			<-statusChannel
+			return
+		}
+	}
+
+	if statusesSet {
+		err = query.addFilter("filter-anomaly-status", pq.Array(statuses))
+		if err != nil {
+			log.Error().Err(err).Msg("unable to load filter sql")
+			errorHandler <- fmt.Errorf("unable to load filter sql: %w", err)
+			<-statusChannel
+			return
+		}
+	}
+
+	if kindsSet {
+		err = query.addFilter("filter-anomaly-kinds", pq.Array(kinds))
+		if err != nil {
+			log.Error().Err(err).Msg("unable to load filter sql")
+			errorHandler <- fmt.Errorf("unable to load filter sql: %w", err)
+			<-statusChannel
+			return
+		}
+	}
+
+	// users restricted to areas only see the anomalies of the consumers
+	// inside their areas
+	if principal := auth.PrincipalFromContext(r.Context()); principal.Restricted() {
+		err = query.addFilter("filter-anomaly-area", pq.Array(principal.Areas))
+		if err != nil {
+			log.Error().Err(err).Msg("unable to load filter sql")
+			errorHandler <- fmt.Errorf("unable to load filter sql: %w", err)
+			<-statusChannel
+			return
+		}
+	}
+
+	sql, err := query.build("order-anomalies")
+	if err != nil {
+		log.Error().Err(err).Msg("unable to build sql")
+		errorHandler <- fmt.Errorf("unable to build sql: %w", err)
+		<-statusChannel
+		return
+	}
+
+	rows, err := requestDB(r).Query(sql, query.arguments...)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to query database")
+		errorHandler <- fmt.Errorf("unable to query database: %w", err)
+		<-statusChannel
+		return
+	}
+
+	var anomalies []types.Anomaly
+	err = scan.Rows(&anomalies, rows)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to scan query results")
+		errorHandler <- fmt.Errorf("unable to scan query results: %w", err)
+		<-statusChannel
+		return
+	}
+
+	if len(anomalies) == 0 {
+		// since there are no anomalies that match the filters, return
+		// 204 No Content as response
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = encodeResponse(w, r, anomalies)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to encode anomalies into json")
+		errorHandler <- fmt.Errorf("unable to encode anomalies into json: %w", err)
+		<-statusChannel
+		return
+	}
+}
@@ -0,0 +1,66 @@
+package routes
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/blockloop/scan/v2"
+	"github.com/rs/zerolog/log"
+	wisdomMiddleware "github.com/wisdom-oss/microservice-middlewares/v3"
+
+	"github.com/wisdom-oss/service-consumers/types"
+)
+
+// APIKeyList returns all api keys including the revoked ones. The keys
+// themselves are not returned since only their hashes are stored
+func APIKeyList(w http.ResponseWriter, r *http.Request) {
+	// get the error handler and the error handler status channel
+	errorHandler := r.Context().Value(wisdomMiddleware.ERROR_CHANNEL_NAME).(chan<- interface{})
+	statusChannel := r.Context().Value(wisdomMiddleware.STATUS_CHANNEL_NAME).(<-chan bool)
+
+	query, err := newQueryBuilder("get-api-keys")
+	if err != nil {
+		log.Error().Err(err).Msg("unable to build sql")
+		errorHandler <- fmt.Errorf("unable to build sql: %w", err)
+		<-statusChannel
+		return
+	}
+	sql, err := query.build("order-api-keys")
+	if err != nil {
+		log.Error().Err(err).Msg("unable to build sql")
+		errorHandler <- fmt.Errorf("unable to build sql: %w", err)
+		<-statusChannel
+		return
+	}
+
+	rows, err := requestDB(r).Query(sql)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to query database")
+		errorHandler <- fmt.Errorf("unable to query database: %w", err)
+		<-statusChannel
+		return
+	}
+
+	var apiKeys []types.APIKey
+	err = scan.Rows(&apiKeys, rows)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to scan query results")
+		errorHandler <- fmt.Errorf("unable to scan query results: %w", err)
+		<-statusChannel
+		return
+	}
+
+	if len(apiKeys) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = encodeResponse(w, r, apiKeys)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to encode api keys into json")
+		errorHandler <- fmt.Errorf("unable to encode api keys into json: %w", err)
+		<-statusChannel
+		return
+	}
+}
@@ -0,0 +1,95 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/blockloop/scan/v2"
+	"github.com/lib/pq"
+	"github.com/rs/zerolog/log"
+	wisdomMiddleware "github.com/wisdom-oss/microservice-middlewares/v3"
+
+	"github.com/wisdom-oss/service-consumers/forecast"
+	"github.com/wisdom-oss/service-consumers/globals"
+	"github.com/wisdom-oss/service-consumers/types"
+)
+
+// AreaForecast forecasts the monthly usages of all consumers located in the
+// shapes supplied using the `in` query parameter. The forecast is based on the
+// summed up usage history of the consumers.
+// The forecast can be configured using the following query parameters:
+//   - horizon
+//   - level
+//   - method
+//   - from
+//   - to
+func AreaForecast(w http.ResponseWriter, r *http.Request) {
+	// get the error handler and the error handler status channel
+	errorHandler := r.Context().Value(wisdomMiddleware.ERROR_CHANNEL_NAME).(chan<- interface{})
+	statusChannel := r.Context().Value(wisdomMiddleware.STATUS_CHANNEL_NAME).(<-chan bool)
+
+	shapeKeys, shapeKeysSet := r.URL.Query()["in"]
+	if !shapeKeysSet {
+		errorHandler <- "MISSING_SHAPE_KEYS"
+		<-statusChannel
+		return
+	}
+	// users restricted to areas may only aggregate the usages inside their
+	// areas
+	shapeKeys = callerShapeKeys(r, shapeKeys)
+
+	parameters, errorCode := parseForecastParameters(r)
+	if errorCode != "" {
+		errorHandler <- errorCode
+		<-statusChannel
+		return
+	}
+	from, to, errorCode := parseTimeRange(r)
+	if errorCode != "" {
+		errorHandler <- errorCode
+		<-statusChannel
+		return
+	}
+
+	// now get the monthly usages of the area which are used as history for
+	// the forecast
+	rows, err := globals.SqlQueries.Query(requestDB(r), "aggregate-area-usages", "month", from, to, pq.Array(shapeKeys))
+	if err != nil {
+		log.Error().Err(err).Msg("unable to query database")
+		errorHandler <- fmt.Errorf("unable to query database: %w", err)
+		<-statusChannel
+		return
+	}
+
+	var aggregates []types.UsageAggregate
+	err = scan.Rows(&aggregates, rows)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to scan query results")
+		errorHandler <- fmt.Errorf("unable to scan query results: %w", err)
+		<-statusChannel
+		return
+	}
+
+	usageForecast, err := forecastUsages(aggregates, parameters)
+	if errors.Is(err, forecast.ErrInsufficientHistory) {
+		errorHandler <- "INSUFFICIENT_USAGE_HISTORY"
+		<-statusChannel
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("unable to forecast usages")
+		errorHandler <- fmt.Errorf("unable to forecast usages: %w", err)
+		<-statusChannel
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = encodeResponse(w, r, usageForecast)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to encode forecast into json")
+		errorHandler <- fmt.Errorf("unable to encode forecast into json: %w", err)
+		<-statusChannel
+		return
+	}
+}
@@ -0,0 +1,79 @@
+package routes
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/blockloop/scan/v2"
+	"github.com/lib/pq"
+	"github.com/rs/zerolog/log"
+	wisdomMiddleware "github.com/wisdom-oss/microservice-middlewares/v3"
+
+	"github.com/wisdom-oss/service-consumers/globals"
+	"github.com/wisdom-oss/service-consumers/types"
+)
+
+// AreaUsageAggregate returns the usages of all consumers located in the
+// shapes supplied using the `in` query parameter aggregated into time buckets.
+// The aggregation can be configured using the following query parameters:
+//   - interval
+//   - from
+//   - to
+//
+// Time buckets without any usages are contained in the response with a total
+// of zero to allow displaying gaps in the recorded usages
+func AreaUsageAggregate(w http.ResponseWriter, r *http.Request) {
+	// get the error handler and the error handler status channel
+	errorHandler := r.Context().Value(wisdomMiddleware.ERROR_CHANNEL_NAME).(chan<- interface{})
+	statusChannel := r.Context().Value(wisdomMiddleware.STATUS_CHANNEL_NAME).(<-chan bool)
+
+	shapeKeys, shapeKeysSet := r.URL.Query()["in"]
+	if !shapeKeysSet {
+		errorHandler <- "MISSING_SHAPE_KEYS"
+		<-statusChannel
+		return
+	}
+	// users restricted to areas may only aggregate the usages inside their
+	// areas
+	shapeKeys = callerShapeKeys(r, shapeKeys)
+
+	interval, from, to, errorCode := parseAggregationParameters(r)
+	if errorCode != "" {
+		errorHandler <- errorCode
+		<-statusChannel
+		return
+	}
+
+	rows, err := globals.SqlQueries.Query(requestDB(r), "aggregate-area-usages", interval, from, to, pq.Array(shapeKeys))
+	if err != nil {
+		log.Error().Err(err).Msg("unable to query database")
+		errorHandler <- fmt.Errorf("unable to query database: %w", err)
+		<-statusChannel
+		return
+	}
+
+	var aggregates []types.UsageAggregate
+	err = scan.Rows(&aggregates, rows)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to scan query results")
+		errorHandler <- fmt.Errorf("unable to scan query results: %w", err)
+		<-statusChannel
+		return
+	}
+
+	if len(aggregates) == 0 {
+		// since there are no usages in the area and no time range has been
+		// supplied, there are no buckets to return
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = encodeResponse(w, r, aggregates)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to encode usage aggregates into json")
+		errorHandler <- fmt.Errorf("unable to encode usage aggregates into json: %w", err)
+		<-statusChannel
+		return
+	}
+}
@@ -0,0 +1,62 @@
+package routes
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/blockloop/scan/v2"
+	"github.com/lib/pq"
+	"github.com/paulmach/go.geojson"
+	"github.com/qustavo/dotsql"
+
+	"github.com/wisdom-oss/service-consumers/auth"
+	"github.com/wisdom-oss/service-consumers/globals"
+)
+
+// restrictToCallerArea adds a filter to the query which only matches the
+// consumers located inside the areas of the user sending the request. The
+// query is not changed for unrestricted users
+func restrictToCallerArea(query *queryBuilder, r *http.Request) error {
+	principal := auth.PrincipalFromContext(r.Context())
+	if !principal.Restricted() {
+		return nil
+	}
+	return query.addFilter("filter-location", pq.Array(principal.Areas))
+}
+
+// callerShapeKeys limits the requested shape keys to the areas of the user
+// sending the request. The keys are not changed for unrestricted users
+func callerShapeKeys(r *http.Request, shapeKeys []string) []string {
+	principal := auth.PrincipalFromContext(r.Context())
+	if !principal.Restricted() {
+		return shapeKeys
+	}
+	allowedKeys := make([]string, 0, len(shapeKeys))
+	for _, key := range shapeKeys {
+		if slices.Contains(principal.Areas, key) {
+			allowedKeys = append(allowedKeys, key)
+		}
+	}
+	return allowedKeys
+}
+
+// locationInCallerArea checks if the location is inside the areas of the user
+// sending the request. Locations are always accepted for unrestricted users,
+// while missing locations are rejected for restricted users since they cannot
+// be assigned to an area
+func locationInCallerArea(db dotsql.Queryer, r *http.Request, location *geojson.Geometry) (bool, error) {
+	principal := auth.PrincipalFromContext(r.Context())
+	if !principal.Restricted() {
+		return true, nil
+	}
+	if location == nil {
+		return false, nil
+	}
+	rows, err := globals.SqlQueries.Query(db, "location-in-area", location, pq.Array(principal.Areas))
+	if err != nil {
+		return false, err
+	}
+	var inArea bool
+	err = scan.Row(&inArea, rows)
+	return inArea, err
+}
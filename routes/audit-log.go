@@ -0,0 +1,134 @@
+package routes
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/blockloop/scan/v2"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/rs/zerolog/log"
+	wisdomMiddleware "github.com/wisdom-oss/microservice-middlewares/v3"
+
+	"github.com/wisdom-oss/service-consumers/types"
+)
+
+// AuditLog returns the entries of the audit log. The audit log is only
+// accessible for users with the admin scope.
+// The entries can be filtered by using the following query parameters:
+//   - user
+//   - consumer
+//   - from
+//   - to
+func AuditLog(w http.ResponseWriter, r *http.Request) {
+	// get the error handler and the error handler status channel
+	errorHandler := r.Context().Value(wisdomMiddleware.ERROR_CHANNEL_NAME).(chan<- interface{})
+	statusChannel := r.Context().Value(wisdomMiddleware.STATUS_CHANNEL_NAME).(<-chan bool)
+
+	// get the different sql parameters
+	users, usersSet := r.URL.Query()["user"]
+	consumerIDs, consumerIDsSet := r.URL.Query()["consumer"]
+	from, to, errorCode := parseTimeRange(r)
+	if errorCode != "" {
+		errorHandler <- errorCode
+		<-statusChannel
+		return
+	}
+
+	query, err := newQueryBuilder("get-audit-entries")
+	if err != nil {
+		log.Error().Err(err).Msg("unable to build sql")
+		errorHandler <- fmt.Errorf("unable to build sql: %w", err)
+		<-statusChannel
+		return
+	}
+
+	if usersSet {
+		err = query.addFilter("filter-audit-users", pq.Array(users))
+		if err != nil {
+			log.Error().Err(err).Msg("unable to load filter sql")
+			errorHandler <- fmt.Errorf("unable to load filter sql: %w", err)
+			<-statusChannel
+			return
+		}
+	}
+
+	if consumerIDsSet {
+		for _, consumerID := range consumerIDs {
+			_, err = uuid.Parse(consumerID)
+			if err != nil {
+				errorHandler <- "INVALID_UUID_IN_FILTER"
+				<-statusChannel
+				return
+			}
+		}
+		err = query.addFilter("filter-audit-consumers", pq.Array(consumerIDs))
+		if err != nil {
+			log.Error().Err(err).Msg("unable to load filter sql")
+			errorHandler <- fmt.Errorf("unable to load filter sql: %w", err)
+			<-statusChannel
+			return
+		}
+	}
+
+	if from != nil {
+		err = query.addFilter("filter-audit-from", from)
+		if err != nil {
+			log.Error().Err(err).Msg("unable to load filter sql")
+			errorHandler <- fmt.Errorf("unable to load filter sql: %w", err)
+			<-statusChannel
+			return
+		}
+	}
+
+	if to != nil {
+		err = query.addFilter("filter-audit-to", to)
+		if err != nil {
+			log.Error().Err(err).Msg("unable to load filter sql")
+			errorHandler <- fmt.Errorf("unable to load filter sql: %w", err)
+			<-statusChannel
+			return
+		}
+	}
+
+	sql, err := query.build("order-audit-entries")
+	if err != nil {
+		log.Error().Err(err).Msg("unable to build sql")
+		errorHandler <- fmt.Errorf("unable to build sql: %w", err)
+		<-statusChannel
+		return
+	}
+
+	rows, err := requestDB(r).Query(sql, query.arguments...)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to query database")
+		errorHandler <- fmt.Errorf("unable to query database: %w", err)
+		<-statusChannel
+		return
+	}
+
+	var entries []types.AuditEntry
+	err = scan.Rows(&entries, rows)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to scan query results")
+		errorHandler <- fmt.Errorf("unable to scan query results: %w", err)
+		<-statusChannel
+		return
+	}
+
+	if len(entries) == 0 {
+		// since there are no audit entries that match the filters, return
+		// 204 No Content as response
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = encodeResponse(w, r, entries)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to encode audit entries into json")
+		errorHandler <- fmt.Errorf("unable to encode audit entries into json: %w", err)
+		<-statusChannel
+		return
+	}
+}
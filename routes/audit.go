@@ -0,0 +1,84 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/qustavo/dotsql"
+
+	"github.com/wisdom-oss/service-consumers/auth"
+	"github.com/wisdom-oss/service-consumers/globals"
+	"github.com/wisdom-oss/service-consumers/types"
+)
+
+// auditIgnoredFields contains the fields that are not compared when building
+// the field-level diff since they only contain metadata of the change itself
+// or secrets which must not be written to the audit log
+var auditIgnoredFields = []string{"version", "operation", "validFrom", "validUntil", "changedBy", "secret", "key"}
+
+// writeAuditEntry records the change made by the request in the audit log.
+// The field-level diff is built from the json representations of the resource
+// before and after the change. Resources that did not exist before or after
+// the change are supplied as nil
+func writeAuditEntry(db dotsql.Execer, r *http.Request, action string, consumerID *uuid.UUID, before interface{}, after interface{}) error {
+	changes, err := diffResources(before, after)
+	if err != nil {
+		return err
+	}
+
+	principal := auth.PrincipalFromContext(r.Context())
+	_, err = globals.SqlQueries.Exec(db, "insert-audit-entry",
+		principal.User,
+		pq.Array(principal.Groups),
+		middleware.GetReqID(r.Context()),
+		action,
+		consumerID,
+		changes,
+	)
+	return err
+}
+
+// diffResources compares the json representations of the supplied resources
+// and returns the changed fields mapped to their old and new values
+func diffResources(before interface{}, after interface{}) (types.Map, error) {
+	beforeFields, err := resourceFields(before)
+	if err != nil {
+		return nil, err
+	}
+	afterFields, err := resourceFields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := types.Map{}
+	for field, oldValue := range beforeFields {
+		if newValue := afterFields[field]; !reflect.DeepEqual(oldValue, newValue) {
+			changes[field] = map[string]interface{}{"old": oldValue, "new": newValue}
+		}
+	}
+	for field, newValue := range afterFields {
+		if _, existedBefore := beforeFields[field]; !existedBefore && newValue != nil {
+			changes[field] = map[string]interface{}{"old": nil, "new": newValue}
+		}
+	}
+	for _, field := range auditIgnoredFields {
+		delete(changes, field)
+	}
+	return changes, nil
+}
+
+// resourceFields converts the resource into a map containing the fields of
+// its json representation
+func resourceFields(resource interface{}) (map[string]interface{}, error) {
+	encodedResource, err := json.Marshal(resource)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]interface{}
+	err = json.Unmarshal(encodedResource, &fields)
+	return fields, err
+}
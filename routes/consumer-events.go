@@ -0,0 +1,154 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	wisdomMiddleware "github.com/wisdom-oss/microservice-middlewares/v3"
+
+	"github.com/wisdom-oss/service-consumers/auth"
+	"github.com/wisdom-oss/service-consumers/events"
+	"github.com/wisdom-oss/service-consumers/globals"
+	"github.com/wisdom-oss/service-consumers/types"
+)
+
+// keepAliveInterval contains the interval in which comments are sent to the
+// clients to keep idle connections open
+const keepAliveInterval = 30 * time.Second
+
+// ConsumerEvents returns a handler that streams the changes of consumers to
+// the client using Server-Sent Events.
+// Every event contains the id of the recorded consumer version which allows
+// clients to resume the stream by sending the `Last-Event-ID` header
+func ConsumerEvents(hub *events.Hub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// get the error handler and the error handler status channel
+		errorHandler := r.Context().Value(wisdomMiddleware.ERROR_CHANNEL_NAME).(chan<- interface{})
+		statusChannel := r.Context().Value(wisdomMiddleware.STATUS_CHANNEL_NAME).(<-chan bool)
+
+		var lastEventID int64 = -1
+		if rawLastEventID := r.Header.Get("Last-Event-ID"); rawLastEventID != "" {
+			var err error
+			lastEventID, err = strconv.ParseInt(rawLastEventID, 10, 64)
+			if err != nil {
+				errorHandler <- "INVALID_LAST_EVENT_ID"
+				<-statusChannel
+				return
+			}
+		}
+
+		// subscribe before reading the missed events to ensure that no event
+		// is lost between both steps
+		subscription, unsubscribe := hub.Subscribe()
+		defer unsubscribe()
+
+		var missedEvents []types.ConsumerEvent
+		if lastEventID >= 0 {
+			var err error
+			missedEvents, err = events.EventsSince(r.Context(), requestDB(r), lastEventID)
+			if err != nil {
+				log.Error().Err(err).Msg("unable to get the missed consumer events")
+				errorHandler <- fmt.Errorf("unable to get the missed consumer events: %w", err)
+				<-statusChannel
+				return
+			}
+		}
+
+		// the stream does not access the database anymore. the connection of
+		// the request is released to not occupy it while the stream is open
+		requestDB(r).Release()
+		tenant := auth.PrincipalFromContext(r.Context()).Tenant
+
+		// since the stream is kept open, the write deadline of the server
+		// is removed for this response
+		controller := http.NewResponseController(w)
+		_ = controller.SetWriteDeadline(time.Time{})
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.WriteHeader(http.StatusOK)
+		err := controller.Flush()
+		if err != nil {
+			log.Error().Err(err).Msg("response writer does not support streaming")
+			return
+		}
+
+		for _, event := range missedEvents {
+			if !consumerEventVisible(r, event) {
+				continue
+			}
+			err = writeConsumerEvent(w, r, event)
+			if err != nil {
+				return
+			}
+			lastEventID = event.Version
+		}
+		_ = controller.Flush()
+
+		keepAlive := time.NewTicker(keepAliveInterval)
+		defer keepAlive.Stop()
+
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case event, subscribed := <-subscription:
+				if !subscribed {
+					// the subscription has been dropped since the client did
+					// not keep up. closing the stream lets the client resume
+					return
+				}
+				// skip the events that already have been sent while sending
+				// the missed events and the events of other tenants
+				if event.Version <= lastEventID || event.Tenant != tenant {
+					continue
+				}
+				if !consumerEventVisible(r, event) {
+					continue
+				}
+				err = writeConsumerEvent(w, r, event)
+				if err != nil {
+					return
+				}
+				lastEventID = event.Version
+			case <-keepAlive.C:
+				_, err = fmt.Fprint(w, ": keep-alive\n\n")
+				if err != nil {
+					return
+				}
+			}
+			_ = controller.Flush()
+		}
+	}
+}
+
+// consumerEventVisible checks if the consumer contained in the event is
+// located inside the areas of the user sending the request. Since the
+// connection of the request has been released, the areas are checked using
+// the connection pool. Events that cannot be checked are not sent
+func consumerEventVisible(r *http.Request, event types.ConsumerEvent) bool {
+	visible, err := locationInCallerArea(globals.Db, r, event.Location)
+	if err != nil {
+		log.Warn().Err(err).Int64("version", event.Version).Msg("unable to check if consumer event is inside the areas of the user")
+		return false
+	}
+	return visible
+}
+
+// writeConsumerEvent writes a single event using the Server-Sent Events format.
+// The consumer contained in the event is redacted for users without the pii
+// scope
+func writeConsumerEvent(w http.ResponseWriter, r *http.Request, event types.ConsumerEvent) error {
+	data, err := json.Marshal(redactResponse(r, event.ConsumerVersion))
+	if err != nil {
+		log.Error().Err(err).Msg("unable to encode consumer event into json")
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.Version, event.Event, data)
+	return err
+}
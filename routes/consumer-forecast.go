@@ -0,0 +1,106 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/blockloop/scan/v2"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	wisdomMiddleware "github.com/wisdom-oss/microservice-middlewares/v3"
+
+	"github.com/wisdom-oss/service-consumers/forecast"
+	"github.com/wisdom-oss/service-consumers/globals"
+	"github.com/wisdom-oss/service-consumers/types"
+)
+
+// ConsumerForecast forecasts the monthly usages of a single consumer based on
+// the consumer's usage history.
+// The forecast can be configured using the following query parameters:
+//   - horizon
+//   - level
+//   - method
+//   - from
+//   - to
+func ConsumerForecast(w http.ResponseWriter, r *http.Request) {
+	// get the error handler and the error handler status channel
+	errorHandler := r.Context().Value(wisdomMiddleware.ERROR_CHANNEL_NAME).(chan<- interface{})
+	statusChannel := r.Context().Value(wisdomMiddleware.STATUS_CHANNEL_NAME).(<-chan bool)
+
+	// now get the consumer id from the url and validate it
+	consumerID, err := uuid.Parse(chi.URLParam(r, "consumer-id"))
+	if err != nil {
+		errorHandler <- "INVALID_CONSUMER_ID"
+		<-statusChannel
+		return
+	}
+
+	parameters, errorCode := parseForecastParameters(r)
+	if errorCode != "" {
+		errorHandler <- errorCode
+		<-statusChannel
+		return
+	}
+	from, to, errorCode := parseTimeRange(r)
+	if errorCode != "" {
+		errorHandler <- errorCode
+		<-statusChannel
+		return
+	}
+
+	exists, err := consumerExists(requestDB(r), r, consumerID)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to check if consumer exists")
+		errorHandler <- fmt.Errorf("unable to check if consumer exists: %w", err)
+		<-statusChannel
+		return
+	}
+	if !exists {
+		errorHandler <- "UNKNOWN_CONSUMER"
+		<-statusChannel
+		return
+	}
+
+	// now get the monthly usages of the consumer which are used as history
+	// for the forecast
+	rows, err := globals.SqlQueries.Query(requestDB(r), "aggregate-consumer-usages", "month", from, to, consumerID)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to query database")
+		errorHandler <- fmt.Errorf("unable to query database: %w", err)
+		<-statusChannel
+		return
+	}
+
+	var aggregates []types.UsageAggregate
+	err = scan.Rows(&aggregates, rows)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to scan query results")
+		errorHandler <- fmt.Errorf("unable to scan query results: %w", err)
+		<-statusChannel
+		return
+	}
+
+	usageForecast, err := forecastUsages(aggregates, parameters)
+	if errors.Is(err, forecast.ErrInsufficientHistory) {
+		errorHandler <- "INSUFFICIENT_USAGE_HISTORY"
+		<-statusChannel
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("unable to forecast usages")
+		errorHandler <- fmt.Errorf("unable to forecast usages: %w", err)
+		<-statusChannel
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = encodeResponse(w, r, usageForecast)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to encode forecast into json")
+		errorHandler <- fmt.Errorf("unable to encode forecast into json: %w", err)
+		<-statusChannel
+		return
+	}
+}
@@ -0,0 +1,71 @@
+package routes
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/blockloop/scan/v2"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/rs/zerolog/log"
+	wisdomMiddleware "github.com/wisdom-oss/microservice-middlewares/v3"
+
+	"github.com/wisdom-oss/service-consumers/auth"
+	"github.com/wisdom-oss/service-consumers/globals"
+	"github.com/wisdom-oss/service-consumers/types"
+)
+
+// ConsumerHistory returns all versions of a consumer that have been recorded
+// in the consumer history ordered from the oldest to the newest version.
+// The history of deleted and merged consumers is returned as well. Consumers
+// whose last recorded location is outside the areas of the user are reported
+// as unknown
+func ConsumerHistory(w http.ResponseWriter, r *http.Request) {
+	// get the error handler and the error handler status channel
+	errorHandler := r.Context().Value(wisdomMiddleware.ERROR_CHANNEL_NAME).(chan<- interface{})
+	statusChannel := r.Context().Value(wisdomMiddleware.STATUS_CHANNEL_NAME).(<-chan bool)
+
+	// now get the consumer id from the url and validate it
+	consumerID, err := uuid.Parse(chi.URLParam(r, "consumer-id"))
+	if err != nil {
+		errorHandler <- "INVALID_CONSUMER_ID"
+		<-statusChannel
+		return
+	}
+
+	areas := auth.PrincipalFromContext(r.Context()).Areas
+	rows, err := globals.SqlQueries.Query(requestDB(r), "get-consumer-history", consumerID, pq.Array(areas))
+	if err != nil {
+		log.Error().Err(err).Msg("unable to query database")
+		errorHandler <- fmt.Errorf("unable to query database: %w", err)
+		<-statusChannel
+		return
+	}
+
+	var versions []types.ConsumerVersion
+	err = scan.Rows(&versions, rows)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to scan query results")
+		errorHandler <- fmt.Errorf("unable to scan query results: %w", err)
+		<-statusChannel
+		return
+	}
+
+	// since every consumer has at least one version, an empty history
+	// indicates that the consumer never existed or is not visible to the user
+	if len(versions) == 0 {
+		errorHandler <- "UNKNOWN_CONSUMER"
+		<-statusChannel
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = encodeResponse(w, r, versions)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to encode consumer history into json")
+		errorHandler <- fmt.Errorf("unable to encode consumer history into json: %w", err)
+		<-statusChannel
+		return
+	}
+}
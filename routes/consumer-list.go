@@ -1,11 +1,9 @@
 package routes
 
 import (
-	"encoding/json"
 	"fmt"
 	"net/http"
 	"strconv"
-	"strings"
 
 	"github.com/blockloop/scan/v2"
 	"github.com/google/uuid"
@@ -13,7 +11,8 @@ import (
 	"github.com/rs/zerolog/log"
 	wisdomMiddleware "github.com/wisdom-oss/microservice-middlewares/v3"
 
-	"github.com/wisdom-oss/service-consumers/globals"
+	"github.com/wisdom-oss/service-consumers/metrics"
+	"github.com/wisdom-oss/service-consumers/tracing"
 	"github.com/wisdom-oss/service-consumers/types"
 )
 
@@ -23,6 +22,12 @@ import (
 //   - in
 //   - id
 //   - usageAbove
+//
+// The usage types of the consumers may be embedded into the response by
+// setting the `expand` query parameter to `usageType`.
+// The `asOf` query parameter allows reconstructing the consumers as they have
+// been at the supplied point in time.
+// Users restricted to areas only receive the consumers inside their areas
 func ConsumerList(w http.ResponseWriter, r *http.Request) {
 	// get the error handler and the error handler status channel
 	errorHandler := r.Context().Value(wisdomMiddleware.ERROR_CHANNEL_NAME).(chan<- interface{})
@@ -33,6 +38,20 @@ func ConsumerList(w http.ResponseWriter, r *http.Request) {
 	consumerIDs, consumerIDsSet := r.URL.Query()["id"]
 	minimalUsages, minimalUsagesSet := r.URL.Query()["usageAbove"]
 
+	expandUsageType, errorCode := parseExpansions(r)
+	if errorCode != "" {
+		errorHandler <- errorCode
+		<-statusChannel
+		return
+	}
+
+	asOf, errorCode := parseAsOf(r)
+	if errorCode != "" {
+		errorHandler <- errorCode
+		<-statusChannel
+		return
+	}
+
 	/*
 			The following check is only done to issue a deprecation warning when
 			using the API in a deprecated way.
@@ -43,38 +62,43 @@ func ConsumerList(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Warning", `299 consumer-management "Selecting a single consumer using the id filter is deprecated. Please use the /{consumer-id} endpoint"`)
 	}
 
-	// now build the sql using the pulled sql parameters
-	sql, err := globals.SqlQueries.Raw("get-consumers")
+	// now build the sql using the pulled sql parameters. if a point in time
+	// has been requested, the consumers are reconstructed from the consumer
+	// history
+	baseQueryName := "get-consumers"
+	var baseArguments []interface{}
+	if asOf != nil {
+		baseQueryName = "get-consumers-as-of"
+		baseArguments = append(baseArguments, asOf)
+	}
+	query, err := newQueryBuilder(baseQueryName, baseArguments...)
 	if err != nil {
 		log.Error().Err(err).Msg("unable to build sql")
 		errorHandler <- fmt.Errorf("unable to build sql: %w", err)
 		<-statusChannel
 		return
 	}
-	activeFilters := 0
-	var arguments []interface{}
 	// now check every filter option if they have been specified
 	if shapeKeysSet {
-		activeFilters++
-		filter, err := globals.SqlQueries.Raw("filter-location")
+		err = query.addFilter("filter-location", pq.Array(shapeKeys))
 		if err != nil {
 			log.Error().Err(err).Msg("unable to load filter sql")
 			errorHandler <- fmt.Errorf("unable to load filter sql: %w", err)
 			<-statusChannel
 			return
 		}
-		filter = strings.ReplaceAll(filter, "$1", fmt.Sprintf("$%d", activeFilters))
-		if !strings.Contains(sql, "WHERE") {
-			sql = strings.Trim(sql, ";")
-			sql += fmt.Sprintf(" WHERE %s", filter)
-		} else {
-			sql += fmt.Sprintf(" AND %s", filter)
-		}
-		arguments = append(arguments, pq.Array(shapeKeys))
+	}
+
+	// users restricted to areas only see the consumers inside their areas
+	err = restrictToCallerArea(query, r)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to load filter sql")
+		errorHandler <- fmt.Errorf("unable to load filter sql: %w", err)
+		<-statusChannel
+		return
 	}
 
 	if consumerIDsSet {
-		activeFilters++
 		for _, consumerID := range consumerIDs {
 			_, err = uuid.Parse(consumerID)
 			if err != nil {
@@ -83,25 +107,16 @@ func ConsumerList(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 		}
-		filter, err := globals.SqlQueries.Raw("filter-ids")
+		err = query.addFilter("filter-ids", pq.Array(consumerIDs))
 		if err != nil {
 			log.Error().Err(err).Msg("unable to load filter sql")
 			errorHandler <- fmt.Errorf("unable to load filter sql: %w", err)
 			<-statusChannel
 			return
 		}
-		filter = strings.ReplaceAll(filter, "$1", fmt.Sprintf("$%d", activeFilters))
-		if !strings.Contains(sql, "WHERE") {
-			sql = strings.Trim(sql, ";")
-			sql += fmt.Sprintf(" WHERE %s", filter)
-		} else {
-			sql += fmt.Sprintf(" AND %s", filter)
-		}
-		arguments = append(arguments, pq.Array(consumerIDs))
 	}
 
 	if minimalUsagesSet {
-		activeFilters++
 		minimalUsageString := minimalUsages[0]
 		minimalUsage, err := strconv.ParseFloat(minimalUsageString, 64)
 		if err != nil {
@@ -109,35 +124,23 @@ func ConsumerList(w http.ResponseWriter, r *http.Request) {
 			<-statusChannel
 			return
 		}
-		filter, err := globals.SqlQueries.Raw("filter-usage-amount")
+		err = query.addFilter("filter-usage-amount", minimalUsage)
 		if err != nil {
 			log.Error().Err(err).Msg("unable to load filter sql")
 			errorHandler <- fmt.Errorf("unable to load filter sql: %w", err)
 			<-statusChannel
 			return
 		}
-		filter = strings.ReplaceAll(filter, "$1", fmt.Sprintf("$%d", activeFilters))
-		if !strings.Contains(sql, "WHERE") {
-			sql = strings.Trim(sql, ";")
-			sql += fmt.Sprintf(" WHERE %s", filter)
-		} else {
-			sql += fmt.Sprintf(" AND %s", filter)
-		}
-		arguments = append(arguments, minimalUsage)
 	}
 
-	// now prepare the query
-	sql = strings.ReplaceAll(sql, ";", "")
-	sql += ";"
-	query, err := globals.Db.Prepare(sql)
+	sql, err := query.build()
 	if err != nil {
-		log.Error().Err(err).Msg("unable to preparse database query")
-		errorHandler <- fmt.Errorf("unable to prepare database query: %w", err)
+		log.Error().Err(err).Msg("unable to build sql")
+		errorHandler <- fmt.Errorf("unable to build sql: %w", err)
 		<-statusChannel
 		return
 	}
-
-	rows, err := query.Query(arguments...)
+	rows, err := requestDB(r).Query(sql, query.arguments...)
 	if err != nil {
 		log.Error().Err(err).Msg("unable to query database")
 		errorHandler <- fmt.Errorf("unable to query database: %w", err)
@@ -147,7 +150,9 @@ func ConsumerList(w http.ResponseWriter, r *http.Request) {
 
 	// now scan the results
 	var consumers []types.Consumer
+	_, scanSpan := tracing.Start(r.Context(), "scan consumers")
 	err = scan.Rows(&consumers, rows)
+	scanSpan.End()
 	if err != nil {
 		log.Error().Err(err).Msg("unable to scan query results")
 		errorHandler <- fmt.Errorf("unable to scan query results: %w", err)
@@ -155,6 +160,7 @@ func ConsumerList(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	metrics.ObserveListedConsumers(len(consumers))
 	if len(consumers) == 0 {
 		// since there are no consumers that match the filters, return
 		// 204 No Content as response
@@ -162,9 +168,19 @@ func ConsumerList(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if expandUsageType {
+		err = expandUsageTypes(requestDB(r), consumers)
+		if err != nil {
+			log.Error().Err(err).Msg("unable to expand usage types")
+			errorHandler <- fmt.Errorf("unable to expand usage types: %w", err)
+			<-statusChannel
+			return
+		}
+	}
+
 	// now return the consumers
 	w.Header().Set("Content-Type", "application/json")
-	err = json.NewEncoder(w).Encode(consumers)
+	err = encodeResponse(w, r, consumers)
 	if err != nil {
 		log.Error().Err(err).Msg("unable to encode consumers into json")
 		errorHandler <- fmt.Errorf("unable to encode consumers into json: %w", err)
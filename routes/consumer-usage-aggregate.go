@@ -0,0 +1,91 @@
+package routes
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/blockloop/scan/v2"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	wisdomMiddleware "github.com/wisdom-oss/microservice-middlewares/v3"
+
+	"github.com/wisdom-oss/service-consumers/globals"
+	"github.com/wisdom-oss/service-consumers/types"
+)
+
+// ConsumerUsageAggregate returns the usages of a single consumer aggregated
+// into time buckets.
+// The aggregation can be configured using the following query parameters:
+//   - interval
+//   - from
+//   - to
+//
+// Time buckets without any usages are contained in the response with a total
+// of zero to allow displaying gaps in the recorded usages
+func ConsumerUsageAggregate(w http.ResponseWriter, r *http.Request) {
+	// get the error handler and the error handler status channel
+	errorHandler := r.Context().Value(wisdomMiddleware.ERROR_CHANNEL_NAME).(chan<- interface{})
+	statusChannel := r.Context().Value(wisdomMiddleware.STATUS_CHANNEL_NAME).(<-chan bool)
+
+	// now get the consumer id from the url and validate it
+	consumerID, err := uuid.Parse(chi.URLParam(r, "consumer-id"))
+	if err != nil {
+		errorHandler <- "INVALID_CONSUMER_ID"
+		<-statusChannel
+		return
+	}
+
+	interval, from, to, errorCode := parseAggregationParameters(r)
+	if errorCode != "" {
+		errorHandler <- errorCode
+		<-statusChannel
+		return
+	}
+
+	exists, err := consumerExists(requestDB(r), r, consumerID)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to check if consumer exists")
+		errorHandler <- fmt.Errorf("unable to check if consumer exists: %w", err)
+		<-statusChannel
+		return
+	}
+	if !exists {
+		errorHandler <- "UNKNOWN_CONSUMER"
+		<-statusChannel
+		return
+	}
+
+	rows, err := globals.SqlQueries.Query(requestDB(r), "aggregate-consumer-usages", interval, from, to, consumerID)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to query database")
+		errorHandler <- fmt.Errorf("unable to query database: %w", err)
+		<-statusChannel
+		return
+	}
+
+	var aggregates []types.UsageAggregate
+	err = scan.Rows(&aggregates, rows)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to scan query results")
+		errorHandler <- fmt.Errorf("unable to scan query results: %w", err)
+		<-statusChannel
+		return
+	}
+
+	if len(aggregates) == 0 {
+		// since the consumer has no usages and no time range has been
+		// supplied, there are no buckets to return
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = encodeResponse(w, r, aggregates)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to encode usage aggregates into json")
+		errorHandler <- fmt.Errorf("unable to encode usage aggregates into json: %w", err)
+		<-statusChannel
+		return
+	}
+}
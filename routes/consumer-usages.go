@@ -0,0 +1,155 @@
+package routes
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/blockloop/scan/v2"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/rs/zerolog/log"
+	wisdomMiddleware "github.com/wisdom-oss/microservice-middlewares/v3"
+
+	"github.com/wisdom-oss/service-consumers/types"
+)
+
+// ConsumerUsages returns the usage records that have been recorded for a
+// single consumer.
+// The records can be filtered by using the following query parameters:
+//   - from
+//   - to
+//   - usageType
+func ConsumerUsages(w http.ResponseWriter, r *http.Request) {
+	// get the error handler and the error handler status channel
+	errorHandler := r.Context().Value(wisdomMiddleware.ERROR_CHANNEL_NAME).(chan<- interface{})
+	statusChannel := r.Context().Value(wisdomMiddleware.STATUS_CHANNEL_NAME).(<-chan bool)
+
+	// now get the consumer id from the url and validate it
+	consumerID, err := uuid.Parse(chi.URLParam(r, "consumer-id"))
+	if err != nil {
+		errorHandler <- "INVALID_CONSUMER_ID"
+		<-statusChannel
+		return
+	}
+
+	// check if the consumer exists before querying the usages to allow
+	// distinguishing between an unknown consumer and a consumer without usages
+	exists, err := consumerExists(requestDB(r), r, consumerID)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to check if consumer exists")
+		errorHandler <- fmt.Errorf("unable to check if consumer exists: %w", err)
+		<-statusChannel
+		return
+	}
+	if !exists {
+		errorHandler <- "UNKNOWN_CONSUMER"
+		<-statusChannel
+		return
+	}
+
+	// get the different sql parameters
+	from, fromSet := r.URL.Query()["from"]
+	to, toSet := r.URL.Query()["to"]
+	usageTypes, usageTypesSet := r.URL.Query()["usageType"]
+
+	query, err := newQueryBuilder("get-usage-records", consumerID)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to build sql")
+		errorHandler <- fmt.Errorf("unable to build sql: %w", err)
+		<-statusChannel
+		return
+	}
+
+	if fromSet {
+		timestamp, err := parseTimestamp(from[0])
+		if err != nil {
+			errorHandler <- "INVALID_TIMESTAMP"
+			<-statusChannel
+			return
+		}
+		err = query.addFilter("filter-usage-from", timestamp)
+		if err != nil {
+			log.Error().Err(err).Msg("unable to load filter sql")
+			errorHandler <- fmt.Errorf("unable to load filter sql: %w", err)
+			<-statusChannel
+			return
+		}
+	}
+
+	if toSet {
+		timestamp, err := parseTimestamp(to[0])
+		if err != nil {
+			errorHandler <- "INVALID_TIMESTAMP"
+			<-statusChannel
+			return
+		}
+		err = query.addFilter("filter-usage-to", timestamp)
+		if err != nil {
+			log.Error().Err(err).Msg("unable to load filter sql")
+			errorHandler <- fmt.Errorf("unable to load filter sql: %w", err)
+			<-statusChannel
+			return
+		}
+	}
+
+	if usageTypesSet {
+		for _, usageType := range usageTypes {
+			_, err = uuid.Parse(usageType)
+			if err != nil {
+				errorHandler <- "INVALID_UUID_IN_FILTER"
+				<-statusChannel
+				return
+			}
+		}
+		err = query.addFilter("filter-usage-types", pq.Array(usageTypes))
+		if err != nil {
+			log.Error().Err(err).Msg("unable to load filter sql")
+			errorHandler <- fmt.Errorf("unable to load filter sql: %w", err)
+			<-statusChannel
+			return
+		}
+	}
+
+	sql, err := query.build("order-usage-records")
+	if err != nil {
+		log.Error().Err(err).Msg("unable to build sql")
+		errorHandler <- fmt.Errorf("unable to build sql: %w", err)
+		<-statusChannel
+		return
+	}
+
+	rows, err := requestDB(r).Query(sql, query.arguments...)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to query database")
+		errorHandler <- fmt.Errorf("unable to query database: %w", err)
+		<-statusChannel
+		return
+	}
+
+	// now scan the results
+	var records []types.UsageRecord
+	err = scan.Rows(&records, rows)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to scan query results")
+		errorHandler <- fmt.Errorf("unable to scan query results: %w", err)
+		<-statusChannel
+		return
+	}
+
+	if len(records) == 0 {
+		// since there are no usages that match the filters, return
+		// 204 No Content as response
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = encodeResponse(w, r, records)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to encode usage records into json")
+		errorHandler <- fmt.Errorf("unable to encode usage records into json: %w", err)
+		<-statusChannel
+		return
+	}
+}
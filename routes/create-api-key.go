@@ -0,0 +1,140 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/blockloop/scan/v2"
+	"github.com/rs/zerolog/log"
+	wisdomMiddleware "github.com/wisdom-oss/microservice-middlewares/v3"
+
+	"github.com/wisdom-oss/service-consumers/auth"
+	"github.com/wisdom-oss/service-consumers/globals"
+	"github.com/wisdom-oss/service-consumers/types"
+)
+
+// CreateAPIKey creates a new api key for a machine client in the tenant of
+// the user. The key is only returned in the response of this request since
+// only its hash is stored
+func CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	// get the error handler and the error handler status channel
+	errorHandler := r.Context().Value(wisdomMiddleware.ERROR_CHANNEL_NAME).(chan<- interface{})
+	statusChannel := r.Context().Value(wisdomMiddleware.STATUS_CHANNEL_NAME).(<-chan bool)
+
+	var apiKey types.APIKey
+	err := json.NewDecoder(r.Body).Decode(&apiKey)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to decode request body into api key")
+		errorHandler <- requestBodyError(err, "unable to decode request body into api key")
+		<-statusChannel
+		return
+	}
+
+	if errorCode := validateAPIKey(apiKey); errorCode != "" {
+		errorHandler <- errorCode
+		<-statusChannel
+		return
+	}
+
+	key, prefix, hash, err := auth.GenerateAPIKey()
+	if err != nil {
+		log.Error().Err(err).Msg("unable to generate api key")
+		errorHandler <- fmt.Errorf("unable to generate api key: %w", err)
+		<-statusChannel
+		return
+	}
+
+	// the key is created in the tenant the user acts for
+	principal := auth.PrincipalFromContext(r.Context())
+	var createdBy *string
+	if principal.User != "" {
+		createdBy = &principal.User
+	}
+
+	tx, err := requestDB(r).BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to start database transaction")
+		errorHandler <- fmt.Errorf("unable to start database transaction: %w", err)
+		<-statusChannel
+		return
+	}
+
+	rows, err := globals.SqlQueries.Query(tx, "insert-api-key",
+		strings.TrimSpace(apiKey.Name),
+		prefix,
+		hash,
+		apiKey.Scopes,
+		apiKey.Areas,
+		apiKey.ExpiresAt,
+		createdBy,
+		principal.Tenant,
+	)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to insert the api key into the database")
+		errorHandler <- databaseError(err, "unable to insert the api key into the database")
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+
+	err = scan.Row(&apiKey, rows)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to get the inserted api key")
+		errorHandler <- databaseError(err, "unable to get the inserted api key")
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+
+	err = writeAuditEntry(tx, r, "create-api-key", nil, nil, apiKey)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to write audit entry")
+		errorHandler <- fmt.Errorf("unable to write audit entry: %w", err)
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		log.Error().Err(err).Msg("unable to commit changes to the database")
+		errorHandler <- fmt.Errorf("unable to commit changes to the database: %w", err)
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+
+	apiKey.Key = &key
+	w.Header().Set("Location", fmt.Sprintf("./%s", apiKey.ID.String()))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	err = encodeResponse(w, r, apiKey)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to encode api key into json")
+	}
+}
+
+// validateAPIKey checks that the api key has a name, only contains known
+// scopes and expires in the future. If the api key is invalid, the error code
+// describing the issue is returned
+func validateAPIKey(apiKey types.APIKey) string {
+	if strings.TrimSpace(apiKey.Name) == "" {
+		return "INVALID_API_KEY_NAME"
+	}
+	if len(apiKey.Scopes) == 0 {
+		return "INVALID_API_KEY_SCOPES"
+	}
+	for _, scope := range apiKey.Scopes {
+		if !slices.Contains(auth.Scopes, scope) {
+			return "INVALID_API_KEY_SCOPES"
+		}
+	}
+	if apiKey.ExpiresAt != nil && !apiKey.ExpiresAt.After(time.Now()) {
+		return "INVALID_API_KEY_EXPIRY"
+	}
+	return ""
+}
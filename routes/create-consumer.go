@@ -14,6 +14,9 @@ import (
 	"github.com/wisdom-oss/service-consumers/types"
 )
 
+// CreateNewConsumer inserts the consumer contained in the request body.
+// Before the consumer is created, it is checked for duplicates. Users with the
+// admin scope may skip this check by setting the `force` query parameter
 func CreateNewConsumer(w http.ResponseWriter, r *http.Request) {
 	// get the error handler and the error handler status channel
 	errorHandler := r.Context().Value(wisdomMiddleware.ERROR_CHANNEL_NAME).(chan<- interface{})
@@ -24,13 +27,34 @@ func CreateNewConsumer(w http.ResponseWriter, r *http.Request) {
 	err := json.NewDecoder(r.Body).Decode(&consumer)
 	if err != nil {
 		log.Error().Err(err).Msg("unable to decode request body into consumer")
-		errorHandler <- fmt.Errorf("unable to decode request body into consumer: %w", err)
+		errorHandler <- requestBodyError(err, "unable to decode request body into consumer")
+		<-statusChannel
+		return
+	}
+
+	force, errorCode := parseForceOverride(r)
+	if errorCode != "" {
+		errorHandler <- errorCode
+		<-statusChannel
+		return
+	}
+
+	// restricted users may only create consumers inside their areas
+	inArea, err := locationInCallerArea(requestDB(r), r, consumer.Location)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to check the location of the consumer")
+		errorHandler <- databaseError(err, "unable to check the location of the consumer")
+		<-statusChannel
+		return
+	}
+	if !inArea {
+		errorHandler <- "CONSUMER_OUTSIDE_AREA"
 		<-statusChannel
 		return
 	}
 
 	// now write the consumer into the database
-	tx, err := globals.Db.BeginTx(r.Context(), nil)
+	tx, err := requestDB(r).BeginTx(r.Context(), nil)
 	if err != nil {
 		log.Error().Err(err).Msg("unable to start database transaction")
 		errorHandler <- fmt.Errorf("unable to start database transaction: %w", err)
@@ -38,6 +62,52 @@ func CreateNewConsumer(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	err = setChangingUser(tx, r)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to set the changing user")
+		errorHandler <- fmt.Errorf("unable to set the changing user: %w", err)
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+
+	// now check that the usage type referenced by the consumer exists
+	usageTypeValid, err := usageTypesExist(tx, consumer.UsageType)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to check if the usage type exists")
+		errorHandler <- fmt.Errorf("unable to check if the usage type exists: %w", err)
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+	if !usageTypeValid {
+		errorHandler <- "UNKNOWN_USAGE_TYPE"
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+
+	// now check if the consumer already exists unless the duplicate detection
+	// has been overridden
+	if duplicateDetection.Enabled && !force {
+		duplicates, err := findDuplicateConsumers(tx, r, consumer)
+		if err != nil {
+			log.Error().Err(err).Msg("unable to check for duplicate consumers")
+			errorHandler <- databaseError(err, "unable to check for duplicate consumers")
+			<-statusChannel
+			tx.Rollback()
+			return
+		}
+		if len(duplicates) > 0 {
+			tx.Rollback()
+			err = sendDuplicateConsumerError(w, duplicates)
+			if err != nil {
+				log.Error().Err(err).Msg("unable to send duplicate consumer error")
+			}
+			return
+		}
+	}
+
 	res, err := globals.SqlQueries.Query(tx, "insert-consumer",
 		consumer.Name,
 		consumer.Description,
@@ -48,7 +118,7 @@ func CreateNewConsumer(w http.ResponseWriter, r *http.Request) {
 	)
 	if err != nil {
 		log.Error().Err(err).Msg("unable to insert the consumer into the database")
-		errorHandler <- fmt.Errorf("unable to insert the consumer into the database: %w", err)
+		errorHandler <- databaseError(err, "unable to insert the consumer into the database")
 		<-statusChannel
 		tx.Rollback()
 		return
@@ -59,7 +129,26 @@ func CreateNewConsumer(w http.ResponseWriter, r *http.Request) {
 	err = scan.Row(&consumerID, res)
 	if err != nil {
 		log.Error().Err(err).Msg("unable to get the inserted consumer id")
-		errorHandler <- fmt.Errorf("unable to get the inserted consumer id: %w", err)
+		errorHandler <- databaseError(err, "unable to get the inserted consumer id")
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+
+	consumer.ID = consumerID
+	err = writeAuditEntry(tx, r, "create-consumer", &consumerID, nil, consumer)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to write audit entry")
+		errorHandler <- fmt.Errorf("unable to write audit entry: %w", err)
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+
+	err = writeOutboxEvent(tx, "consumer.created", &consumerID, consumer)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to write outbox event")
+		errorHandler <- fmt.Errorf("unable to write outbox event: %w", err)
 		<-statusChannel
 		tx.Rollback()
 		return
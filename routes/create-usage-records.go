@@ -0,0 +1,184 @@
+package routes
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/rs/zerolog/log"
+	wisdomMiddleware "github.com/wisdom-oss/microservice-middlewares/v3"
+
+	"github.com/wisdom-oss/service-consumers/globals"
+	"github.com/wisdom-oss/service-consumers/metrics"
+	"github.com/wisdom-oss/service-consumers/types"
+)
+
+// CreateUsageRecords inserts the usage records contained in the request body
+// for the consumer.
+// The request body may either contain a single usage record or an array of
+// usage records which allows importing multiple meter readings at once.
+// All records are inserted in a single transaction.
+func CreateUsageRecords(w http.ResponseWriter, r *http.Request) {
+	// get the error handler and the error handler status channel
+	errorHandler := r.Context().Value(wisdomMiddleware.ERROR_CHANNEL_NAME).(chan<- interface{})
+	statusChannel := r.Context().Value(wisdomMiddleware.STATUS_CHANNEL_NAME).(<-chan bool)
+
+	// now get the consumer id from the url and validate it
+	consumerID, err := uuid.Parse(chi.URLParam(r, "consumer-id"))
+	if err != nil {
+		errorHandler <- "INVALID_CONSUMER_ID"
+		<-statusChannel
+		return
+	}
+
+	// now record the outcome of the import once the request has been handled
+	var records []types.UsageRecord
+	imported := false
+	defer func() {
+		metrics.CountUsageImport(len(records), imported)
+	}()
+
+	// now read the request body and check if it contains a single record or
+	// multiple records
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to read request body")
+		errorHandler <- requestBodyError(err, "unable to read request body")
+		<-statusChannel
+		return
+	}
+	if bytes.HasPrefix(bytes.TrimSpace(body), []byte("{")) {
+		var record types.UsageRecord
+		err = json.Unmarshal(body, &record)
+		records = append(records, record)
+	} else {
+		err = json.Unmarshal(body, &records)
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("unable to decode request body into usage records")
+		errorHandler <- requestBodyError(err, "unable to decode request body into usage records")
+		<-statusChannel
+		return
+	}
+
+	if len(records) == 0 {
+		errorHandler <- "NO_USAGE_RECORDS"
+		<-statusChannel
+		return
+	}
+	for _, record := range records {
+		if record.Date.IsZero() {
+			errorHandler <- "MISSING_USAGE_DATE"
+			<-statusChannel
+			return
+		}
+	}
+
+	exists, err := consumerExists(requestDB(r), r, consumerID)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to check if consumer exists")
+		errorHandler <- fmt.Errorf("unable to check if consumer exists: %w", err)
+		<-statusChannel
+		return
+	}
+	if !exists {
+		errorHandler <- "UNKNOWN_CONSUMER"
+		<-statusChannel
+		return
+	}
+
+	// now write the records into the database
+	tx, err := requestDB(r).BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to start database transaction")
+		errorHandler <- fmt.Errorf("unable to start database transaction: %w", err)
+		<-statusChannel
+		return
+	}
+
+	// now check that all usage types referenced by the records exist
+	var usageTypeIDs []*uuid.UUID
+	for _, record := range records {
+		usageTypeIDs = append(usageTypeIDs, record.UsageType)
+	}
+	usageTypesValid, err := usageTypesExist(tx, usageTypeIDs...)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to check if the usage types exist")
+		errorHandler <- fmt.Errorf("unable to check if the usage types exist: %w", err)
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+	if !usageTypesValid {
+		errorHandler <- "UNKNOWN_USAGE_TYPE"
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+
+	// now insert all records with a single statement. the identifiers are
+	// generated beforehand to return them in the order of the request body
+	var (
+		ids            = make([]uuid.UUID, len(records))
+		municipalities = make([]*string, len(records))
+		dates          = make([]time.Time, len(records))
+		usageTypes     = make([]*uuid.UUID, len(records))
+		amounts        = make([]float64, len(records))
+	)
+	for idx := range records {
+		records[idx].ID = uuid.New()
+		records[idx].Consumer = consumerID
+		ids[idx] = records[idx].ID
+		municipalities[idx] = records[idx].Municipality
+		dates[idx] = records[idx].Date
+		usageTypes[idx] = records[idx].UsageType
+		amounts[idx] = records[idx].Amount
+	}
+	_, err = globals.SqlQueries.Exec(tx, "insert-usage-records", consumerID,
+		pq.Array(ids), pq.Array(municipalities), pq.Array(dates), pq.Array(usageTypes), pq.Array(amounts))
+	if err != nil {
+		log.Error().Err(err).Msg("unable to insert the usage records into the database")
+		errorHandler <- databaseError(err, "unable to insert the usage records into the database")
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+
+	// the audit entry only describes the import since the records are
+	// stored in the usage records themselves
+	from := slices.MinFunc(dates, func(a, b time.Time) int { return a.Compare(b) })
+	until := slices.MaxFunc(dates, func(a, b time.Time) int { return a.Compare(b) })
+	err = writeAuditEntry(tx, r, "create-usage-records", &consumerID, nil, types.Map{"usageRecords": len(records), "from": from, "until": until})
+	if err != nil {
+		log.Error().Err(err).Msg("unable to write audit entry")
+		errorHandler <- fmt.Errorf("unable to write audit entry: %w", err)
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		log.Error().Err(err).Msg("unable to commit changes to the database")
+		errorHandler <- fmt.Errorf("unable to commit changes to the database: %w", err)
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+	imported = true
+
+	// now return the created records to allow the client to reference them
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	err = encodeResponse(w, r, records)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to encode usage records into json")
+	}
+}
@@ -0,0 +1,145 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/blockloop/scan/v2"
+	"github.com/google/uuid"
+	"github.com/qustavo/dotsql"
+	"github.com/rs/zerolog/log"
+	wisdomMiddleware "github.com/wisdom-oss/microservice-middlewares/v3"
+
+	"github.com/wisdom-oss/service-consumers/globals"
+	"github.com/wisdom-oss/service-consumers/types"
+)
+
+// CreateUsageType creates a new usage type
+func CreateUsageType(w http.ResponseWriter, r *http.Request) {
+	// get the error handler and the error handler status channel
+	errorHandler := r.Context().Value(wisdomMiddleware.ERROR_CHANNEL_NAME).(chan<- interface{})
+	statusChannel := r.Context().Value(wisdomMiddleware.STATUS_CHANNEL_NAME).(<-chan bool)
+
+	var usageType types.UsageType
+	err := json.NewDecoder(r.Body).Decode(&usageType)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to decode request body into usage type")
+		errorHandler <- requestBodyError(err, "unable to decode request body into usage type")
+		<-statusChannel
+		return
+	}
+
+	tx, err := requestDB(r).BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to start database transaction")
+		errorHandler <- fmt.Errorf("unable to start database transaction: %w", err)
+		<-statusChannel
+		return
+	}
+
+	errorCode, err := validateUsageType(tx, usageType, nil)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to validate usage type")
+		errorHandler <- fmt.Errorf("unable to validate usage type: %w", err)
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+	if errorCode != "" {
+		errorHandler <- errorCode
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+
+	rows, err := globals.SqlQueries.Query(tx, "insert-usage-type",
+		usageType.Name,
+		usageType.Description,
+		usageType.ExternalCode,
+		usageType.Parent,
+	)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to insert the usage type into the database")
+		errorHandler <- databaseError(err, "unable to insert the usage type into the database")
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+
+	err = scan.Row(&usageType.ID, rows)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to get the inserted usage type id")
+		errorHandler <- databaseError(err, "unable to get the inserted usage type id")
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+
+	err = writeAuditEntry(tx, r, "create-usage-type", nil, nil, usageType)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to write audit entry")
+		errorHandler <- fmt.Errorf("unable to write audit entry: %w", err)
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		log.Error().Err(err).Msg("unable to commit changes to the database")
+		errorHandler <- fmt.Errorf("unable to commit changes to the database: %w", err)
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("./%s", usageType.ID.String()))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	err = encodeResponse(w, r, usageType)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to encode usage type into json")
+	}
+}
+
+// validateUsageType checks that the usage type has a name and that the parent
+// exists. If the id of an existing usage type is supplied, the function also
+// checks that the parent does not create a cycle in the hierarchy.
+// If the usage type is invalid, the error code describing the issue is
+// returned
+func validateUsageType(db dotsql.Queryer, usageType types.UsageType, usageTypeID *uuid.UUID) (string, error) {
+	if strings.TrimSpace(usageType.Name) == "" {
+		return "MISSING_USAGE_TYPE_NAME", nil
+	}
+	if usageType.Parent == nil {
+		return "", nil
+	}
+
+	exists, err := usageTypeExists(db, *usageType.Parent)
+	if err != nil {
+		return "", err
+	}
+	if !exists {
+		return "UNKNOWN_PARENT_USAGE_TYPE", nil
+	}
+
+	if usageTypeID == nil {
+		return "", nil
+	}
+	rows, err := globals.SqlQueries.Query(db, "usage-type-ancestors", *usageType.Parent)
+	if err != nil {
+		return "", err
+	}
+	var ancestors []uuid.UUID
+	err = scan.Rows(&ancestors, rows)
+	if err != nil {
+		return "", err
+	}
+	if slices.Contains(ancestors, *usageTypeID) {
+		return "CYCLIC_USAGE_TYPE_HIERARCHY", nil
+	}
+	return "", nil
+}
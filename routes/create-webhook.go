@@ -0,0 +1,148 @@
+package routes
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/blockloop/scan/v2"
+	"github.com/rs/zerolog/log"
+	wisdomMiddleware "github.com/wisdom-oss/microservice-middlewares/v3"
+
+	"github.com/wisdom-oss/service-consumers/globals"
+	"github.com/wisdom-oss/service-consumers/types"
+)
+
+// CreateWebhook creates a new webhook. If no secret is supplied, a random
+// secret is generated. The secret is only returned in the response of this
+// request
+func CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	// get the error handler and the error handler status channel
+	errorHandler := r.Context().Value(wisdomMiddleware.ERROR_CHANNEL_NAME).(chan<- interface{})
+	statusChannel := r.Context().Value(wisdomMiddleware.STATUS_CHANNEL_NAME).(<-chan bool)
+
+	// new webhooks are active unless stated otherwise
+	webhook := types.Webhook{Active: true}
+	err := json.NewDecoder(r.Body).Decode(&webhook)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to decode request body into webhook")
+		errorHandler <- requestBodyError(err, "unable to decode request body into webhook")
+		<-statusChannel
+		return
+	}
+
+	if errorCode := validateWebhook(webhook); errorCode != "" {
+		errorHandler <- errorCode
+		<-statusChannel
+		return
+	}
+
+	if webhook.Secret == nil {
+		secret, err := generateWebhookSecret()
+		if err != nil {
+			log.Error().Err(err).Msg("unable to generate webhook secret")
+			errorHandler <- fmt.Errorf("unable to generate webhook secret: %w", err)
+			<-statusChannel
+			return
+		}
+		webhook.Secret = &secret
+	}
+	if webhook.EventTypes == nil {
+		webhook.EventTypes = []string{}
+	}
+
+	tx, err := requestDB(r).BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to start database transaction")
+		errorHandler <- fmt.Errorf("unable to start database transaction: %w", err)
+		<-statusChannel
+		return
+	}
+
+	rows, err := globals.SqlQueries.Query(tx, "insert-webhook",
+		webhook.URL,
+		*webhook.Secret,
+		webhook.EventTypes,
+		webhook.Active,
+	)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to insert the webhook into the database")
+		errorHandler <- databaseError(err, "unable to insert the webhook into the database")
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+
+	err = scan.Row(&webhook, rows)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to get the inserted webhook")
+		errorHandler <- databaseError(err, "unable to get the inserted webhook")
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+
+	err = writeAuditEntry(tx, r, "create-webhook", nil, nil, webhook)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to write audit entry")
+		errorHandler <- fmt.Errorf("unable to write audit entry: %w", err)
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		log.Error().Err(err).Msg("unable to commit changes to the database")
+		errorHandler <- fmt.Errorf("unable to commit changes to the database: %w", err)
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("./%s", webhook.ID.String()))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	err = encodeResponse(w, r, webhook)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to encode webhook into json")
+	}
+}
+
+// validateWebhook checks that the webhook points to an absolute http(s) url
+// and is only subscribed to known event types. If the webhook is invalid, the
+// error code describing the issue is returned
+func validateWebhook(webhook types.Webhook) string {
+	webhookURL, err := url.Parse(strings.TrimSpace(webhook.URL))
+	if err != nil || !webhookURL.IsAbs() || webhookURL.Host == "" {
+		return "INVALID_WEBHOOK_URL"
+	}
+	if webhookURL.Scheme != "http" && webhookURL.Scheme != "https" {
+		return "INVALID_WEBHOOK_URL"
+	}
+	for _, eventType := range webhook.EventTypes {
+		if !slices.Contains(eventTypes, eventType) {
+			return "UNKNOWN_EVENT_TYPE"
+		}
+	}
+	if webhook.Secret != nil && strings.TrimSpace(*webhook.Secret) == "" {
+		return "INVALID_WEBHOOK_SECRET"
+	}
+	return ""
+}
+
+// generateWebhookSecret generates a random secret used for signing the
+// deliveries of a webhook
+func generateWebhookSecret() (string, error) {
+	secret := make([]byte, 32)
+	_, err := rand.Read(secret)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(secret), nil
+}
@@ -0,0 +1,52 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// constraintErrorCodes maps the names of the PostgreSQL error codes that are
+// caused by the data sent by the client to the error codes that are returned
+// to the client
+var constraintErrorCodes = map[string]string{
+	"unique_violation":      "DUPLICATE_RESOURCE",
+	"foreign_key_violation": "UNKNOWN_REFERENCED_RESOURCE",
+	"check_violation":       "CONSTRAINT_VIOLATION",
+	"not_null_violation":    "CONSTRAINT_VIOLATION",
+}
+
+// geometryErrorIndicators contains parts of the messages that are used by
+// PostGIS when rejecting a geometry. Since PostGIS does not use a dedicated
+// error code for these errors, the message is used to detect them
+var geometryErrorIndicators = []string{"geometry", "geojson", "coordinates"}
+
+// databaseError translates errors returned by the database that have been
+// caused by the data supplied by the client into the matching error code
+// from the errors.json file.
+// Errors that cannot be translated are wrapped using the message and are
+// therefore handled as internal errors
+func databaseError(err error, message string) interface{} {
+	var pqError *pq.Error
+	if !errors.As(err, &pqError) {
+		return fmt.Errorf("%s: %w", message, err)
+	}
+
+	if errorCode, isConstraintError := constraintErrorCodes[pqError.Code.Name()]; isConstraintError {
+		return errorCode
+	}
+
+	// now check if the error has been raised by PostGIS while handling a
+	// geometry which is either reported as internal error or as data exception
+	if pqError.Code == "XX000" || pqError.Code.Class() == "22" {
+		errorMessage := strings.ToLower(pqError.Message)
+		for _, indicator := range geometryErrorIndicators {
+			if strings.Contains(errorMessage, indicator) {
+				return "INVALID_GEOMETRY"
+			}
+		}
+	}
+	return fmt.Errorf("%s: %w", message, err)
+}
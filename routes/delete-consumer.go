@@ -1 +1,105 @@
 package routes
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/rs/zerolog/log"
+	wisdomMiddleware "github.com/wisdom-oss/microservice-middlewares/v3"
+
+	"github.com/wisdom-oss/service-consumers/auth"
+	"github.com/wisdom-oss/service-consumers/globals"
+	"github.com/wisdom-oss/service-consumers/types"
+)
+
+// DeleteConsumer deletes a consumer softly. The consumer and its usage
+// records are kept in the database which allows restoring the consumer by
+// reverting it to a former version
+func DeleteConsumer(w http.ResponseWriter, r *http.Request) {
+	// get the error handler and the error handler status channel
+	errorHandler := r.Context().Value(wisdomMiddleware.ERROR_CHANNEL_NAME).(chan<- interface{})
+	statusChannel := r.Context().Value(wisdomMiddleware.STATUS_CHANNEL_NAME).(<-chan bool)
+
+	// now get the consumer id from the url and validate it
+	consumerID, err := uuid.Parse(chi.URLParam(r, "consumer-id"))
+	if err != nil {
+		errorHandler <- "INVALID_CONSUMER_ID"
+		<-statusChannel
+		return
+	}
+
+	tx, err := requestDB(r).BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to start database transaction")
+		errorHandler <- fmt.Errorf("unable to start database transaction: %w", err)
+		<-statusChannel
+		return
+	}
+
+	err = setChangingUser(tx, r)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to set the changing user")
+		errorHandler <- fmt.Errorf("unable to set the changing user: %w", err)
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+
+	res, err := globals.SqlQueries.Exec(tx, "soft-delete-consumer", consumerID, pq.Array(auth.PrincipalFromContext(r.Context()).Areas))
+	if err != nil {
+		log.Error().Err(err).Msg("unable to delete the consumer")
+		errorHandler <- databaseError(err, "unable to delete the consumer")
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+
+	// since already deleted consumers and consumers outside the areas of a
+	// restricted user are not deleted, check that a consumer has been deleted
+	affectedRows, err := res.RowsAffected()
+	if err != nil {
+		log.Error().Err(err).Msg("unable to get the number of deleted consumers")
+		errorHandler <- fmt.Errorf("unable to get the number of deleted consumers: %w", err)
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+	if affectedRows == 0 {
+		errorHandler <- "UNKNOWN_CONSUMER"
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+
+	err = writeAuditEntry(tx, r, "delete-consumer", &consumerID, types.Map{"deleted": false}, types.Map{"deleted": true})
+	if err != nil {
+		log.Error().Err(err).Msg("unable to write audit entry")
+		errorHandler <- fmt.Errorf("unable to write audit entry: %w", err)
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+
+	err = writeOutboxEvent(tx, "consumer.deleted", &consumerID, types.Map{"id": consumerID})
+	if err != nil {
+		log.Error().Err(err).Msg("unable to write outbox event")
+		errorHandler <- fmt.Errorf("unable to write outbox event: %w", err)
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		log.Error().Err(err).Msg("unable to commit changes to the database")
+		errorHandler <- fmt.Errorf("unable to commit changes to the database: %w", err)
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,106 @@
+package routes
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	wisdomMiddleware "github.com/wisdom-oss/microservice-middlewares/v3"
+
+	"github.com/wisdom-oss/service-consumers/globals"
+	"github.com/wisdom-oss/service-consumers/types"
+)
+
+// DeleteUsageRecord removes a single usage record of a consumer. This allows
+// the removal of erroneous meter readings
+func DeleteUsageRecord(w http.ResponseWriter, r *http.Request) {
+	// get the error handler and the error handler status channel
+	errorHandler := r.Context().Value(wisdomMiddleware.ERROR_CHANNEL_NAME).(chan<- interface{})
+	statusChannel := r.Context().Value(wisdomMiddleware.STATUS_CHANNEL_NAME).(<-chan bool)
+
+	// now get the consumer and usage record id from the url and validate them
+	consumerID, err := uuid.Parse(chi.URLParam(r, "consumer-id"))
+	if err != nil {
+		errorHandler <- "INVALID_CONSUMER_ID"
+		<-statusChannel
+		return
+	}
+	usageRecordID, err := uuid.Parse(chi.URLParam(r, "usage-id"))
+	if err != nil {
+		errorHandler <- "INVALID_USAGE_RECORD_ID"
+		<-statusChannel
+		return
+	}
+
+	tx, err := requestDB(r).BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to start database transaction")
+		errorHandler <- fmt.Errorf("unable to start database transaction: %w", err)
+		<-statusChannel
+		return
+	}
+
+	// consumers outside the areas of a restricted user are treated as unknown
+	exists, err := consumerExists(tx, r, consumerID)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to check if the consumer exists")
+		errorHandler <- fmt.Errorf("unable to check if the consumer exists: %w", err)
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+	if !exists {
+		errorHandler <- "UNKNOWN_CONSUMER"
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+
+	res, err := globals.SqlQueries.Exec(tx, "delete-usage-record", usageRecordID, consumerID)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to delete the usage record")
+		errorHandler <- databaseError(err, "unable to delete the usage record")
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+
+	// since the usage record is only deleted if it belongs to the consumer,
+	// check that a record has been deleted
+	affectedRows, err := res.RowsAffected()
+	if err != nil {
+		log.Error().Err(err).Msg("unable to get the number of deleted usage records")
+		errorHandler <- fmt.Errorf("unable to get the number of deleted usage records: %w", err)
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+	if affectedRows == 0 {
+		errorHandler <- "UNKNOWN_USAGE_RECORD"
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+
+	err = writeAuditEntry(tx, r, "delete-usage-record", &consumerID, types.Map{"usageRecord": usageRecordID}, nil)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to write audit entry")
+		errorHandler <- fmt.Errorf("unable to write audit entry: %w", err)
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		log.Error().Err(err).Msg("unable to commit changes to the database")
+		errorHandler <- fmt.Errorf("unable to commit changes to the database: %w", err)
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,137 @@
+package routes
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/blockloop/scan/v2"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	wisdomMiddleware "github.com/wisdom-oss/microservice-middlewares/v3"
+
+	"github.com/wisdom-oss/service-consumers/auth"
+	"github.com/wisdom-oss/service-consumers/globals"
+	"github.com/wisdom-oss/service-consumers/tenancy"
+)
+
+// DeleteUsageType removes a usage type. Usage types which are still assigned
+// to consumers, usage records or other usage types cannot be deleted
+func DeleteUsageType(w http.ResponseWriter, r *http.Request) {
+	// get the error handler and the error handler status channel
+	errorHandler := r.Context().Value(wisdomMiddleware.ERROR_CHANNEL_NAME).(chan<- interface{})
+	statusChannel := r.Context().Value(wisdomMiddleware.STATUS_CHANNEL_NAME).(<-chan bool)
+
+	usageTypeID, err := uuid.Parse(chi.URLParam(r, "usage-type-id"))
+	if err != nil {
+		errorHandler <- "INVALID_USAGE_TYPE_ID"
+		<-statusChannel
+		return
+	}
+
+	tx, err := requestDB(r).BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to start database transaction")
+		errorHandler <- fmt.Errorf("unable to start database transaction: %w", err)
+		<-statusChannel
+		return
+	}
+
+	// now get the current representation of the usage type to record the
+	// changes
+	formerUsageType, err := getUsageType(tx, usageTypeID)
+	if errors.Is(err, sql.ErrNoRows) {
+		errorHandler <- "UNKNOWN_USAGE_TYPE"
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("unable to get the usage type")
+		errorHandler <- fmt.Errorf("unable to get the usage type: %w", err)
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+
+	inUse, err := usageTypeInUse(r, tx, usageTypeID)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to check if the usage type is in use")
+		errorHandler <- fmt.Errorf("unable to check if the usage type is in use: %w", err)
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+	if inUse {
+		errorHandler <- "USAGE_TYPE_IN_USE"
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+
+	res, err := globals.SqlQueries.Exec(tx, "delete-usage-type", usageTypeID)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to delete the usage type")
+		errorHandler <- databaseError(err, "unable to delete the usage type")
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+	affectedRows, err := res.RowsAffected()
+	if err != nil {
+		log.Error().Err(err).Msg("unable to get the number of deleted usage types")
+		errorHandler <- fmt.Errorf("unable to get the number of deleted usage types: %w", err)
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+	if affectedRows == 0 {
+		errorHandler <- "UNKNOWN_USAGE_TYPE"
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+
+	err = writeAuditEntry(tx, r, "delete-usage-type", nil, formerUsageType, nil)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to write audit entry")
+		errorHandler <- fmt.Errorf("unable to write audit entry: %w", err)
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		log.Error().Err(err).Msg("unable to commit changes to the database")
+		errorHandler <- fmt.Errorf("unable to commit changes to the database: %w", err)
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// usageTypeInUse checks if the usage type is assigned to the consumers, usage
+// records or usage types of any tenant. the usage types are shared by all
+// tenants, so the transaction is bound to the system tenant during the check
+// and bound to the tenant of the caller again afterwards
+func usageTypeInUse(r *http.Request, tx *sql.Tx, usageTypeID uuid.UUID) (bool, error) {
+	err := tenancy.BindTx(r.Context(), tx, tenancy.System)
+	if err != nil {
+		return false, err
+	}
+	rows, err := globals.SqlQueries.Query(tx, "usage-type-in-use", usageTypeID)
+	if err != nil {
+		return false, err
+	}
+	var inUse bool
+	err = scan.Row(&inUse, rows)
+	if err != nil {
+		return false, err
+	}
+	return inUse, tenancy.BindTx(r.Context(), tx, auth.PrincipalFromContext(r.Context()).Tenant)
+}
@@ -0,0 +1,81 @@
+package routes
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	wisdomMiddleware "github.com/wisdom-oss/microservice-middlewares/v3"
+
+	"github.com/wisdom-oss/service-consumers/globals"
+)
+
+// DeleteWebhook deletes a webhook together with its deliveries
+func DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	// get the error handler and the error handler status channel
+	errorHandler := r.Context().Value(wisdomMiddleware.ERROR_CHANNEL_NAME).(chan<- interface{})
+	statusChannel := r.Context().Value(wisdomMiddleware.STATUS_CHANNEL_NAME).(<-chan bool)
+
+	webhookID, err := uuid.Parse(chi.URLParam(r, "webhook-id"))
+	if err != nil {
+		errorHandler <- "INVALID_WEBHOOK_ID"
+		<-statusChannel
+		return
+	}
+
+	tx, err := requestDB(r).BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to start database transaction")
+		errorHandler <- fmt.Errorf("unable to start database transaction: %w", err)
+		<-statusChannel
+		return
+	}
+
+	formerWebhook, err := getWebhook(tx, webhookID)
+	if errors.Is(err, sql.ErrNoRows) {
+		errorHandler <- "UNKNOWN_WEBHOOK"
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("unable to get webhook")
+		errorHandler <- fmt.Errorf("unable to get webhook: %w", err)
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+
+	_, err = globals.SqlQueries.Exec(tx, "delete-webhook", webhookID)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to delete the webhook")
+		errorHandler <- databaseError(err, "unable to delete the webhook")
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+
+	err = writeAuditEntry(tx, r, "delete-webhook", nil, formerWebhook, nil)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to write audit entry")
+		errorHandler <- fmt.Errorf("unable to write audit entry: %w", err)
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		log.Error().Err(err).Msg("unable to commit changes to the database")
+		errorHandler <- fmt.Errorf("unable to commit changes to the database: %w", err)
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,115 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/blockloop/scan/v2"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/qustavo/dotsql"
+	wisdomType "github.com/wisdom-oss/commonTypes"
+
+	"github.com/wisdom-oss/service-consumers/auth"
+	"github.com/wisdom-oss/service-consumers/globals"
+	"github.com/wisdom-oss/service-consumers/types"
+)
+
+// DuplicateDetection contains the configuration of the duplicate detection
+// which is executed before a new consumer is created
+type DuplicateDetection struct {
+	// Enabled indicates if new consumers are checked for duplicates
+	Enabled bool
+
+	// Radius contains the distance in meters in which a consumer with the same
+	// usage type is considered a duplicate. If the radius is zero, only the
+	// normalized name and address are compared
+	Radius float64
+}
+
+// duplicateDetection contains the duplicate detection configuration used by
+// the handlers
+var duplicateDetection = DuplicateDetection{Enabled: true, Radius: 25}
+
+// ConfigureDuplicateDetection reads the configuration of the duplicate
+// detection from the supplied environment
+func ConfigureDuplicateDetection(environment map[string]string) error {
+	var d DuplicateDetection
+	var err error
+
+	d.Enabled, err = strconv.ParseBool(environment["DUPLICATE_DETECTION_ENABLED"])
+	if err != nil {
+		return fmt.Errorf("unable to parse duplicate detection toggle: %w", err)
+	}
+
+	d.Radius, err = strconv.ParseFloat(environment["DUPLICATE_DETECTION_RADIUS"], 64)
+	if err != nil {
+		return fmt.Errorf("unable to parse duplicate detection radius: %w", err)
+	}
+	if d.Radius < 0 {
+		return fmt.Errorf("negative duplicate detection radius: %f", d.Radius)
+	}
+
+	duplicateDetection = d
+	return nil
+}
+
+// duplicateConsumerError extends the predefined error with the ids of the
+// consumers that conflict with the consumer sent in the request
+type duplicateConsumerError struct {
+	wisdomType.WISdoMError
+	Duplicates []uuid.UUID `json:"duplicates"`
+}
+
+// findDuplicateConsumers returns the ids of the consumers that are considered
+// duplicates of the supplied consumer. Consumers outside the areas of the user
+// sending the request are not considered
+func findDuplicateConsumers(db dotsql.Queryer, r *http.Request, consumer types.Consumer) ([]uuid.UUID, error) {
+	rows, err := globals.SqlQueries.Query(db, "find-duplicate-consumers",
+		consumer.Name,
+		consumer.Address,
+		consumer.UsageType,
+		consumer.Location,
+		duplicateDetection.Radius,
+		pq.Array(auth.PrincipalFromContext(r.Context()).Areas),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var duplicates []uuid.UUID
+	err = scan.Rows(&duplicates, rows)
+	return duplicates, err
+}
+
+// parseForceOverride reads the `force` query parameter which allows users
+// with the admin scope to skip the duplicate detection
+func parseForceOverride(r *http.Request) (force bool, errorCode string) {
+	rawForce := strings.TrimSpace(r.URL.Query().Get("force"))
+	if rawForce == "" {
+		return false, ""
+	}
+	force, err := strconv.ParseBool(rawForce)
+	if err != nil {
+		return false, "INVALID_FORCE_OVERRIDE"
+	}
+	if force && !auth.PrincipalFromContext(r.Context()).HasScope(auth.ScopeAdmin) {
+		return false, "FORCE_OVERRIDE_FORBIDDEN"
+	}
+	return force, ""
+}
+
+// sendDuplicateConsumerError writes the predefined error for duplicate
+// consumers together with the ids of the duplicates to the response
+func sendDuplicateConsumerError(w http.ResponseWriter, duplicates []uuid.UUID) error {
+	e := duplicateConsumerError{
+		WISdoMError: globals.Errors["DUPLICATE_CONSUMER"],
+		Duplicates:  duplicates,
+	}
+	w.Header().Set("Content-Type", "text/json; charset=utf-8")
+	w.WriteHeader(e.HttpStatusCode)
+	return json.NewEncoder(w).Encode(e)
+}
@@ -0,0 +1,137 @@
+package routes
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/rs/zerolog/log"
+	wisdomMiddleware "github.com/wisdom-oss/microservice-middlewares/v3"
+
+	"github.com/wisdom-oss/service-consumers/globals"
+	"github.com/wisdom-oss/service-consumers/types"
+)
+
+// erasedName replaces the name of erased consumers
+const erasedName = "[erased]"
+
+// minimalErasureGridSize contains the smallest grid the locations of erased
+// consumers are snapped to. It applies even if the redaction policy does not
+// coarsen the locations, since the exact location would identify the
+// consumer after the erasure
+const minimalErasureGridSize = 0.01
+
+// EraseConsumer anonymizes the personal data of a consumer irreversibly. The
+// name, description and address are removed from the consumer and every
+// recorded version of it, the location is snapped to the grid of the
+// redaction policy (at least minimalErasureGridSize) and the sensitive
+// properties are removed. The personal
+// data is removed from the audit log and the outbox as well.
+// The usage records and the usage type of the consumer are kept, which keeps
+// the aggregated usage statistics intact
+func EraseConsumer(w http.ResponseWriter, r *http.Request) {
+	// get the error handler and the error handler status channel
+	errorHandler := r.Context().Value(wisdomMiddleware.ERROR_CHANNEL_NAME).(chan<- interface{})
+	statusChannel := r.Context().Value(wisdomMiddleware.STATUS_CHANNEL_NAME).(<-chan bool)
+
+	consumerID, err := uuid.Parse(chi.URLParam(r, "consumer-id"))
+	if err != nil {
+		errorHandler <- "INVALID_CONSUMER_ID"
+		<-statusChannel
+		return
+	}
+
+	tx, err := requestDB(r).BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to start database transaction")
+		errorHandler <- fmt.Errorf("unable to start database transaction: %w", err)
+		<-statusChannel
+		return
+	}
+
+	err = setChangingUser(tx, r)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to set the changing user")
+		errorHandler <- fmt.Errorf("unable to set the changing user: %w", err)
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+
+	// an empty array is used if no properties are sensitive, since removing
+	// null from the properties would remove all of them
+	sensitiveProperties := pq.Array(append([]string{}, redactionPolicy.SensitiveProperties...))
+	gridSize := max(redactionPolicy.GridSize, minimalErasureGridSize)
+
+	res, err := globals.SqlQueries.Exec(tx, "erase-consumer", consumerID, erasedName, gridSize, sensitiveProperties)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to erase the consumer")
+		errorHandler <- databaseError(err, "unable to erase the consumer")
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+	affectedRows, err := res.RowsAffected()
+	if err != nil {
+		log.Error().Err(err).Msg("unable to get the number of erased consumers")
+		errorHandler <- fmt.Errorf("unable to get the number of erased consumers: %w", err)
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+	if affectedRows == 0 {
+		errorHandler <- "UNKNOWN_CONSUMER"
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+
+	// now remove the personal data from the places it has been copied to.
+	// the history is erased after the consumer to include the version
+	// recorded for the erasure itself
+	_, err = globals.SqlQueries.Exec(tx, "erase-consumer-history", consumerID, erasedName, gridSize, sensitiveProperties)
+	if err == nil {
+		_, err = globals.SqlQueries.Exec(tx, "erase-consumer-audit-entries", consumerID)
+	}
+	if err == nil {
+		_, err = globals.SqlQueries.Exec(tx, "erase-consumer-outbox-events", consumerID)
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("unable to erase the copies of the consumer")
+		errorHandler <- databaseError(err, "unable to erase the copies of the consumer")
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+
+	err = writeAuditEntry(tx, r, "erase-consumer", &consumerID, types.Map{"erased": false}, types.Map{"erased": true})
+	if err != nil {
+		log.Error().Err(err).Msg("unable to write audit entry")
+		errorHandler <- fmt.Errorf("unable to write audit entry: %w", err)
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+
+	err = writeOutboxEvent(tx, "consumer.erased", &consumerID, types.Map{"id": consumerID})
+	if err != nil {
+		log.Error().Err(err).Msg("unable to write outbox event")
+		errorHandler <- fmt.Errorf("unable to write outbox event: %w", err)
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		log.Error().Err(err).Msg("unable to commit changes to the database")
+		errorHandler <- fmt.Errorf("unable to commit changes to the database: %w", err)
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,175 @@
+package routes
+
+import (
+	"archive/zip"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/blockloop/scan/v2"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/rs/zerolog/log"
+	wisdomMiddleware "github.com/wisdom-oss/microservice-middlewares/v3"
+
+	"github.com/wisdom-oss/service-consumers/globals"
+	"github.com/wisdom-oss/service-consumers/tenancy"
+	"github.com/wisdom-oss/service-consumers/types"
+)
+
+// ExportConsumer returns everything that is stored about a consumer. This
+// includes the consumer itself, its history, its usage records and the audit
+// entries of the changes made to it. Deleted consumers are exported as well.
+// The export is returned as a single json document unless the `format` query
+// parameter is set to `zip`. In this case, every part of the export is
+// written into its own file in a zip archive
+func ExportConsumer(w http.ResponseWriter, r *http.Request) {
+	// get the error handler and the error handler status channel
+	errorHandler := r.Context().Value(wisdomMiddleware.ERROR_CHANNEL_NAME).(chan<- interface{})
+	statusChannel := r.Context().Value(wisdomMiddleware.STATUS_CHANNEL_NAME).(<-chan bool)
+
+	consumerID, err := uuid.Parse(chi.URLParam(r, "consumer-id"))
+	if err != nil {
+		errorHandler <- "INVALID_CONSUMER_ID"
+		<-statusChannel
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && format != "zip" {
+		errorHandler <- "INVALID_EXPORT_FORMAT"
+		<-statusChannel
+		return
+	}
+
+	export, err := collectConsumerExport(r.Context(), requestDB(r), consumerID)
+	if errors.Is(err, sql.ErrNoRows) {
+		errorHandler <- "UNKNOWN_CONSUMER"
+		<-statusChannel
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("unable to collect the consumer export")
+		errorHandler <- fmt.Errorf("unable to collect the consumer export: %w", err)
+		<-statusChannel
+		return
+	}
+
+	fileName := fmt.Sprintf("consumer-%s.%s", consumerID, format)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, fileName))
+
+	if format == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		err = encodeResponse(w, r, export)
+		if err != nil {
+			log.Error().Err(err).Msg("unable to encode consumer export into json")
+			errorHandler <- fmt.Errorf("unable to encode consumer export into json: %w", err)
+			<-statusChannel
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	archive := zip.NewWriter(w)
+	files := []struct {
+		name    string
+		content interface{}
+	}{
+		{"consumer.json", export.Consumer},
+		{"history.json", export.History},
+		{"usage-records.json", export.UsageRecords},
+		{"audit-entries.json", export.AuditEntries},
+	}
+	for _, file := range files {
+		fileWriter, err := archive.CreateHeader(&zip.FileHeader{
+			Name:     file.name,
+			Method:   zip.Deflate,
+			Modified: export.ExportedAt,
+		})
+		if err != nil {
+			log.Error().Err(err).Msg("unable to create file in export archive")
+			return
+		}
+		err = encodeResponse(fileWriter, r, file.content)
+		if err != nil {
+			log.Error().Err(err).Msg("unable to write file in export archive")
+			return
+		}
+	}
+	err = archive.Close()
+	if err != nil {
+		log.Error().Err(err).Msg("unable to close export archive")
+	}
+}
+
+// collectConsumerExport reads everything stored about the consumer. The data
+// is read in a single read-only transaction to get a consistent export. If
+// the consumer does not exist, sql.ErrNoRows is returned
+func collectConsumerExport(ctx context.Context, db *tenancy.Conn, consumerID uuid.UUID) (*types.ConsumerExport, error) {
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	export := types.ConsumerExport{ExportedAt: time.Now()}
+
+	rows, err := globals.SqlQueries.Query(tx, "get-consumer-record", consumerID)
+	if err != nil {
+		return nil, err
+	}
+	err = scan.Row(&export.Consumer, rows)
+	if err != nil {
+		return nil, err
+	}
+
+	// the export is only available to administrators which are not
+	// restricted to any areas
+	rows, err = globals.SqlQueries.Query(tx, "get-consumer-history", consumerID, nil)
+	if err != nil {
+		return nil, err
+	}
+	err = scan.Rows(&export.History, rows)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err = globals.SqlQueries.Query(tx, "get-usage-records", consumerID)
+	if err != nil {
+		return nil, err
+	}
+	err = scan.Rows(&export.UsageRecords, rows)
+	if err != nil {
+		return nil, err
+	}
+
+	query, err := newQueryBuilder("get-audit-entries")
+	if err != nil {
+		return nil, err
+	}
+	err = query.addFilter("filter-audit-consumers", pq.Array([]string{consumerID.String()}))
+	if err != nil {
+		return nil, err
+	}
+	rawQuery, err := query.build("order-audit-entries")
+	if err != nil {
+		return nil, err
+	}
+	rows, err = tx.Query(rawQuery, query.arguments...)
+	if err != nil {
+		return nil, err
+	}
+	err = scan.Rows(&export.AuditEntries, rows)
+	if err != nil {
+		return nil, err
+	}
+
+	return &export, nil
+}
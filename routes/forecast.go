@@ -0,0 +1,122 @@
+package routes
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/wisdom-oss/service-consumers/forecast"
+	"github.com/wisdom-oss/service-consumers/types"
+)
+
+// monthsPerYear contains the season length used for forecasting monthly
+// usages
+const monthsPerYear = 12
+
+// defaultForecastHorizon contains the number of months that are forecasted
+// if no other horizon has been requested
+const defaultForecastHorizon = 12
+
+// maximalForecastHorizon contains the maximal number of months that may be
+// forecasted
+const maximalForecastHorizon = 60
+
+// defaultForecastLevel contains the confidence level used for the
+// prediction intervals if no other level has been requested
+const defaultForecastLevel = 0.95
+
+// forecastModels maps the forecasting methods that may be requested using
+// the "method" query parameter to the models implementing them
+var forecastModels = map[string]func(seasonLength int) forecast.Model{
+	"holt-winters":   forecast.HoltWinters,
+	"seasonal-naive": forecast.SeasonalNaive,
+}
+
+// forecastParameters contains the parameters used for forecasting usages
+type forecastParameters struct {
+	horizon int
+	level   float64
+	method  string
+}
+
+// parseForecastParameters reads the parameters used for forecasting usages
+// from the query parameters of the request.
+// If a parameter is invalid, the error code describing the issue is returned
+func parseForecastParameters(r *http.Request) (parameters forecastParameters, errorCode string) {
+	parameters.horizon = defaultForecastHorizon
+	if rawHorizon := r.URL.Query().Get("horizon"); rawHorizon != "" {
+		horizon, err := strconv.Atoi(rawHorizon)
+		if err != nil || horizon < 1 || horizon > maximalForecastHorizon {
+			return parameters, "INVALID_FORECAST_HORIZON"
+		}
+		parameters.horizon = horizon
+	}
+
+	parameters.level = defaultForecastLevel
+	if rawLevel := r.URL.Query().Get("level"); rawLevel != "" {
+		level, err := strconv.ParseFloat(rawLevel, 64)
+		if err != nil || level <= 0 || level >= 1 {
+			return parameters, "INVALID_FORECAST_LEVEL"
+		}
+		parameters.level = level
+	}
+
+	parameters.method = r.URL.Query().Get("method")
+	if parameters.method == "" {
+		parameters.method = "auto"
+	}
+	if _, methodSupported := forecastModels[parameters.method]; !methodSupported && parameters.method != "auto" {
+		return parameters, "INVALID_FORECAST_METHOD"
+	}
+	return parameters, ""
+}
+
+// forecastUsages forecasts the monthly usages following the supplied monthly
+// usage aggregates.
+// The history ends with the last month containing usage records since the
+// months following it have not been observed yet and would pull the forecast
+// towards zero.
+// If the method "auto" is requested, Holt-Winters is used for histories
+// containing at least two years and the seasonal naive method otherwise.
+// Since usages cannot be negative, the forecasts are limited to zero
+func forecastUsages(aggregates []types.UsageAggregate, parameters forecastParameters) (*types.UsageForecast, error) {
+	for len(aggregates) > 0 && aggregates[len(aggregates)-1].Records == 0 {
+		aggregates = aggregates[:len(aggregates)-1]
+	}
+	if len(aggregates) == 0 {
+		return nil, forecast.ErrInsufficientHistory
+	}
+
+	series := make([]float64, len(aggregates))
+	for idx, aggregate := range aggregates {
+		series[idx] = aggregate.Total
+	}
+
+	method := parameters.method
+	if method == "auto" {
+		method = "seasonal-naive"
+		if len(series) >= 2*monthsPerYear {
+			method = "holt-winters"
+		}
+	}
+
+	predictions, err := forecastModels[method](monthsPerYear).Forecast(series, parameters.horizon, parameters.level)
+	if err != nil {
+		return nil, err
+	}
+
+	lastPeriod := aggregates[len(aggregates)-1].Bucket
+	usageForecast := types.UsageForecast{
+		Method:      method,
+		Level:       parameters.level,
+		Predictions: make([]types.ForecastedUsage, len(predictions)),
+	}
+	for idx, prediction := range predictions {
+		usageForecast.Predictions[idx] = types.ForecastedUsage{
+			Period: lastPeriod.AddDate(0, idx+1, 0),
+			Value:  max(prediction.Value, 0),
+			Lower:  max(prediction.Lower, 0),
+			Upper:  max(prediction.Upper, 0),
+		}
+	}
+	return &usageForecast, nil
+}
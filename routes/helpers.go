@@ -0,0 +1,226 @@
+package routes
+
+import (
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/blockloop/scan/v2"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/qustavo/dotsql"
+
+	"github.com/wisdom-oss/service-consumers/auth"
+	"github.com/wisdom-oss/service-consumers/globals"
+	"github.com/wisdom-oss/service-consumers/tenancy"
+	"github.com/wisdom-oss/service-consumers/types"
+)
+
+// requestDB returns the database connection of the request. The connection
+// is bound to the tenant of the user and only exposes the consumers and
+// usages of this tenant
+func requestDB(r *http.Request) *tenancy.Conn {
+	return tenancy.FromContext(r.Context())
+}
+
+// timestampLayouts contains the layouts that are accepted for timestamps
+// supplied in query parameters and request bodies
+var timestampLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	time.DateOnly,
+}
+
+// parseTimestamp tries to parse the supplied string using the accepted
+// timestamp layouts and returns the first successful result
+func parseTimestamp(value string) (time.Time, error) {
+	var err error
+	for _, layout := range timestampLayouts {
+		var timestamp time.Time
+		timestamp, err = time.Parse(layout, value)
+		if err == nil {
+			return timestamp, nil
+		}
+	}
+	return time.Time{}, err
+}
+
+// consumerExists checks if a consumer with the supplied id is stored in the
+// database. Consumers outside the areas of the user sending the request are
+// treated as nonexistent
+func consumerExists(db dotsql.Queryer, r *http.Request, consumerID uuid.UUID) (bool, error) {
+	areas := auth.PrincipalFromContext(r.Context()).Areas
+	rows, err := globals.SqlQueries.Query(db, "consumer-exists", consumerID, pq.Array(areas))
+	if err != nil {
+		return false, err
+	}
+	var exists bool
+	err = scan.Row(&exists, rows)
+	return exists, err
+}
+
+// usageTypeExists checks if a usage type with the supplied id is stored in the
+// database
+func usageTypeExists(db dotsql.Queryer, usageTypeID uuid.UUID) (bool, error) {
+	rows, err := globals.SqlQueries.Query(db, "usage-type-exists", usageTypeID)
+	if err != nil {
+		return false, err
+	}
+	var exists bool
+	err = scan.Row(&exists, rows)
+	return exists, err
+}
+
+// usageTypesExist checks if all supplied usage types are stored in the
+// database. Usage types that are not set are ignored
+func usageTypesExist(db dotsql.Queryer, usageTypeIDs ...*uuid.UUID) (bool, error) {
+	var distinctUsageTypeIDs []string
+	for _, usageTypeID := range usageTypeIDs {
+		if usageTypeID != nil && !slices.Contains(distinctUsageTypeIDs, usageTypeID.String()) {
+			distinctUsageTypeIDs = append(distinctUsageTypeIDs, usageTypeID.String())
+		}
+	}
+	if len(distinctUsageTypeIDs) == 0 {
+		return true, nil
+	}
+
+	rows, err := globals.SqlQueries.Query(db, "count-usage-types", pq.Array(distinctUsageTypeIDs))
+	if err != nil {
+		return false, err
+	}
+	var existingUsageTypes int
+	err = scan.Row(&existingUsageTypes, rows)
+	return existingUsageTypes == len(distinctUsageTypeIDs), err
+}
+
+// expandUsageTypes resolves the usage types assigned to the consumers and
+// attaches them to the consumers
+func expandUsageTypes(db dotsql.Queryer, consumers []types.Consumer) error {
+	var usageTypeIDs []string
+	for _, consumer := range consumers {
+		if consumer.UsageType != nil && !slices.Contains(usageTypeIDs, consumer.UsageType.String()) {
+			usageTypeIDs = append(usageTypeIDs, consumer.UsageType.String())
+		}
+	}
+	if len(usageTypeIDs) == 0 {
+		return nil
+	}
+
+	query, err := newQueryBuilder("get-usage-types")
+	if err != nil {
+		return err
+	}
+	err = query.addFilter("filter-ids", pq.Array(usageTypeIDs))
+	if err != nil {
+		return err
+	}
+	sql, err := query.build()
+	if err != nil {
+		return err
+	}
+	rows, err := db.Query(sql, query.arguments...)
+	if err != nil {
+		return err
+	}
+	var usageTypes []types.UsageType
+	err = scan.Rows(&usageTypes, rows)
+	if err != nil {
+		return err
+	}
+
+	for idx := range consumers {
+		for usageTypeIdx := range usageTypes {
+			if consumers[idx].UsageType != nil && *consumers[idx].UsageType == usageTypes[usageTypeIdx].ID {
+				consumers[idx].UsageTypeDetails = &usageTypes[usageTypeIdx]
+			}
+		}
+	}
+	return nil
+}
+
+// parseExpansions reads the expansions requested using the "expand" query
+// parameter and checks that they are supported.
+// If an expansion is not supported, the error code describing the issue is
+// returned
+func parseExpansions(r *http.Request) (expandUsageType bool, errorCode string) {
+	for _, expansion := range r.URL.Query()["expand"] {
+		switch expansion {
+		case "usageType":
+			expandUsageType = true
+		default:
+			return false, "UNSUPPORTED_EXPANSION"
+		}
+	}
+	return expandUsageType, ""
+}
+
+// aggregationIntervals contains the intervals that are supported when
+// aggregating usages into time buckets
+var aggregationIntervals = []string{"day", "week", "month", "quarter", "year"}
+
+// parseAggregationParameters reads the interval and the time range used
+// for aggregating usages from the query parameters of the request.
+// If a parameter is invalid, the error code describing the issue is returned
+func parseAggregationParameters(r *http.Request) (interval string, from *time.Time, to *time.Time, errorCode string) {
+	interval = r.URL.Query().Get("interval")
+	if interval == "" {
+		interval = "month"
+	}
+	if !slices.Contains(aggregationIntervals, interval) {
+		return "", nil, nil, "INVALID_AGGREGATION_INTERVAL"
+	}
+
+	from, to, errorCode = parseTimeRange(r)
+	if errorCode != "" {
+		return "", nil, nil, errorCode
+	}
+	return interval, from, to, ""
+}
+
+// parseTimeRange reads the optional time range from the "from" and "to"
+// query parameters of the request.
+// If a parameter is invalid, the error code describing the issue is returned
+func parseTimeRange(r *http.Request) (from *time.Time, to *time.Time, errorCode string) {
+	if rawFrom := r.URL.Query().Get("from"); rawFrom != "" {
+		timestamp, err := parseTimestamp(rawFrom)
+		if err != nil {
+			return nil, nil, "INVALID_TIMESTAMP"
+		}
+		from = &timestamp
+	}
+
+	if rawTo := r.URL.Query().Get("to"); rawTo != "" {
+		timestamp, err := parseTimestamp(rawTo)
+		if err != nil {
+			return nil, nil, "INVALID_TIMESTAMP"
+		}
+		to = &timestamp
+	}
+
+	if from != nil && to != nil && from.After(*to) {
+		return nil, nil, "INVALID_TIME_RANGE"
+	}
+	return from, to, ""
+}
+
+// parseAsOf reads the optional "asOf" query parameter which selects the point
+// in time at which the consumers are reconstructed from their history.
+// If the parameter is invalid, the error code describing the issue is returned
+func parseAsOf(r *http.Request) (asOf *time.Time, errorCode string) {
+	rawAsOf := r.URL.Query().Get("asOf")
+	if rawAsOf == "" {
+		return nil, ""
+	}
+	timestamp, err := parseTimestamp(rawAsOf)
+	if err != nil {
+		return nil, "INVALID_TIMESTAMP"
+	}
+	return &timestamp, ""
+}
+
+// setChangingUser stores the user that sent the request in the transaction
+// to allow the consumer history to record who changed a consumer
+func setChangingUser(db dotsql.Execer, r *http.Request) error {
+	_, err := globals.SqlQueries.Exec(db, "set-changing-user", auth.PrincipalFromContext(r.Context()).User)
+	return err
+}
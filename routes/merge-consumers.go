@@ -0,0 +1,274 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/blockloop/scan/v2"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/rs/zerolog/log"
+	wisdomMiddleware "github.com/wisdom-oss/microservice-middlewares/v3"
+
+	"github.com/wisdom-oss/service-consumers/auth"
+	"github.com/wisdom-oss/service-consumers/globals"
+	"github.com/wisdom-oss/service-consumers/types"
+)
+
+// mergeStrategies contains the strategies that are supported for combining
+// the additional properties of the merged consumers
+var mergeStrategies = []string{"survivor", "duplicates", "survivor-only"}
+
+// mergeRequest contains the request body of a merge
+type mergeRequest struct {
+	Survivor   uuid.UUID   `json:"survivor"`
+	Duplicates []uuid.UUID `json:"duplicates"`
+	Strategy   string      `json:"strategy"`
+}
+
+// MergeConsumers merges one or more duplicate consumers into a surviving
+// consumer.
+// The usage records of the duplicates are reassigned to the survivor and the
+// additional properties are combined using the requested strategy:
+//   - survivor: the values of the survivor take precedence, properties only
+//     present on the duplicates are added
+//   - duplicates: the values of the duplicates take precedence. Duplicates
+//     listed later take precedence over the ones listed earlier
+//   - survivor-only: the properties of the duplicates are discarded
+//
+// Afterwards, the duplicates are deleted softly and the merge is recorded.
+// All changes are made in a single transaction
+func MergeConsumers(w http.ResponseWriter, r *http.Request) {
+	// get the error handler and the error handler status channel
+	errorHandler := r.Context().Value(wisdomMiddleware.ERROR_CHANNEL_NAME).(chan<- interface{})
+	statusChannel := r.Context().Value(wisdomMiddleware.STATUS_CHANNEL_NAME).(<-chan bool)
+
+	var request mergeRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to decode request body into merge request")
+		errorHandler <- requestBodyError(err, "unable to decode request body into merge request")
+		<-statusChannel
+		return
+	}
+
+	// now validate the merge request
+	if request.Strategy == "" {
+		request.Strategy = "survivor"
+	}
+	if !slices.Contains(mergeStrategies, request.Strategy) {
+		errorHandler <- "INVALID_MERGE_STRATEGY"
+		<-statusChannel
+		return
+	}
+	if request.Survivor == uuid.Nil || len(request.Duplicates) == 0 {
+		errorHandler <- "INVALID_MERGE_REQUEST"
+		<-statusChannel
+		return
+	}
+	consumerIDs := []string{request.Survivor.String()}
+	for _, duplicate := range request.Duplicates {
+		if slices.Contains(consumerIDs, duplicate.String()) {
+			errorHandler <- "INVALID_MERGE_REQUEST"
+			<-statusChannel
+			return
+		}
+		consumerIDs = append(consumerIDs, duplicate.String())
+	}
+
+	tx, err := requestDB(r).BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to start database transaction")
+		errorHandler <- fmt.Errorf("unable to start database transaction: %w", err)
+		<-statusChannel
+		return
+	}
+
+	err = setChangingUser(tx, r)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to set the changing user")
+		errorHandler <- fmt.Errorf("unable to set the changing user: %w", err)
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+
+	// now lock the consumers to prevent concurrent changes while merging them.
+	// consumers outside the areas of a restricted user are treated as unknown
+	rows, err := globals.SqlQueries.Query(tx, "lock-merge-consumers", pq.Array(consumerIDs), pq.Array(auth.PrincipalFromContext(r.Context()).Areas))
+	if err != nil {
+		log.Error().Err(err).Msg("unable to lock the consumers")
+		errorHandler <- fmt.Errorf("unable to lock the consumers: %w", err)
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+	var consumers []types.Consumer
+	err = scan.Rows(&consumers, rows)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to scan query results")
+		errorHandler <- fmt.Errorf("unable to scan query results: %w", err)
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+	if len(consumers) != len(consumerIDs) {
+		errorHandler <- "UNKNOWN_CONSUMER"
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+
+	// now sort the properties in the order of the request since the order
+	// of the duplicates is relevant for the merge strategies
+	properties := make([]*types.Map, len(consumerIDs))
+	for _, consumer := range consumers {
+		properties[slices.Index(consumerIDs, consumer.ID.String())] = consumer.AdditionalProperties
+	}
+	mergedProperties := mergeAdditionalProperties(request.Strategy, properties[0], properties[1:])
+
+	res, err := globals.SqlQueries.Exec(tx, "reassign-usage-records", request.Survivor, pq.Array(consumerIDs[1:]))
+	if err != nil {
+		log.Error().Err(err).Msg("unable to reassign the usage records")
+		errorHandler <- databaseError(err, "unable to reassign the usage records")
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+	reassignedUsageRecords, err := res.RowsAffected()
+	if err != nil {
+		log.Error().Err(err).Msg("unable to get the number of reassigned usage records")
+		errorHandler <- fmt.Errorf("unable to get the number of reassigned usage records: %w", err)
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+
+	_, err = globals.SqlQueries.Exec(tx, "update-consumer-additional-properties", request.Survivor, mergedProperties)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to update the additional properties of the survivor")
+		errorHandler <- databaseError(err, "unable to update the additional properties of the survivor")
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+
+	_, err = globals.SqlQueries.Exec(tx, "soft-delete-merged-consumers", request.Survivor, pq.Array(consumerIDs[1:]))
+	if err != nil {
+		log.Error().Err(err).Msg("unable to delete the merged consumers")
+		errorHandler <- databaseError(err, "unable to delete the merged consumers")
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+
+	// now record the merge to allow tracing the deleted consumers
+	rows, err = globals.SqlQueries.Query(tx, "insert-consumer-merge",
+		request.Survivor,
+		pq.Array(consumerIDs[1:]),
+		request.Strategy,
+		reassignedUsageRecords,
+		auth.PrincipalFromContext(r.Context()).User,
+	)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to record the merge")
+		errorHandler <- databaseError(err, "unable to record the merge")
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+	var merge types.ConsumerMerge
+	err = scan.Row(&merge, rows)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to scan the recorded merge")
+		errorHandler <- fmt.Errorf("unable to scan the recorded merge: %w", err)
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+
+	err = writeAuditEntry(tx, r, "merge-consumers", &request.Survivor,
+		types.Map{"additionalProperties": properties[0]},
+		types.Map{"additionalProperties": mergedProperties},
+	)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to write audit entry")
+		errorHandler <- fmt.Errorf("unable to write audit entry: %w", err)
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+
+	for idx := range request.Duplicates {
+		err = writeAuditEntry(tx, r, "merge-consumers", &request.Duplicates[idx], nil, types.Map{"mergedInto": request.Survivor})
+		if err != nil {
+			log.Error().Err(err).Msg("unable to write audit entry")
+			errorHandler <- fmt.Errorf("unable to write audit entry: %w", err)
+			<-statusChannel
+			tx.Rollback()
+			return
+		}
+	}
+
+	err = writeOutboxEvent(tx, "consumer.merged", &request.Survivor, merge)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to write outbox event")
+		errorHandler <- fmt.Errorf("unable to write outbox event: %w", err)
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		log.Error().Err(err).Msg("unable to commit changes to the database")
+		errorHandler <- fmt.Errorf("unable to commit changes to the database: %w", err)
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = encodeResponse(w, r, merge)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to encode merge into json")
+	}
+}
+
+// mergeAdditionalProperties combines the additional properties of the
+// survivor and the duplicates using the supplied strategy
+func mergeAdditionalProperties(strategy string, survivor *types.Map, duplicates []*types.Map) *types.Map {
+	if strategy == "survivor-only" {
+		return survivor
+	}
+
+	merged := types.Map{}
+	if strategy == "duplicates" && survivor != nil {
+		for key, value := range *survivor {
+			merged[key] = value
+		}
+	}
+	for _, duplicate := range duplicates {
+		if duplicate == nil {
+			continue
+		}
+		for key, value := range *duplicate {
+			if _, isSet := merged[key]; isSet && strategy == "survivor" {
+				continue
+			}
+			merged[key] = value
+		}
+	}
+	if strategy == "survivor" && survivor != nil {
+		for key, value := range *survivor {
+			merged[key] = value
+		}
+	}
+
+	if len(merged) == 0 {
+		return survivor
+	}
+	return &merged
+}
@@ -0,0 +1,89 @@
+package routes
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/wisdom-oss/service-consumers/types"
+)
+
+func TestMergeAdditionalProperties(t *testing.T) {
+	survivor := &types.Map{"meter": "S-1", "floor": 1}
+	duplicates := []*types.Map{
+		{"meter": "D-1", "phone": "0123"},
+		nil,
+		{"meter": "D-2", "phone": "0456", "garden": true},
+	}
+
+	tests := []struct {
+		name       string
+		strategy   string
+		survivor   *types.Map
+		duplicates []*types.Map
+		expected   *types.Map
+	}{
+		{
+			name:       "survivor takes precedence",
+			strategy:   "survivor",
+			survivor:   survivor,
+			duplicates: duplicates,
+			expected:   &types.Map{"meter": "S-1", "floor": 1, "phone": "0123", "garden": true},
+		},
+		{
+			name:       "duplicates take precedence",
+			strategy:   "duplicates",
+			survivor:   survivor,
+			duplicates: duplicates,
+			expected:   &types.Map{"meter": "D-2", "floor": 1, "phone": "0456", "garden": true},
+		},
+		{
+			name:       "survivor only",
+			strategy:   "survivor-only",
+			survivor:   survivor,
+			duplicates: duplicates,
+			expected:   survivor,
+		},
+		{
+			name:       "survivor without properties",
+			strategy:   "survivor",
+			survivor:   nil,
+			duplicates: duplicates[:1],
+			expected:   &types.Map{"meter": "D-1", "phone": "0123"},
+		},
+		{
+			name:       "duplicates without properties",
+			strategy:   "duplicates",
+			survivor:   survivor,
+			duplicates: []*types.Map{nil},
+			expected:   &types.Map{"meter": "S-1", "floor": 1},
+		},
+		{
+			name:       "no properties",
+			strategy:   "survivor",
+			survivor:   nil,
+			duplicates: []*types.Map{nil, {}},
+			expected:   nil,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			merged := mergeAdditionalProperties(test.strategy, test.survivor, test.duplicates)
+			if !reflect.DeepEqual(merged, test.expected) {
+				t.Fatalf("expected %v, got %v", test.expected, merged)
+			}
+		})
+	}
+}
+
+func TestMergeAdditionalPropertiesKeepsOriginals(t *testing.T) {
+	survivor := &types.Map{"meter": "S-1"}
+	duplicate := &types.Map{"meter": "D-1", "phone": "0123"}
+
+	for _, strategy := range mergeStrategies {
+		mergeAdditionalProperties(strategy, survivor, []*types.Map{duplicate})
+		if !reflect.DeepEqual(survivor, &types.Map{"meter": "S-1"}) || !reflect.DeepEqual(duplicate, &types.Map{"meter": "D-1", "phone": "0123"}) {
+			t.Fatalf("strategy %s changed the original properties", strategy)
+		}
+	}
+}
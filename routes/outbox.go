@@ -0,0 +1,24 @@
+package routes
+
+import (
+	"github.com/google/uuid"
+	"github.com/qustavo/dotsql"
+
+	"github.com/wisdom-oss/service-consumers/globals"
+	"github.com/wisdom-oss/service-consumers/types"
+)
+
+// eventTypes contains the types of the events that are written to the outbox
+var eventTypes = []string{"consumer.created", "consumer.updated", "consumer.deleted", "consumer.merged", "consumer.erased"}
+
+// writeOutboxEvent writes an event to the outbox. Since the outbox is written
+// in the same transaction as the change, the event is only published if the
+// change has been committed
+func writeOutboxEvent(db dotsql.Execer, eventType string, consumerID *uuid.UUID, payload interface{}) error {
+	fields, err := resourceFields(payload)
+	if err != nil {
+		return err
+	}
+	_, err = globals.SqlQueries.Exec(db, "insert-outbox-event", eventType, consumerID, types.Map(fields))
+	return err
+}
@@ -0,0 +1,102 @@
+package routes
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/wisdom-oss/service-consumers/globals"
+)
+
+// placeholderPattern matches the numbered placeholders used in sql queries
+var placeholderPattern = regexp.MustCompile(`\$(\d+)`)
+
+// queryBuilder assembles a sql query from a named base query and an arbitrary
+// amount of named filters.
+// The placeholders used in the filters are renumbered to match the position
+// of their argument in the final argument list
+type queryBuilder struct {
+	query     string
+	filters   []string
+	arguments []interface{}
+}
+
+// newQueryBuilder loads the named base query and registers the arguments
+// that are used by the base query itself
+func newQueryBuilder(baseQueryName string, arguments ...interface{}) (*queryBuilder, error) {
+	query, err := globals.SqlQueries.Raw(baseQueryName)
+	if err != nil {
+		return nil, err
+	}
+	query = strings.TrimSpace(query)
+	query = strings.TrimSuffix(query, ";")
+	return &queryBuilder{query: query, arguments: arguments}, nil
+}
+
+// addFilter loads the named filter and registers the argument that is used
+// in the filter
+func (b *queryBuilder) addFilter(filterName string, argument interface{}) error {
+	filter, err := globals.SqlQueries.Raw(filterName)
+	if err != nil {
+		return err
+	}
+	b.arguments = append(b.arguments, argument)
+	filter = strings.TrimSpace(filter)
+	filter = strings.TrimSuffix(filter, ";")
+	filter = strings.ReplaceAll(filter, "$1", fmt.Sprintf("$%d", len(b.arguments)))
+	b.filters = append(b.filters, filter)
+	return nil
+}
+
+// build merges the base query and the filters into a single query. the
+// named fragments supplied to the function (e.g., ordering clauses) are
+// appended after the filters
+func (b *queryBuilder) build(fragmentNames ...string) (string, error) {
+	query := b.query
+	if len(b.filters) > 0 {
+		if strings.Contains(strings.ToUpper(query), "WHERE") {
+			query += " AND "
+		} else {
+			query += " WHERE "
+		}
+		query += strings.Join(b.filters, " AND ")
+	}
+	for _, fragmentName := range fragmentNames {
+		fragment, err := globals.SqlQueries.Raw(fragmentName)
+		if err != nil {
+			return "", err
+		}
+		fragment = strings.TrimSpace(fragment)
+		fragment = strings.TrimSuffix(fragment, ";")
+		query += " " + fragment
+	}
+	return query + ";", nil
+}
+
+// buildWithin builds the query using the supplied fragments and embeds it as
+// common table expression with the supplied name into the named query.
+// The placeholders used in the named query are renumbered to follow the
+// arguments of the builder.
+// The function returns the merged query and the merged argument list
+func (b *queryBuilder) buildWithin(fragmentNames []string, expressionName string, queryName string, arguments ...interface{}) (string, []interface{}, error) {
+	innerQuery, err := b.build(fragmentNames...)
+	if err != nil {
+		return "", nil, err
+	}
+	innerQuery = strings.TrimSuffix(innerQuery, ";")
+
+	outerQuery, err := globals.SqlQueries.Raw(queryName)
+	if err != nil {
+		return "", nil, err
+	}
+	offset := len(b.arguments)
+	outerQuery = placeholderPattern.ReplaceAllStringFunc(outerQuery, func(placeholder string) string {
+		position, _ := strconv.Atoi(strings.TrimPrefix(placeholder, "$"))
+		return fmt.Sprintf("$%d", position+offset)
+	})
+
+	query := fmt.Sprintf("WITH %s AS (%s) %s", expressionName, innerQuery, strings.TrimSpace(outerQuery))
+	mergedArguments := append(append([]interface{}{}, b.arguments...), arguments...)
+	return query, mergedArguments, nil
+}
@@ -0,0 +1,59 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/wisdom-oss/service-consumers/auth"
+	"github.com/wisdom-oss/service-consumers/tracing"
+	"github.com/wisdom-oss/service-consumers/types"
+)
+
+// redactionPolicy contains the redaction applied to the responses sent to
+// users without the pii scope
+var redactionPolicy = types.Redaction{GridSize: 0.01}
+
+// ConfigureRedaction reads the redaction policy from the supplied environment
+func ConfigureRedaction(environment map[string]string) error {
+	var p types.Redaction
+	var err error
+
+	p.GridSize, err = strconv.ParseFloat(environment["REDACTION_GRID_SIZE"], 64)
+	if err != nil {
+		return fmt.Errorf("unable to parse redaction grid size: %w", err)
+	}
+	if p.GridSize < 0 {
+		return fmt.Errorf("negative redaction grid size: %f", p.GridSize)
+	}
+
+	for _, key := range strings.Split(environment["REDACTION_SENSITIVE_PROPERTIES"], ",") {
+		if strings.TrimSpace(key) != "" {
+			p.SensitiveProperties = append(p.SensitiveProperties, strings.TrimSpace(key))
+		}
+	}
+
+	redactionPolicy = p
+	return nil
+}
+
+// redactResponse redacts the personal data contained in the supplied value
+// if the user sending the request has not been granted the pii scope
+func redactResponse(r *http.Request, value interface{}) interface{} {
+	if auth.PrincipalFromContext(r.Context()).HasScope(auth.ScopePII) {
+		return value
+	}
+	return redactionPolicy.Redact(value)
+}
+
+// encodeResponse writes the json representation of the supplied value. Every
+// response is encoded using this function to redact the personal data for
+// users without the pii scope
+func encodeResponse(w io.Writer, r *http.Request, value interface{}) error {
+	_, span := tracing.Start(r.Context(), "encode response")
+	defer span.End()
+	return json.NewEncoder(w).Encode(redactResponse(r, value))
+}
@@ -0,0 +1,20 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// requestBodyError translates errors returned while reading the request body
+// into the matching error code from the errors.json file. Request bodies
+// exceeding the size limit of the route are reported as too large.
+// Errors that cannot be translated are wrapped using the message and are
+// therefore handled as internal errors
+func requestBodyError(err error, message string) interface{} {
+	var maxBytesError *http.MaxBytesError
+	if errors.As(err, &maxBytesError) {
+		return "REQUEST_BODY_TOO_LARGE"
+	}
+	return fmt.Errorf("%s: %w", message, err)
+}
@@ -0,0 +1,53 @@
+package routes
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	wisdomMiddleware "github.com/wisdom-oss/microservice-middlewares/v3"
+
+	"github.com/wisdom-oss/service-consumers/globals"
+)
+
+// RetryWebhookDelivery moves a dead delivery back into the delivery queue.
+// The number of attempts is reset which allows the delivery to be retried
+// with the configured backoff
+func RetryWebhookDelivery(w http.ResponseWriter, r *http.Request) {
+	// get the error handler and the error handler status channel
+	errorHandler := r.Context().Value(wisdomMiddleware.ERROR_CHANNEL_NAME).(chan<- interface{})
+	statusChannel := r.Context().Value(wisdomMiddleware.STATUS_CHANNEL_NAME).(<-chan bool)
+
+	deliveryID, err := uuid.Parse(chi.URLParam(r, "delivery-id"))
+	if err != nil {
+		errorHandler <- "INVALID_DELIVERY_ID"
+		<-statusChannel
+		return
+	}
+
+	res, err := globals.SqlQueries.Exec(requestDB(r), "retry-webhook-delivery", deliveryID)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to retry the webhook delivery")
+		errorHandler <- fmt.Errorf("unable to retry the webhook delivery: %w", err)
+		<-statusChannel
+		return
+	}
+
+	// only dead deliveries are retried
+	affectedRows, err := res.RowsAffected()
+	if err != nil {
+		log.Error().Err(err).Msg("unable to get the number of retried deliveries")
+		errorHandler <- fmt.Errorf("unable to get the number of retried deliveries: %w", err)
+		<-statusChannel
+		return
+	}
+	if affectedRows == 0 {
+		errorHandler <- "UNKNOWN_DEAD_LETTER"
+		<-statusChannel
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
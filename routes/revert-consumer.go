@@ -0,0 +1,164 @@
+package routes
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/blockloop/scan/v2"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/rs/zerolog/log"
+	wisdomMiddleware "github.com/wisdom-oss/microservice-middlewares/v3"
+
+	"github.com/wisdom-oss/service-consumers/auth"
+	"github.com/wisdom-oss/service-consumers/globals"
+	"github.com/wisdom-oss/service-consumers/types"
+)
+
+// RevertConsumer restores the representation of a consumer from a version
+// recorded in the consumer history.
+// The revert itself is recorded as a new version, so it may be reverted as
+// well. The new version is returned to the client
+func RevertConsumer(w http.ResponseWriter, r *http.Request) {
+	// get the error handler and the error handler status channel
+	errorHandler := r.Context().Value(wisdomMiddleware.ERROR_CHANNEL_NAME).(chan<- interface{})
+	statusChannel := r.Context().Value(wisdomMiddleware.STATUS_CHANNEL_NAME).(<-chan bool)
+
+	// now get the consumer id and the version from the url and validate them
+	consumerID, err := uuid.Parse(chi.URLParam(r, "consumer-id"))
+	if err != nil {
+		errorHandler <- "INVALID_CONSUMER_ID"
+		<-statusChannel
+		return
+	}
+	version, err := strconv.ParseInt(chi.URLParam(r, "version"), 10, 64)
+	if err != nil {
+		errorHandler <- "INVALID_CONSUMER_VERSION"
+		<-statusChannel
+		return
+	}
+
+	tx, err := requestDB(r).BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to start database transaction")
+		errorHandler <- fmt.Errorf("unable to start database transaction: %w", err)
+		<-statusChannel
+		return
+	}
+
+	err = setChangingUser(tx, r)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to set the changing user")
+		errorHandler <- fmt.Errorf("unable to set the changing user: %w", err)
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+
+	// now get the current version of the consumer to record the changes
+	rows, err := globals.SqlQueries.Query(tx, "get-current-consumer-version", consumerID)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to query database")
+		errorHandler <- fmt.Errorf("unable to query database: %w", err)
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+	var formerVersion types.ConsumerVersion
+	err = scan.Row(&formerVersion, rows)
+	if errors.Is(err, sql.ErrNoRows) {
+		errorHandler <- "UNKNOWN_CONSUMER_VERSION"
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("unable to scan query results")
+		errorHandler <- fmt.Errorf("unable to scan query results: %w", err)
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+
+	res, err := globals.SqlQueries.Exec(tx, "revert-consumer", consumerID, version, pq.Array(auth.PrincipalFromContext(r.Context()).Areas))
+	if err != nil {
+		log.Error().Err(err).Msg("unable to revert the consumer")
+		errorHandler <- databaseError(err, "unable to revert the consumer")
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+
+	// since the consumer is only reverted if the version belongs to the
+	// consumer and both versions are inside the areas of a restricted user,
+	// check that the consumer has been reverted
+	affectedRows, err := res.RowsAffected()
+	if err != nil {
+		log.Error().Err(err).Msg("unable to get the number of reverted consumers")
+		errorHandler <- fmt.Errorf("unable to get the number of reverted consumers: %w", err)
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+	if affectedRows == 0 {
+		errorHandler <- "UNKNOWN_CONSUMER_VERSION"
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+
+	rows, err = globals.SqlQueries.Query(tx, "get-current-consumer-version", consumerID)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to query database")
+		errorHandler <- fmt.Errorf("unable to query database: %w", err)
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+	var currentVersion types.ConsumerVersion
+	err = scan.Row(&currentVersion, rows)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to scan query results")
+		errorHandler <- fmt.Errorf("unable to scan query results: %w", err)
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+
+	err = writeAuditEntry(tx, r, "revert-consumer", &consumerID, formerVersion, currentVersion)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to write audit entry")
+		errorHandler <- fmt.Errorf("unable to write audit entry: %w", err)
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+
+	err = writeOutboxEvent(tx, "consumer.updated", &consumerID, currentVersion)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to write outbox event")
+		errorHandler <- fmt.Errorf("unable to write outbox event: %w", err)
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		log.Error().Err(err).Msg("unable to commit changes to the database")
+		errorHandler <- fmt.Errorf("unable to commit changes to the database: %w", err)
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = encodeResponse(w, r, currentVersion)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to encode consumer version into json")
+	}
+}
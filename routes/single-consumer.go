@@ -1,60 +1,87 @@
 package routes
 
 import (
-	"encoding/json"
+	"database/sql"
+	"errors"
 	"fmt"
 	"net/http"
-	"strings"
 
 	"github.com/blockloop/scan/v2"
 	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
 	"github.com/lib/pq"
 	"github.com/rs/zerolog/log"
 	wisdomMiddleware "github.com/wisdom-oss/microservice-middlewares/v3"
 
-	"github.com/wisdom-oss/service-consumers/globals"
+	"github.com/wisdom-oss/service-consumers/tracing"
 	"github.com/wisdom-oss/service-consumers/types"
 )
 
-// SingleConsumer allows pulling one consumer with all their data attached
+// SingleConsumer allows pulling one consumer with all their data attached.
+// The `asOf` query parameter allows reconstructing the consumer as it has been
+// at the supplied point in time.
+// Consumers outside the areas of a restricted user are reported as unknown
 func SingleConsumer(w http.ResponseWriter, r *http.Request) {
 	// get the error handler and the error handler status channel
 	errorHandler := r.Context().Value(wisdomMiddleware.ERROR_CHANNEL_NAME).(chan<- interface{})
 	statusChannel := r.Context().Value(wisdomMiddleware.STATUS_CHANNEL_NAME).(<-chan bool)
 
-	// now get the consumer id from the url
-	consumerID := chi.URLParam(r, "consumer-id")
+	// now get the consumer id from the url and validate it
+	consumerID, err := uuid.Parse(chi.URLParam(r, "consumer-id"))
+	if err != nil {
+		errorHandler <- "INVALID_CONSUMER_ID"
+		<-statusChannel
+		return
+	}
+
+	expandUsageType, errorCode := parseExpansions(r)
+	if errorCode != "" {
+		errorHandler <- errorCode
+		<-statusChannel
+		return
+	}
+
+	asOf, errorCode := parseAsOf(r)
+	if errorCode != "" {
+		errorHandler <- errorCode
+		<-statusChannel
+		return
+	}
 
-	// now build the sql query
-	baseQuery, err := globals.SqlQueries.Raw("get-consumers")
+	// now build the sql query. if a point in time has been requested, the
+	// consumer is reconstructed from the consumer history
+	var query *queryBuilder
+	if asOf != nil {
+		query, err = newQueryBuilder("get-consumers-as-of", asOf)
+	} else {
+		query, err = newQueryBuilder("get-consumers")
+	}
 	if err != nil {
 		log.Error().Err(err).Msg("unable to build query")
 		errorHandler <- fmt.Errorf("unable to build query: %w", err)
 		<-statusChannel
 		return
 	}
-	idFilter, err := globals.SqlQueries.Raw("filter-ids")
+	err = query.addFilter("filter-ids", pq.Array([]string{consumerID.String()}))
+	if err == nil {
+		err = restrictToCallerArea(query, r)
+	}
 	if err != nil {
 		log.Error().Err(err).Msg("unable to build query")
 		errorHandler <- fmt.Errorf("unable to build query: %w", err)
 		<-statusChannel
 		return
 	}
-
-	// now merge the two filters
-	sql := fmt.Sprintf(`%s WHERE %s`, strings.Trim(baseQuery, ";"), idFilter)
-
-	// now prepare the query
-	query, err := globals.Db.Prepare(sql)
+	rawQuery, err := query.build()
 	if err != nil {
-		log.Error().Err(err).Msg("unable to prepare database query")
-		errorHandler <- fmt.Errorf("unable to preparse database query: %w", err)
+		log.Error().Err(err).Msg("unable to build query")
+		errorHandler <- fmt.Errorf("unable to build query: %w", err)
 		<-statusChannel
 		return
 	}
 
 	// now execute the sql query
-	rows, err := query.Query(pq.Array([]string{consumerID}))
+	rows, err := requestDB(r).Query(rawQuery, query.arguments...)
 	if err != nil {
 		log.Error().Err(err).Msg("unable to query the database")
 		errorHandler <- fmt.Errorf("unable to query database: %w", err)
@@ -64,7 +91,14 @@ func SingleConsumer(w http.ResponseWriter, r *http.Request) {
 
 	// now scan the query results into a single consumer
 	var consumer types.Consumer
+	_, scanSpan := tracing.Start(r.Context(), "scan consumer")
 	err = scan.Row(&consumer, rows)
+	scanSpan.End()
+	if errors.Is(err, sql.ErrNoRows) {
+		errorHandler <- "UNKNOWN_CONSUMER"
+		<-statusChannel
+		return
+	}
 	if err != nil {
 		log.Error().Err(err).Msg("unable to parse database query results")
 		errorHandler <- fmt.Errorf("unable to parse query result: %w", err)
@@ -72,9 +106,21 @@ func SingleConsumer(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if expandUsageType {
+		consumers := []types.Consumer{consumer}
+		err = expandUsageTypes(requestDB(r), consumers)
+		if err != nil {
+			log.Error().Err(err).Msg("unable to expand usage type")
+			errorHandler <- fmt.Errorf("unable to expand usage type: %w", err)
+			<-statusChannel
+			return
+		}
+		consumer = consumers[0]
+	}
+
 	// since the consumer has been successfully scanned, return it to the
 	// user
-	err = json.NewEncoder(w).Encode(consumer)
+	err = encodeResponse(w, r, consumer)
 	if err != nil {
 		log.Error().Err(err).Msg("unable to return consumer")
 		errorHandler <- fmt.Errorf("unable to return json response: %w", err)
@@ -0,0 +1,81 @@
+package routes
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/blockloop/scan/v2"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/qustavo/dotsql"
+	"github.com/rs/zerolog/log"
+	wisdomMiddleware "github.com/wisdom-oss/microservice-middlewares/v3"
+
+	"github.com/wisdom-oss/service-consumers/types"
+)
+
+// SingleUsageType returns a single usage type
+func SingleUsageType(w http.ResponseWriter, r *http.Request) {
+	// get the error handler and the error handler status channel
+	errorHandler := r.Context().Value(wisdomMiddleware.ERROR_CHANNEL_NAME).(chan<- interface{})
+	statusChannel := r.Context().Value(wisdomMiddleware.STATUS_CHANNEL_NAME).(<-chan bool)
+
+	usageTypeID, err := uuid.Parse(chi.URLParam(r, "usage-type-id"))
+	if err != nil {
+		errorHandler <- "INVALID_USAGE_TYPE_ID"
+		<-statusChannel
+		return
+	}
+
+	usageType, err := getUsageType(requestDB(r), usageTypeID)
+	if errors.Is(err, sql.ErrNoRows) {
+		errorHandler <- "UNKNOWN_USAGE_TYPE"
+		<-statusChannel
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("unable to get usage type")
+		errorHandler <- fmt.Errorf("unable to get usage type: %w", err)
+		<-statusChannel
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = encodeResponse(w, r, usageType)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to encode usage type into json")
+		errorHandler <- fmt.Errorf("unable to encode usage type into json: %w", err)
+		<-statusChannel
+		return
+	}
+}
+
+// getUsageType queries a single usage type from the database. If the usage
+// type does not exist, sql.ErrNoRows is returned
+func getUsageType(db dotsql.Queryer, usageTypeID uuid.UUID) (*types.UsageType, error) {
+	query, err := newQueryBuilder("get-usage-types")
+	if err != nil {
+		return nil, err
+	}
+	err = query.addFilter("filter-ids", pq.Array([]string{usageTypeID.String()}))
+	if err != nil {
+		return nil, err
+	}
+	sql, err := query.build()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.Query(sql, query.arguments...)
+	if err != nil {
+		return nil, err
+	}
+	var usageType types.UsageType
+	err = scan.Row(&usageType, rows)
+	if err != nil {
+		return nil, err
+	}
+	return &usageType, nil
+}
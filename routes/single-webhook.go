@@ -0,0 +1,81 @@
+package routes
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/blockloop/scan/v2"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/qustavo/dotsql"
+	"github.com/rs/zerolog/log"
+	wisdomMiddleware "github.com/wisdom-oss/microservice-middlewares/v3"
+
+	"github.com/wisdom-oss/service-consumers/types"
+)
+
+// SingleWebhook returns a single webhook without its secret
+func SingleWebhook(w http.ResponseWriter, r *http.Request) {
+	// get the error handler and the error handler status channel
+	errorHandler := r.Context().Value(wisdomMiddleware.ERROR_CHANNEL_NAME).(chan<- interface{})
+	statusChannel := r.Context().Value(wisdomMiddleware.STATUS_CHANNEL_NAME).(<-chan bool)
+
+	webhookID, err := uuid.Parse(chi.URLParam(r, "webhook-id"))
+	if err != nil {
+		errorHandler <- "INVALID_WEBHOOK_ID"
+		<-statusChannel
+		return
+	}
+
+	webhook, err := getWebhook(requestDB(r), webhookID)
+	if errors.Is(err, sql.ErrNoRows) {
+		errorHandler <- "UNKNOWN_WEBHOOK"
+		<-statusChannel
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("unable to get webhook")
+		errorHandler <- fmt.Errorf("unable to get webhook: %w", err)
+		<-statusChannel
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = encodeResponse(w, r, webhook)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to encode webhook into json")
+		errorHandler <- fmt.Errorf("unable to encode webhook into json: %w", err)
+		<-statusChannel
+		return
+	}
+}
+
+// getWebhook queries a single webhook from the database. If the webhook does
+// not exist, sql.ErrNoRows is returned
+func getWebhook(db dotsql.Queryer, webhookID uuid.UUID) (*types.Webhook, error) {
+	query, err := newQueryBuilder("get-webhooks")
+	if err != nil {
+		return nil, err
+	}
+	err = query.addFilter("filter-ids", pq.Array([]string{webhookID.String()}))
+	if err != nil {
+		return nil, err
+	}
+	sql, err := query.build()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.Query(sql, query.arguments...)
+	if err != nil {
+		return nil, err
+	}
+	var webhook types.Webhook
+	err = scan.Row(&webhook, rows)
+	if err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
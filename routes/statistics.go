@@ -0,0 +1,173 @@
+package routes
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/blockloop/scan/v2"
+	"github.com/lib/pq"
+	"github.com/rs/zerolog/log"
+	wisdomMiddleware "github.com/wisdom-oss/microservice-middlewares/v3"
+
+	"github.com/wisdom-oss/service-consumers/types"
+)
+
+// statisticPercentiles contains the labels of the percentiles calculated by
+// the "statistics-summary" query in the order they are returned
+var statisticPercentiles = []string{"p25", "p50", "p75", "p90", "p95", "p99"}
+
+// defaultTopConsumers contains the number of consumers returned in the
+// ranking if no other number has been requested
+const defaultTopConsumers = 10
+
+// maximalTopConsumers contains the maximal number of consumers that may be
+// requested for the ranking
+const maximalTopConsumers = 1000
+
+// UsageStatistics calculates statistics about the usages of consumers.
+// The statistics may be restricted using the following query parameters:
+//   - in
+//   - from
+//   - to
+//   - top
+func UsageStatistics(w http.ResponseWriter, r *http.Request) {
+	// get the error handler and the error handler status channel
+	errorHandler := r.Context().Value(wisdomMiddleware.ERROR_CHANNEL_NAME).(chan<- interface{})
+	statusChannel := r.Context().Value(wisdomMiddleware.STATUS_CHANNEL_NAME).(<-chan bool)
+
+	shapeKeys, shapeKeysSet := r.URL.Query()["in"]
+
+	from, to, errorCode := parseTimeRange(r)
+	if errorCode != "" {
+		errorHandler <- errorCode
+		<-statusChannel
+		return
+	}
+
+	topConsumers := defaultTopConsumers
+	if rawTopConsumers := r.URL.Query().Get("top"); rawTopConsumers != "" {
+		var err error
+		topConsumers, err = strconv.Atoi(rawTopConsumers)
+		if err != nil || topConsumers < 1 || topConsumers > maximalTopConsumers {
+			errorHandler <- "INVALID_TOP_CONSUMERS"
+			<-statusChannel
+			return
+		}
+	}
+
+	// now build the query calculating the total usage per consumer which is
+	// used as basis for the statistics
+	consumerTotals, err := newQueryBuilder("statistics-consumer-totals", from, to)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to build sql")
+		errorHandler <- fmt.Errorf("unable to build sql: %w", err)
+		<-statusChannel
+		return
+	}
+	if shapeKeysSet {
+		err = consumerTotals.addFilter("filter-location", pq.Array(shapeKeys))
+		if err != nil {
+			log.Error().Err(err).Msg("unable to load filter sql")
+			errorHandler <- fmt.Errorf("unable to load filter sql: %w", err)
+			<-statusChannel
+			return
+		}
+	}
+	// users restricted to areas only include the consumers inside their areas
+	err = restrictToCallerArea(consumerTotals, r)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to load filter sql")
+		errorHandler <- fmt.Errorf("unable to load filter sql: %w", err)
+		<-statusChannel
+		return
+	}
+	fragments := []string{"group-statistics-consumer-totals"}
+
+	var statistics types.UsageStatistics
+
+	// now calculate the summary of the usages
+	sql, arguments, err := consumerTotals.buildWithin(fragments, "consumer_totals", "statistics-summary")
+	if err != nil {
+		log.Error().Err(err).Msg("unable to build sql")
+		errorHandler <- fmt.Errorf("unable to build sql: %w", err)
+		<-statusChannel
+		return
+	}
+	var percentiles pq.Float64Array
+	err = requestDB(r).QueryRow(sql, arguments...).Scan(
+		&statistics.Consumers, &statistics.TotalUsage, &statistics.MeanUsage, &percentiles)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to query database")
+		errorHandler <- fmt.Errorf("unable to query database: %w", err)
+		<-statusChannel
+		return
+	}
+	statistics.Percentiles = make(map[string]float64)
+	for idx, percentile := range percentiles {
+		statistics.Percentiles[statisticPercentiles[idx]] = percentile
+	}
+
+	// now get the consumers with the largest usages
+	sql, arguments, err = consumerTotals.buildWithin(fragments, "consumer_totals", "statistics-top-consumers", topConsumers)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to build sql")
+		errorHandler <- fmt.Errorf("unable to build sql: %w", err)
+		<-statusChannel
+		return
+	}
+	rows, err := requestDB(r).Query(sql, arguments...)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to query database")
+		errorHandler <- fmt.Errorf("unable to query database: %w", err)
+		<-statusChannel
+		return
+	}
+	err = scan.Rows(&statistics.TopConsumers, rows)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to scan query results")
+		errorHandler <- fmt.Errorf("unable to scan query results: %w", err)
+		<-statusChannel
+		return
+	}
+
+	// now get the distribution of the consumers per usage type
+	sql, arguments, err = consumerTotals.buildWithin(fragments, "consumer_totals", "statistics-usage-types")
+	if err != nil {
+		log.Error().Err(err).Msg("unable to build sql")
+		errorHandler <- fmt.Errorf("unable to build sql: %w", err)
+		<-statusChannel
+		return
+	}
+	rows, err = requestDB(r).Query(sql, arguments...)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to query database")
+		errorHandler <- fmt.Errorf("unable to query database: %w", err)
+		<-statusChannel
+		return
+	}
+	err = scan.Rows(&statistics.UsageTypes, rows)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to scan query results")
+		errorHandler <- fmt.Errorf("unable to scan query results: %w", err)
+		<-statusChannel
+		return
+	}
+
+	// to keep the response layout stable, return empty lists instead of null
+	if statistics.TopConsumers == nil {
+		statistics.TopConsumers = []types.ConsumerUsageTotal{}
+	}
+	if statistics.UsageTypes == nil {
+		statistics.UsageTypes = []types.UsageTypeDistribution{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = encodeResponse(w, r, statistics)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to encode statistics into json")
+		errorHandler <- fmt.Errorf("unable to encode statistics into json: %w", err)
+		<-statusChannel
+		return
+	}
+}
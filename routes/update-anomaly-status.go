@@ -0,0 +1,104 @@
+package routes
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/blockloop/scan/v2"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/rs/zerolog/log"
+	wisdomMiddleware "github.com/wisdom-oss/microservice-middlewares/v3"
+
+	"github.com/wisdom-oss/service-consumers/auth"
+	"github.com/wisdom-oss/service-consumers/globals"
+	"github.com/wisdom-oss/service-consumers/types"
+)
+
+// AcknowledgeAnomaly marks an anomaly as acknowledged which indicates that
+// the anomaly has been confirmed and is being taken care of
+func AcknowledgeAnomaly(w http.ResponseWriter, r *http.Request) {
+	updateAnomalyStatus(w, r, "acknowledged")
+}
+
+// DismissAnomaly marks an anomaly as dismissed which indicates that the
+// anomaly has been a false positive
+func DismissAnomaly(w http.ResponseWriter, r *http.Request) {
+	updateAnomalyStatus(w, r, "dismissed")
+}
+
+// updateAnomalyStatus sets the status of the anomaly referenced in the url
+// and returns the updated anomaly
+func updateAnomalyStatus(w http.ResponseWriter, r *http.Request, status string) {
+	// get the error handler and the error handler status channel
+	errorHandler := r.Context().Value(wisdomMiddleware.ERROR_CHANNEL_NAME).(chan<- interface{})
+	statusChannel := r.Context().Value(wisdomMiddleware.STATUS_CHANNEL_NAME).(<-chan bool)
+
+	anomalyID, err := uuid.Parse(chi.URLParam(r, "anomaly-id"))
+	if err != nil {
+		errorHandler <- "INVALID_ANOMALY_ID"
+		<-statusChannel
+		return
+	}
+
+	tx, err := requestDB(r).BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to start database transaction")
+		errorHandler <- fmt.Errorf("unable to start database transaction: %w", err)
+		<-statusChannel
+		return
+	}
+
+	principal := auth.PrincipalFromContext(r.Context())
+	rows, err := globals.SqlQueries.Query(tx, "update-anomaly-status", anomalyID, status, principal.User, pq.Array(principal.Areas))
+	if err != nil {
+		log.Error().Err(err).Msg("unable to update the anomaly status")
+		errorHandler <- fmt.Errorf("unable to update the anomaly status: %w", err)
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+
+	var anomaly types.Anomaly
+	err = scan.Row(&anomaly, rows)
+	if errors.Is(err, sql.ErrNoRows) {
+		errorHandler <- "UNKNOWN_ANOMALY"
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("unable to parse database query results")
+		errorHandler <- fmt.Errorf("unable to parse query result: %w", err)
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+
+	err = writeAuditEntry(tx, r, "update-anomaly-status", &anomaly.Consumer, nil, types.Map{"anomaly": anomaly.ID, "status": anomaly.Status})
+	if err != nil {
+		log.Error().Err(err).Msg("unable to write audit entry")
+		errorHandler <- fmt.Errorf("unable to write audit entry: %w", err)
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		log.Error().Err(err).Msg("unable to commit changes to the database")
+		errorHandler <- fmt.Errorf("unable to commit changes to the database: %w", err)
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = encodeResponse(w, r, anomaly)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to encode anomaly into json")
+	}
+}
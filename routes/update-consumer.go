@@ -1,17 +1,20 @@
 package routes
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
-	"strings"
 
 	"github.com/blockloop/scan/v2"
 	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
 	"github.com/lib/pq"
 	"github.com/rs/zerolog/log"
 	wisdomMiddleware "github.com/wisdom-oss/microservice-middlewares/v3"
 
+	"github.com/wisdom-oss/service-consumers/auth"
 	"github.com/wisdom-oss/service-consumers/globals"
 	"github.com/wisdom-oss/service-consumers/types"
 )
@@ -21,66 +24,74 @@ func UpdateConsumer(w http.ResponseWriter, r *http.Request) {
 	errorHandler := r.Context().Value(wisdomMiddleware.ERROR_CHANNEL_NAME).(chan<- interface{})
 	statusChannel := r.Context().Value(wisdomMiddleware.STATUS_CHANNEL_NAME).(<-chan bool)
 
-	// get the id of the consumer that shall be updated
-	consumerID := chi.URLParam(r, "consumer-id")
-
-	// now get the consumer that has the id
-	baseQuery, err := globals.SqlQueries.Raw("get-consumers")
+	// get the id of the consumer that shall be updated and validate it
+	consumerID, err := uuid.Parse(chi.URLParam(r, "consumer-id"))
 	if err != nil {
-		log.Error().Err(err).Msg("unable to build query")
-		errorHandler <- fmt.Errorf("unable to build query: %w", err)
+		errorHandler <- "INVALID_CONSUMER_ID"
 		<-statusChannel
 		return
 	}
-	idFilter, err := globals.SqlQueries.Raw("filter-ids")
+
+	// now parse the request body into the new consumer
+	var updatedConsumerRepresentation types.Consumer
+	err = json.NewDecoder(r.Body).Decode(&updatedConsumerRepresentation)
 	if err != nil {
-		log.Error().Err(err).Msg("unable to build query")
-		errorHandler <- fmt.Errorf("unable to build query: %w", err)
+		log.Error().Err(err).Msg("unable to decode request body into consumer")
+		errorHandler <- requestBodyError(err, "unable to decode request body into consumer")
 		<-statusChannel
 		return
 	}
 
-	// now merge the two filters
-	sql := fmt.Sprintf(`%s WHERE %s`, strings.Trim(baseQuery, ";"), idFilter)
+	tx, err := requestDB(r).BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to start database transaction")
+		errorHandler <- fmt.Errorf("unable to start database transaction: %w", err)
+		<-statusChannel
+		return
+	}
 
-	// now prepare the query
-	query, err := globals.Db.Prepare(sql)
+	err = setChangingUser(tx, r)
 	if err != nil {
-		log.Error().Err(err).Msg("unable to prepare database query")
-		errorHandler <- fmt.Errorf("unable to preparse database query: %w", err)
+		log.Error().Err(err).Msg("unable to set the changing user")
+		errorHandler <- fmt.Errorf("unable to set the changing user: %w", err)
 		<-statusChannel
+		tx.Rollback()
 		return
 	}
 
-	// now execute the sql query
-	rows, err := query.Query(pq.Array([]string{consumerID}))
+	// now get the consumer that has the id and lock it until the changes
+	// have been written to keep concurrent updates from being recorded
+	// against an outdated representation. consumers outside the areas of a
+	// restricted user are treated as unknown
+	rows, err := globals.SqlQueries.Query(tx, "lock-consumer", consumerID, pq.Array(auth.PrincipalFromContext(r.Context()).Areas))
 	if err != nil {
 		log.Error().Err(err).Msg("unable to query the database")
 		errorHandler <- fmt.Errorf("unable to query database: %w", err)
 		<-statusChannel
+		tx.Rollback()
 		return
 	}
 
 	// now scan the query results into a single consumer
 	var consumer types.Consumer
 	err = scan.Row(&consumer, rows)
-	if err != nil {
-		log.Error().Err(err).Msg("unable to parse database query results")
-		errorHandler <- fmt.Errorf("unable to parse query result: %w", err)
+	if errors.Is(err, sql.ErrNoRows) {
+		errorHandler <- "UNKNOWN_CONSUMER"
 		<-statusChannel
+		tx.Rollback()
 		return
 	}
-
-	// now parse the request body into the new consumer
-	var updatedConsumerRepresentation types.Consumer
-	err = json.NewDecoder(r.Body).Decode(&updatedConsumerRepresentation)
 	if err != nil {
-		log.Error().Err(err).Msg("unable to decode request body into consumer")
-		errorHandler <- fmt.Errorf("unable to decode request body into consumer: %w", err)
+		log.Error().Err(err).Msg("unable to parse database query results")
+		errorHandler <- fmt.Errorf("unable to parse query result: %w", err)
 		<-statusChannel
+		tx.Rollback()
 		return
 	}
 
+	// now keep the former representation to record the changes
+	formerConsumer := consumer
+
 	// now check which fields need to be updated
 	if consumer.Name != updatedConsumerRepresentation.Name {
 		consumer.Name = updatedConsumerRepresentation.Name
@@ -101,12 +112,35 @@ func UpdateConsumer(w http.ResponseWriter, r *http.Request) {
 		consumer.AdditionalProperties = updatedConsumerRepresentation.AdditionalProperties
 	}
 
-	// now write the consumer into the database
-	tx, err := globals.Db.BeginTx(r.Context(), nil)
+	// restricted users may not move consumers out of their areas
+	inArea, err := locationInCallerArea(tx, r, consumer.Location)
 	if err != nil {
-		log.Error().Err(err).Msg("unable to start database transaction")
-		errorHandler <- fmt.Errorf("unable to start database transaction: %w", err)
+		log.Error().Err(err).Msg("unable to check the location of the consumer")
+		errorHandler <- databaseError(err, "unable to check the location of the consumer")
 		<-statusChannel
+		tx.Rollback()
+		return
+	}
+	if !inArea {
+		errorHandler <- "CONSUMER_OUTSIDE_AREA"
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+
+	// now check that the usage type referenced by the consumer exists
+	usageTypeValid, err := usageTypesExist(tx, consumer.UsageType)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to check if the usage type exists")
+		errorHandler <- fmt.Errorf("unable to check if the usage type exists: %w", err)
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+	if !usageTypeValid {
+		errorHandler <- "UNKNOWN_USAGE_TYPE"
+		<-statusChannel
+		tx.Rollback()
 		return
 	}
 
@@ -120,8 +154,26 @@ func UpdateConsumer(w http.ResponseWriter, r *http.Request) {
 		consumerID,
 	)
 	if err != nil {
-		log.Error().Err(err).Msg("unable to insert the consumer into the database")
-		errorHandler <- fmt.Errorf("unable to insert the consumer into the database: %w", err)
+		log.Error().Err(err).Msg("unable to update the consumer in the database")
+		errorHandler <- databaseError(err, "unable to update the consumer in the database")
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+
+	err = writeAuditEntry(tx, r, "update-consumer", &consumerID, formerConsumer, consumer)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to write audit entry")
+		errorHandler <- fmt.Errorf("unable to write audit entry: %w", err)
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+
+	err = writeOutboxEvent(tx, "consumer.updated", &consumerID, consumer)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to write outbox event")
+		errorHandler <- fmt.Errorf("unable to write outbox event: %w", err)
 		<-statusChannel
 		tx.Rollback()
 		return
@@ -139,5 +191,4 @@ func UpdateConsumer(w http.ResponseWriter, r *http.Request) {
 	// now set the location header and indicate that the consumer has been
 	// created
 	w.WriteHeader(http.StatusOK)
-	err = tx.Commit()
 }
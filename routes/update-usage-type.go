@@ -0,0 +1,136 @@
+package routes
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	wisdomMiddleware "github.com/wisdom-oss/microservice-middlewares/v3"
+
+	"github.com/wisdom-oss/service-consumers/globals"
+	"github.com/wisdom-oss/service-consumers/types"
+)
+
+// UpdateUsageType replaces the representation of a usage type with the one
+// contained in the request body. This allows renaming usage types and moving
+// them in the hierarchy
+func UpdateUsageType(w http.ResponseWriter, r *http.Request) {
+	// get the error handler and the error handler status channel
+	errorHandler := r.Context().Value(wisdomMiddleware.ERROR_CHANNEL_NAME).(chan<- interface{})
+	statusChannel := r.Context().Value(wisdomMiddleware.STATUS_CHANNEL_NAME).(<-chan bool)
+
+	usageTypeID, err := uuid.Parse(chi.URLParam(r, "usage-type-id"))
+	if err != nil {
+		errorHandler <- "INVALID_USAGE_TYPE_ID"
+		<-statusChannel
+		return
+	}
+
+	var usageType types.UsageType
+	err = json.NewDecoder(r.Body).Decode(&usageType)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to decode request body into usage type")
+		errorHandler <- requestBodyError(err, "unable to decode request body into usage type")
+		<-statusChannel
+		return
+	}
+	usageType.ID = usageTypeID
+
+	tx, err := requestDB(r).BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to start database transaction")
+		errorHandler <- fmt.Errorf("unable to start database transaction: %w", err)
+		<-statusChannel
+		return
+	}
+
+	// now get the current representation of the usage type to record the
+	// changes
+	formerUsageType, err := getUsageType(tx, usageTypeID)
+	if errors.Is(err, sql.ErrNoRows) {
+		errorHandler <- "UNKNOWN_USAGE_TYPE"
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("unable to get the usage type")
+		errorHandler <- fmt.Errorf("unable to get the usage type: %w", err)
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+
+	errorCode, err := validateUsageType(tx, usageType, &usageTypeID)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to validate usage type")
+		errorHandler <- fmt.Errorf("unable to validate usage type: %w", err)
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+	if errorCode != "" {
+		errorHandler <- errorCode
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+
+	res, err := globals.SqlQueries.Exec(tx, "update-usage-type",
+		usageTypeID,
+		usageType.Name,
+		usageType.Description,
+		usageType.ExternalCode,
+		usageType.Parent,
+	)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to update the usage type")
+		errorHandler <- databaseError(err, "unable to update the usage type")
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+	affectedRows, err := res.RowsAffected()
+	if err != nil {
+		log.Error().Err(err).Msg("unable to get the number of updated usage types")
+		errorHandler <- fmt.Errorf("unable to get the number of updated usage types: %w", err)
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+	if affectedRows == 0 {
+		errorHandler <- "UNKNOWN_USAGE_TYPE"
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+
+	err = writeAuditEntry(tx, r, "update-usage-type", nil, formerUsageType, usageType)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to write audit entry")
+		errorHandler <- fmt.Errorf("unable to write audit entry: %w", err)
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		log.Error().Err(err).Msg("unable to commit changes to the database")
+		errorHandler <- fmt.Errorf("unable to commit changes to the database: %w", err)
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = encodeResponse(w, r, usageType)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to encode usage type into json")
+	}
+}
@@ -0,0 +1,124 @@
+package routes
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/blockloop/scan/v2"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	wisdomMiddleware "github.com/wisdom-oss/microservice-middlewares/v3"
+
+	"github.com/wisdom-oss/service-consumers/globals"
+	"github.com/wisdom-oss/service-consumers/types"
+)
+
+// UpdateWebhook replaces the webhook with the one sent in the request body.
+// If no secret is supplied, the current secret of the webhook is kept
+func UpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	// get the error handler and the error handler status channel
+	errorHandler := r.Context().Value(wisdomMiddleware.ERROR_CHANNEL_NAME).(chan<- interface{})
+	statusChannel := r.Context().Value(wisdomMiddleware.STATUS_CHANNEL_NAME).(<-chan bool)
+
+	webhookID, err := uuid.Parse(chi.URLParam(r, "webhook-id"))
+	if err != nil {
+		errorHandler <- "INVALID_WEBHOOK_ID"
+		<-statusChannel
+		return
+	}
+
+	webhook := types.Webhook{Active: true}
+	err = json.NewDecoder(r.Body).Decode(&webhook)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to decode request body into webhook")
+		errorHandler <- requestBodyError(err, "unable to decode request body into webhook")
+		<-statusChannel
+		return
+	}
+
+	if errorCode := validateWebhook(webhook); errorCode != "" {
+		errorHandler <- errorCode
+		<-statusChannel
+		return
+	}
+	if webhook.EventTypes == nil {
+		webhook.EventTypes = []string{}
+	}
+
+	tx, err := requestDB(r).BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to start database transaction")
+		errorHandler <- fmt.Errorf("unable to start database transaction: %w", err)
+		<-statusChannel
+		return
+	}
+
+	formerWebhook, err := getWebhook(tx, webhookID)
+	if errors.Is(err, sql.ErrNoRows) {
+		errorHandler <- "UNKNOWN_WEBHOOK"
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("unable to get webhook")
+		errorHandler <- fmt.Errorf("unable to get webhook: %w", err)
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+
+	rows, err := globals.SqlQueries.Query(tx, "update-webhook",
+		webhookID,
+		webhook.URL,
+		webhook.Secret,
+		webhook.EventTypes,
+		webhook.Active,
+	)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to update the webhook")
+		errorHandler <- databaseError(err, "unable to update the webhook")
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+
+	// the secret is not returned after updating the webhook
+	webhook = types.Webhook{}
+	err = scan.Row(&webhook, rows)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to get the updated webhook")
+		errorHandler <- databaseError(err, "unable to get the updated webhook")
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+
+	err = writeAuditEntry(tx, r, "update-webhook", nil, formerWebhook, webhook)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to write audit entry")
+		errorHandler <- fmt.Errorf("unable to write audit entry: %w", err)
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		log.Error().Err(err).Msg("unable to commit changes to the database")
+		errorHandler <- fmt.Errorf("unable to commit changes to the database: %w", err)
+		<-statusChannel
+		tx.Rollback()
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = encodeResponse(w, r, webhook)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to encode webhook into json")
+	}
+}
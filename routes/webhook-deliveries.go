@@ -0,0 +1,114 @@
+package routes
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/blockloop/scan/v2"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/rs/zerolog/log"
+	wisdomMiddleware "github.com/wisdom-oss/microservice-middlewares/v3"
+
+	"github.com/wisdom-oss/service-consumers/types"
+)
+
+// deliveryStatuses contains the statuses a webhook delivery may have
+var deliveryStatuses = []string{"pending", "delivered", "dead"}
+
+// WebhookDeliveries returns the delivery log of a single webhook. The
+// deliveries may be filtered by their status using the `status` parameter
+func WebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	// get the error handler and the error handler status channel
+	errorHandler := r.Context().Value(wisdomMiddleware.ERROR_CHANNEL_NAME).(chan<- interface{})
+	statusChannel := r.Context().Value(wisdomMiddleware.STATUS_CHANNEL_NAME).(<-chan bool)
+
+	webhookID, err := uuid.Parse(chi.URLParam(r, "webhook-id"))
+	if err != nil {
+		errorHandler <- "INVALID_WEBHOOK_ID"
+		<-statusChannel
+		return
+	}
+
+	var statuses []string
+	for _, status := range r.URL.Query()["status"] {
+		status = strings.TrimSpace(status)
+		if !slices.Contains(deliveryStatuses, status) {
+			errorHandler <- "INVALID_DELIVERY_STATUS"
+			<-statusChannel
+			return
+		}
+		statuses = append(statuses, status)
+	}
+
+	writeWebhookDeliveries(w, r, &webhookID, statuses)
+}
+
+// DeadLetters returns the deliveries of all webhooks which have been
+// abandoned after reaching the maximal number of attempts
+func DeadLetters(w http.ResponseWriter, r *http.Request) {
+	writeWebhookDeliveries(w, r, nil, []string{"dead"})
+}
+
+// writeWebhookDeliveries queries the deliveries matching the supplied webhook
+// and statuses and writes them to the response
+func writeWebhookDeliveries(w http.ResponseWriter, r *http.Request, webhookID *uuid.UUID, statuses []string) {
+	// get the error handler and the error handler status channel
+	errorHandler := r.Context().Value(wisdomMiddleware.ERROR_CHANNEL_NAME).(chan<- interface{})
+	statusChannel := r.Context().Value(wisdomMiddleware.STATUS_CHANNEL_NAME).(<-chan bool)
+
+	query, err := newQueryBuilder("get-webhook-deliveries")
+	if err == nil && webhookID != nil {
+		err = query.addFilter("filter-delivery-webhooks", pq.Array([]string{webhookID.String()}))
+	}
+	if err == nil && len(statuses) > 0 {
+		err = query.addFilter("filter-delivery-status", pq.Array(statuses))
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("unable to build sql")
+		errorHandler <- fmt.Errorf("unable to build sql: %w", err)
+		<-statusChannel
+		return
+	}
+	sql, err := query.build("order-webhook-deliveries")
+	if err != nil {
+		log.Error().Err(err).Msg("unable to build sql")
+		errorHandler <- fmt.Errorf("unable to build sql: %w", err)
+		<-statusChannel
+		return
+	}
+
+	rows, err := requestDB(r).Query(sql, query.arguments...)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to query database")
+		errorHandler <- fmt.Errorf("unable to query database: %w", err)
+		<-statusChannel
+		return
+	}
+
+	var deliveries []types.WebhookDelivery
+	err = scan.Rows(&deliveries, rows)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to scan query results")
+		errorHandler <- fmt.Errorf("unable to scan query results: %w", err)
+		<-statusChannel
+		return
+	}
+
+	if len(deliveries) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = encodeResponse(w, r, deliveries)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to encode webhook deliveries into json")
+		errorHandler <- fmt.Errorf("unable to encode webhook deliveries into json: %w", err)
+		<-statusChannel
+		return
+	}
+}
@@ -0,0 +1,149 @@
+// Package tenancy separates the data of the tenants hosted by a single
+// deployment. Every database connection used by a request is bound to the
+// tenant of the user. The row level security policies of the database only
+// expose the rows of the tenant the connection is bound to, which makes
+// accessing the rows of other tenants impossible regardless of the executed
+// queries.
+package tenancy
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"net/http"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/wisdom-oss/service-consumers/auth"
+	"github.com/wisdom-oss/service-consumers/globals"
+)
+
+// setting contains the name of the database setting the row level security
+// policies read the tenant of the connection from
+const setting = "consumers.tenant"
+
+// System contains the tenant used by the background jobs. Connections bound
+// to the system tenant may read the rows of every tenant and update the rows
+// maintained by the background jobs, but may not create any rows. Since it is not a valid tenant name, users are unable to request
+// the system tenant
+const System = "*"
+
+// ErrInvalidTenant is returned if a connection should be bound to an invalid
+// tenant
+var ErrInvalidTenant = errors.New("invalid tenant")
+
+// connKey is the key under which the connection of a request is stored in the
+// request context
+type connKey struct{}
+
+// Conn is a database connection bound to a single tenant. Next to the methods
+// of the connection, it provides the methods of sql.DB which do not accept a
+// context. These methods use the context the connection has been acquired
+// with
+type Conn struct {
+	*sql.Conn
+	ctx     context.Context
+	release sync.Once
+}
+
+// Acquire takes a connection from the pool and binds it to the tenant
+func Acquire(ctx context.Context, db *sql.DB, tenant string) (*Conn, error) {
+	if tenant != System && !auth.ValidTenant(tenant) {
+		return nil, ErrInvalidTenant
+	}
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	_, err = conn.ExecContext(ctx, "SELECT set_config($1, $2, false)", setting, tenant)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return &Conn{Conn: conn, ctx: ctx}, nil
+}
+
+// Release removes the binding of the connection and returns it to the pool.
+// If the binding cannot be removed, the connection is discarded to prevent
+// other requests from using it. Releasing a connection multiple times has no
+// effect
+func (c *Conn) Release() {
+	c.release.Do(func() {
+		_, err := c.Conn.ExecContext(context.Background(), "RESET "+setting)
+		if err != nil {
+			log.Warn().Err(err).Msg("unable to reset tenant of database connection. discarding connection")
+			_ = c.Conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+		}
+		_ = c.Conn.Close()
+	})
+}
+
+// Query executes a query that returns rows
+func (c *Conn) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return c.Conn.QueryContext(c.ctx, query, args...)
+}
+
+// QueryRow executes a query that is expected to return at most one row
+func (c *Conn) QueryRow(query string, args ...interface{}) *sql.Row {
+	return c.Conn.QueryRowContext(c.ctx, query, args...)
+}
+
+// Exec executes a query without returning any rows
+func (c *Conn) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return c.Conn.ExecContext(c.ctx, query, args...)
+}
+
+// Prepare creates a prepared statement for later queries or executions
+func (c *Conn) Prepare(query string) (*sql.Stmt, error) {
+	return c.Conn.PrepareContext(c.ctx, query)
+}
+
+// Bind acquires a connection bound to the tenant of the user for every
+// request and stores it in the request context. The connection is released
+// after the request has been handled
+func Bind(db *sql.DB) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tenant := auth.PrincipalFromContext(r.Context()).Tenant
+			conn, err := Acquire(r.Context(), db, tenant)
+			if err != nil {
+				log.Error().Err(err).Str("tenant", tenant).Msg("unable to bind database connection to tenant")
+				_ = globals.Errors["TENANT_BINDING_FAILED"].Send(w)
+				return
+			}
+			defer conn.Release()
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), connKey{}, conn)))
+		})
+	}
+}
+
+// FromContext returns the connection bound to the tenant of the request. It
+// returns nil if no connection has been bound
+func FromContext(ctx context.Context) *Conn {
+	conn, _ := ctx.Value(connKey{}).(*Conn)
+	return conn
+}
+
+// BeginSystemTx starts a transaction bound to the system tenant. The binding
+// ends together with the transaction
+func BeginSystemTx(ctx context.Context, db *sql.DB, opts *sql.TxOptions) (*sql.Tx, error) {
+	tx, err := db.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	err = BindTx(ctx, tx, System)
+	if err != nil {
+		_ = tx.Rollback()
+		return nil, err
+	}
+	return tx, nil
+}
+
+// BindTx binds an open transaction to the tenant. The binding replaces the
+// previous binding of the transaction and ends together with the transaction
+func BindTx(ctx context.Context, tx *sql.Tx, tenant string) error {
+	_, err := tx.ExecContext(ctx, "SELECT set_config($1, $2, true)", setting, tenant)
+	return err
+}
@@ -0,0 +1,139 @@
+// Package tracing records the spans of the handled requests, the executed
+// database statements and the serialization of the responses using
+// OpenTelemetry. The spans are exported using OTLP if configured. Otherwise,
+// the spans are discarded.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	chiMiddleware "github.com/go-chi/chi/v5/middleware"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName identifies the tracer of the service
+const instrumentationName = "github.com/wisdom-oss/service-consumers"
+
+// queryNameKey contains the attribute containing the name of the query a
+// database statement has been loaded from
+const queryNameKey = attribute.Key("db.query.name")
+
+// Configure sets up the propagation of the trace context and the export of
+// the spans from the supplied environment. The trace context sent by the
+// clients is always propagated using the W3C Trace Context headers. If no
+// exporter is configured, the spans are discarded.
+// The returned function flushes the remaining spans and stops the export
+func Configure(ctx context.Context, environment map[string]string, serviceName string) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	switch exporter := strings.TrimSpace(environment["TRACING_EXPORTER"]); exporter {
+	case "", "none":
+		return func(context.Context) error { return nil }, nil
+	case "otlp":
+	default:
+		return nil, fmt.Errorf("unsupported tracing exporter: %s", exporter)
+	}
+
+	sampleRatio, err := strconv.ParseFloat(environment["TRACING_SAMPLE_RATIO"], 64)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse tracing sample ratio: %w", err)
+	}
+
+	// the endpoint of the exporter is configured using the environment
+	// variables defined by the OTLP exporter specification (e.g.,
+	// OTEL_EXPORTER_OTLP_ENDPOINT)
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create otlp exporter: %w", err)
+	}
+
+	serviceResource, err := resource.Merge(resource.Default(),
+		resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(serviceName)))
+	if err != nil {
+		return nil, fmt.Errorf("unable to build tracing resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(serviceResource),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(sampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// tracer returns the tracer of the service. The tracer is taken from the
+// global provider on every call since the provider is replaced while
+// configuring the export
+func tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Start starts a new span as child of the span contained in the context
+func Start(ctx context.Context, name string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer().Start(ctx, name, trace.WithAttributes(attributes...))
+}
+
+// Middleware records a span for every request. The span continues the trace
+// of the client if the request contains a trace context. Since the route is
+// only known after routing, the span is named after the route pattern once
+// the request has been handled
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := tracer().Start(ctx, r.Method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(r.Method),
+				semconv.URLPath(r.URL.Path),
+			),
+		)
+		defer span.End()
+
+		ww := chiMiddleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r.WithContext(ctx))
+
+		if routeContext := chi.RouteContext(r.Context()); routeContext != nil && routeContext.RoutePattern() != "" {
+			span.SetName(r.Method + " " + routeContext.RoutePattern())
+			span.SetAttributes(semconv.HTTPRoute(routeContext.RoutePattern()))
+		}
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+	})
+}
+
+// ObserveQuery records a span for a database statement named after the query
+// the statement has been loaded from. It is registered as observer of the
+// database driver
+func ObserveQuery(ctx context.Context, queryName string) func(error) {
+	_, span := tracer().Start(ctx, queryName,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBSystemPostgreSQL, queryNameKey.String(queryName)),
+	)
+	return func(err error) {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+}
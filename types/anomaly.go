@@ -0,0 +1,52 @@
+package types
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Anomaly contains a usage anomaly that has been detected for a consumer
+type Anomaly struct {
+	// ID contains the identifier of the anomaly
+	ID uuid.UUID `db:"id" json:"id"`
+
+	// Consumer contains the identifier of the consumer the anomaly has been
+	// detected for
+	Consumer uuid.UUID `db:"consumer" json:"consumer"`
+
+	// UsageRecord contains the identifier of the usage record that deviates
+	// from the consumer's history. It is null for missing readings
+	UsageRecord *uuid.UUID `db:"usage_record" json:"usageRecord"`
+
+	// Kind contains the kind of the anomaly (either "deviation" or
+	// "missing_readings")
+	Kind string `db:"kind" json:"kind"`
+
+	// ReadingDate contains the date of the deviating reading or the date of
+	// the last reading if the meter stopped reporting
+	ReadingDate *time.Time `db:"reading_date" json:"readingDate"`
+
+	// Amount contains the amount of the deviating reading
+	Amount *float64 `db:"amount" json:"amount"`
+
+	// Expected contains the amount that has been expected based on the
+	// consumer's history
+	Expected *float64 `db:"expected" json:"expected"`
+
+	// Score contains the score calculated by the detection method
+	Score *float64 `db:"score" json:"score"`
+
+	// DetectedAt contains the point in time the anomaly has been detected at
+	DetectedAt time.Time `db:"detected_at" json:"detectedAt"`
+
+	// Status contains the status of the anomaly (either "open",
+	// "acknowledged" or "dismissed")
+	Status string `db:"status" json:"status"`
+
+	// StatusChangedAt contains the point in time the status has been changed
+	StatusChangedAt *time.Time `db:"status_changed_at" json:"statusChangedAt"`
+
+	// StatusChangedBy contains the user that changed the status
+	StatusChangedBy *string `db:"status_changed_by" json:"statusChangedBy"`
+}
@@ -0,0 +1,52 @@
+package types
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// APIKey contains a key used by machine clients to access the service
+type APIKey struct {
+	// ID contains the identifier of the api key
+	ID uuid.UUID `db:"id" json:"id"`
+
+	// Name contains a description of the client using the key
+	Name string `db:"name" json:"name"`
+
+	// Prefix contains the first characters of the key which allow
+	// identifying the key without revealing it
+	Prefix string `db:"prefix" json:"prefix"`
+
+	// Tenant contains the tenant the key has been created in. The key only
+	// grants access to the consumers and usages of this tenant
+	Tenant string `db:"tenant" json:"tenant"`
+
+	// Key contains the api key. It is only returned while creating the key
+	// since only a hash of the key is stored
+	Key *string `db:"-" json:"key,omitempty"`
+
+	// Scopes contains the scopes granted to the key
+	Scopes pq.StringArray `db:"scopes" json:"scopes"`
+
+	// Areas contains the keys of the shapes the key is restricted to. If no
+	// areas are set, the key is not restricted
+	Areas pq.StringArray `db:"areas" json:"areas"`
+
+	// ExpiresAt contains the point in time the key expires at. If it is not
+	// set, the key does not expire
+	ExpiresAt *time.Time `db:"expires_at" json:"expiresAt"`
+
+	// LastUsedAt contains the point in time the key has been used last
+	LastUsedAt *time.Time `db:"last_used_at" json:"lastUsedAt"`
+
+	// CreatedBy contains the user that created the key
+	CreatedBy *string `db:"created_by" json:"createdBy"`
+
+	// CreatedAt contains the point in time the key has been created at
+	CreatedAt time.Time `db:"created_at" json:"createdAt"`
+
+	// RevokedAt contains the point in time the key has been revoked at
+	RevokedAt *time.Time `db:"revoked_at" json:"revokedAt"`
+}
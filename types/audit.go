@@ -0,0 +1,38 @@
+package types
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// AuditEntry contains the record of a single change made through the api
+type AuditEntry struct {
+	// ID contains the identifier of the audit entry
+	ID uuid.UUID `db:"id" json:"id"`
+
+	// OccurredAt contains the point in time the change has been made at
+	OccurredAt time.Time `db:"occurred_at" json:"occurredAt"`
+
+	// User contains the user that made the change
+	User *string `db:"username" json:"user"`
+
+	// Groups contains the groups the user has been a member of while making
+	// the change
+	Groups pq.StringArray `db:"groups" json:"groups"`
+
+	// RequestID contains the id of the request that made the change
+	RequestID *string `db:"request_id" json:"requestID"`
+
+	// Action contains the kind of change that has been made (e.g.,
+	// "create-consumer")
+	Action string `db:"action" json:"action"`
+
+	// Consumer contains the identifier of the consumer that has been changed.
+	// It is null for changes that do not affect a single consumer
+	Consumer *uuid.UUID `db:"consumer" json:"consumer"`
+
+	// Changes contains the changed fields mapped to their old and new values
+	Changes *Map `db:"changes" json:"changes" redact:"changes"`
+}
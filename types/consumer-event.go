@@ -0,0 +1,16 @@
+package types
+
+// ConsumerEvent contains a change of a consumer that is published to the
+// clients subscribed to the consumer events. The event is built from the
+// version of the consumer recorded by the change
+type ConsumerEvent struct {
+	// Event contains the kind of change (either "created", "updated" or
+	// "deleted")
+	Event string `db:"event" json:"-"`
+
+	// Tenant contains the tenant the consumer belongs to. The event is only
+	// published to the subscribers of this tenant
+	Tenant string `db:"tenant" json:"-"`
+
+	ConsumerVersion
+}
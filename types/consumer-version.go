@@ -0,0 +1,60 @@
+package types
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/paulmach/go.geojson"
+)
+
+// ConsumerVersion contains a single version of a consumer that has been
+// recorded in the history of the consumer
+type ConsumerVersion struct {
+	// Version contains the identifier of the version
+	Version int64 `db:"version" json:"version"`
+
+	// ID contains the identifier of the consumer
+	ID uuid.UUID `db:"id" json:"id"`
+
+	// Operation contains the operation that created the version (either
+	// "snapshot", "create", "update" or "delete")
+	Operation string `db:"operation" json:"operation"`
+
+	// Name contains the name of the consumer
+	Name string `db:"name" json:"name" redact:"mask"`
+
+	// Description contains a short and optional description of the consumer
+	Description *string `db:"description" json:"description"`
+
+	// Address contains a human-readable location of the consumer
+	Address *string `db:"address" json:"address" redact:"mask"`
+
+	// Location contains the GeoJSON representation of the consumer's location
+	// as a geometry
+	Location *geojson.Geometry `db:"location" json:"location" redact:"location"`
+
+	// UsageType contains the usage type that the consumer has been assigned to
+	UsageType *uuid.UUID `db:"usage_type" json:"usageType"`
+
+	// AdditionalProperties contain additional properties that further apply
+	// to the consumer
+	AdditionalProperties *Map `db:"additional_properties" json:"additionalProperties" redact:"properties"`
+
+	// DeletedAt contains the point in time the consumer has been deleted at
+	DeletedAt *time.Time `db:"deleted_at" json:"deletedAt"`
+
+	// MergedInto contains the consumer this consumer has been merged into
+	MergedInto *uuid.UUID `db:"merged_into" json:"mergedInto"`
+
+	// ValidFrom contains the point in time from which on the version has been
+	// the current representation of the consumer
+	ValidFrom time.Time `db:"valid_from" json:"validFrom"`
+
+	// ValidUntil contains the point in time until which the version has been
+	// the current representation of the consumer. It is null for the current
+	// version
+	ValidUntil *time.Time `db:"valid_until" json:"validUntil"`
+
+	// ChangedBy contains the user that created the version
+	ChangedBy *string `db:"changed_by" json:"changedBy"`
+}
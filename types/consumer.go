@@ -17,24 +17,28 @@ type Consumer struct {
 	ID uuid.UUID `db:"id" json:"id"`
 
 	// Name contains the name of the consumer
-	Name string `db:"name" json:"name"`
+	Name string `db:"name" json:"name" redact:"mask"`
 
 	// Description contains a short and optional description of the consumer
 	Description *string `db:"description" json:"description"`
 
 	// Address contains a human-readable location of the consumer
-	Address *string `db:"address" json:"address"`
+	Address *string `db:"address" json:"address" redact:"mask"`
 
 	// Location contains the GeoJSON representation of the consumer's location
 	// as a geometry
-	Location *geojson.Geometry `db:"location" json:"location"`
+	Location *geojson.Geometry `db:"location" json:"location" redact:"location"`
 
 	// UsageType contains the usage type that the consumer has been assigned to
 	UsageType *uuid.UUID `db:"usage_type" json:"usageType"`
 
+	// UsageTypeDetails contains the resolved usage type of the consumer. It is
+	// only populated if the expansion of the usage type has been requested
+	UsageTypeDetails *UsageType `db:"-" json:"usageTypeDetails,omitempty"`
+
 	// AdditionalProperties contain additional properties that further apply
 	// to the consumer
-	AdditionalProperties *Map `db:"additional_properties" json:"additionalProperties"`
+	AdditionalProperties *Map `db:"additional_properties" json:"additionalProperties" redact:"properties"`
 }
 
 // UnmarshalJSON customizes the way this struct is populated when reading
@@ -0,0 +1,23 @@
+package types
+
+import "time"
+
+// ConsumerExport contains everything that is stored about a single consumer.
+// It is used to answer requests for the data held about a consumer
+type ConsumerExport struct {
+	// ExportedAt contains the point in time the export has been created at
+	ExportedAt time.Time `json:"exportedAt"`
+
+	// Consumer contains the current representation of the consumer
+	Consumer Consumer `json:"consumer"`
+
+	// History contains every recorded version of the consumer
+	History []ConsumerVersion `json:"history"`
+
+	// UsageRecords contains the usage records of the consumer
+	UsageRecords []UsageRecord `json:"usageRecords"`
+
+	// AuditEntries contains the audit entries of the changes made to the
+	// consumer
+	AuditEntries []AuditEntry `json:"auditEntries"`
+}
@@ -0,0 +1,37 @@
+package types
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// ConsumerMerge contains the audit record of a merge of duplicate consumers
+// into a surviving consumer
+type ConsumerMerge struct {
+	// ID contains the identifier of the merge
+	ID uuid.UUID `db:"id" json:"id"`
+
+	// Survivor contains the identifier of the consumer the duplicates have
+	// been merged into
+	Survivor uuid.UUID `db:"survivor" json:"survivor"`
+
+	// Duplicates contains the identifiers of the consumers that have been
+	// merged into the survivor and have been deleted
+	Duplicates pq.StringArray `db:"duplicates" json:"duplicates"`
+
+	// Strategy contains the strategy used for combining the additional
+	// properties of the consumers
+	Strategy string `db:"strategy" json:"strategy"`
+
+	// ReassignedUsageRecords contains the number of usage records that have
+	// been reassigned to the survivor
+	ReassignedUsageRecords int64 `db:"reassigned_usage_records" json:"reassignedUsageRecords"`
+
+	// MergedBy contains the user that merged the consumers
+	MergedBy *string `db:"merged_by" json:"mergedBy"`
+
+	// MergedAt contains the point in time the consumers have been merged at
+	MergedAt time.Time `db:"merged_at" json:"mergedAt"`
+}
@@ -0,0 +1,29 @@
+package types
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OutboxEvent contains an event that has been written to the outbox in the
+// same transaction as the change that caused the event
+type OutboxEvent struct {
+	// ID contains the identifier of the event which is used as idempotency
+	// key by the receivers
+	ID uuid.UUID `db:"event_id" json:"id"`
+
+	// Type contains the type of the event (e.g., "consumer.created")
+	Type string `db:"event_type" json:"type"`
+
+	// Tenant contains the tenant the event belongs to
+	Tenant string `db:"tenant" json:"tenant,omitempty"`
+	// Consumer contains the identifier of the consumer the event belongs to
+	Consumer *uuid.UUID `db:"consumer" json:"consumer"`
+
+	// OccurredAt contains the point in time the event has been written at
+	OccurredAt time.Time `db:"created_at" json:"occurredAt"`
+
+	// Payload contains the representation of the changed resource
+	Payload *Map `db:"payload" json:"data"`
+}
@@ -0,0 +1,233 @@
+package types
+
+import (
+	"math"
+	"reflect"
+	"sync"
+
+	"github.com/paulmach/go.geojson"
+)
+
+// RedactedValue replaces the personal data of a consumer if it is redacted
+const RedactedValue = "[redacted]"
+
+// Redaction contains the policy that is applied to the responses sent to
+// users which are not allowed to see personal data
+type Redaction struct {
+	// GridSize contains the size of the grid the locations are snapped to in
+	// the unit of the coordinates. If the grid size is zero, the locations
+	// are not changed
+	GridSize float64
+
+	// SensitiveProperties contains the keys of the additional properties that
+	// are removed from the consumers
+	SensitiveProperties []string
+}
+
+// redactTag contains the name of the struct tag marking the fields which
+// contain personal data. The value of the tag selects how the field is
+// redacted:
+//   - mask: the value is replaced by the redacted value
+//   - location: the location is snapped to the grid
+//   - properties: the sensitive properties are removed
+//   - changes: the audited changes of the personal fields are masked
+//
+// Fields tagged with an unknown value are removed
+const redactTag = "redact"
+
+// personalFields contains the json names of the consumer fields containing
+// personal data. The audited changes of these fields are masked
+var personalFields = []string{"name", "address", "location", "additionalProperties"}
+
+// personalTypes caches whether a type contains fields marked as personal data
+var personalTypes sync.Map
+
+// Redact returns a copy of the supplied value with the personal data
+// redacted. The personal data is found using the redact tags of the struct
+// fields, which redacts every type containing personal data regardless of
+// where it is nested. Values without personal data are returned unchanged
+func (p Redaction) Redact(value interface{}) interface{} {
+	if value == nil {
+		return nil
+	}
+	return p.redactValue(reflect.ValueOf(value)).Interface()
+}
+
+// containsPersonalData checks if values of the type may contain fields
+// marked as personal data
+func containsPersonalData(t reflect.Type) bool {
+	if contained, checked := personalTypes.Load(t); checked {
+		return contained.(bool)
+	}
+	// recursive types are assumed to contain no personal data while they are
+	// being checked
+	personalTypes.Store(t, false)
+
+	var contained bool
+	switch t.Kind() {
+	case reflect.Pointer, reflect.Slice, reflect.Array, reflect.Map:
+		contained = containsPersonalData(t.Elem())
+	case reflect.Struct:
+		for idx := 0; idx < t.NumField() && !contained; idx++ {
+			field := t.Field(idx)
+			if !field.IsExported() {
+				continue
+			}
+			contained = field.Tag.Get(redactTag) != "" || containsPersonalData(field.Type)
+		}
+	}
+	personalTypes.Store(t, contained)
+	return contained
+}
+
+// redactValue returns a copy of the value with the personal data redacted.
+// Only the parts of the value containing personal data are copied
+func (p Redaction) redactValue(v reflect.Value) reflect.Value {
+	if !containsPersonalData(v.Type()) {
+		return v
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return v
+		}
+		redacted := reflect.New(v.Type().Elem())
+		redacted.Elem().Set(p.redactValue(v.Elem()))
+		return redacted
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		redacted := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for idx := 0; idx < v.Len(); idx++ {
+			redacted.Index(idx).Set(p.redactValue(v.Index(idx)))
+		}
+		return redacted
+	case reflect.Array:
+		redacted := reflect.New(v.Type()).Elem()
+		for idx := 0; idx < v.Len(); idx++ {
+			redacted.Index(idx).Set(p.redactValue(v.Index(idx)))
+		}
+		return redacted
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		redacted := reflect.MakeMapWithSize(v.Type(), v.Len())
+		entries := v.MapRange()
+		for entries.Next() {
+			redacted.SetMapIndex(entries.Key(), p.redactValue(entries.Value()))
+		}
+		return redacted
+	case reflect.Struct:
+		redacted := reflect.New(v.Type()).Elem()
+		redacted.Set(v)
+		for idx := 0; idx < v.NumField(); idx++ {
+			field := v.Type().Field(idx)
+			if !field.IsExported() {
+				continue
+			}
+			if redaction := field.Tag.Get(redactTag); redaction != "" {
+				redacted.Field(idx).Set(p.redactField(redaction, v.Field(idx)))
+				continue
+			}
+			redacted.Field(idx).Set(p.redactValue(v.Field(idx)))
+		}
+		return redacted
+	default:
+		return v
+	}
+}
+
+// redactField applies the redaction selected by the redact tag to the value
+// of a field. If the redaction does not support the type of the field, the
+// field is removed
+func (p Redaction) redactField(redaction string, v reflect.Value) reflect.Value {
+	var redacted interface{}
+	switch value := v.Interface().(type) {
+	case string:
+		if redaction == "mask" {
+			redacted = RedactedValue
+		}
+	case *string:
+		if redaction == "mask" {
+			redacted = p.maskString(value)
+		}
+	case *geojson.Geometry:
+		if redaction == "location" {
+			redacted = p.snapLocation(value)
+		}
+	case *Map:
+		switch redaction {
+		case "properties":
+			redacted = p.removeSensitiveProperties(value)
+		case "changes":
+			redacted = p.maskChanges(value)
+		}
+	}
+	if redacted == nil {
+		return reflect.Zero(v.Type())
+	}
+	return reflect.ValueOf(redacted)
+}
+
+// maskString replaces a set value with the redacted value
+func (p Redaction) maskString(value *string) *string {
+	if value == nil {
+		return nil
+	}
+	masked := RedactedValue
+	return &masked
+}
+
+// snapLocation returns a new point geometry snapped to the grid. Since only
+// points can be snapped without revealing their shape, other geometries are
+// removed
+func (p Redaction) snapLocation(location *geojson.Geometry) *geojson.Geometry {
+	if location == nil || p.GridSize <= 0 {
+		return location
+	}
+	if !location.IsPoint() {
+		return nil
+	}
+	coordinates := make([]float64, len(location.Point))
+	for idx, coordinate := range location.Point {
+		coordinates[idx] = math.Round(coordinate/p.GridSize) * p.GridSize
+	}
+	return geojson.NewPointGeometry(coordinates)
+}
+
+// removeSensitiveProperties returns a copy of the properties without the
+// sensitive properties
+func (p Redaction) removeSensitiveProperties(properties *Map) *Map {
+	if properties == nil || len(p.SensitiveProperties) == 0 {
+		return properties
+	}
+	redacted := make(Map, len(*properties))
+	for key, value := range *properties {
+		redacted[key] = value
+	}
+	for _, key := range p.SensitiveProperties {
+		delete(redacted, key)
+	}
+	return &redacted
+}
+
+// maskChanges returns a copy of the audited changes with the old and new
+// values of the personal fields masked
+func (p Redaction) maskChanges(changes *Map) *Map {
+	if changes == nil {
+		return nil
+	}
+	redacted := make(Map, len(*changes))
+	for field, change := range *changes {
+		redacted[field] = change
+	}
+	for _, field := range personalFields {
+		if _, changed := redacted[field]; changed {
+			redacted[field] = map[string]interface{}{"old": RedactedValue, "new": RedactedValue}
+		}
+	}
+	return &redacted
+}
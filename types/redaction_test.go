@@ -0,0 +1,168 @@
+package types
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/paulmach/go.geojson"
+)
+
+func stringPointer(value string) *string {
+	return &value
+}
+
+func testConsumer() Consumer {
+	return Consumer{
+		ID:                   uuid.MustParse("8c1b2a48-54c4-4c6c-9f55-2b4c2d1e3f4a"),
+		Name:                 "Jane Doe",
+		Description:          stringPointer("household"),
+		Address:              stringPointer("Main Street 1"),
+		Location:             geojson.NewPointGeometry([]float64{8.123456, 53.987654}),
+		AdditionalProperties: &Map{"phone": "0123", "meter": "A-1"},
+	}
+}
+
+func TestRedactionRedact(t *testing.T) {
+	// the grid size allows comparing the snapped coordinates exactly
+	redaction := Redaction{GridSize: 0.5, SensitiveProperties: []string{"phone"}}
+	redactedConsumer := Consumer{
+		ID:                   testConsumer().ID,
+		Name:                 RedactedValue,
+		Description:          stringPointer("household"),
+		Address:              stringPointer(RedactedValue),
+		Location:             geojson.NewPointGeometry([]float64{8, 54}),
+		AdditionalProperties: &Map{"meter": "A-1"},
+	}
+	consumer := testConsumer()
+
+	tests := []struct {
+		name     string
+		value    interface{}
+		expected interface{}
+	}{
+		{
+			name:     "consumer",
+			value:    testConsumer(),
+			expected: redactedConsumer,
+		},
+		{
+			name:     "pointer to consumer",
+			value:    &consumer,
+			expected: &redactedConsumer,
+		},
+		{
+			name:     "list of consumers",
+			value:    []Consumer{testConsumer(), {Name: "John Doe"}},
+			expected: []Consumer{redactedConsumer, {Name: RedactedValue}},
+		},
+		{
+			name:     "nested consumer",
+			value:    map[string][]Consumer{"duplicates": {testConsumer()}},
+			expected: map[string][]Consumer{"duplicates": {redactedConsumer}},
+		},
+		{
+			name:     "consumer without personal data",
+			value:    Consumer{ID: consumer.ID},
+			expected: Consumer{ID: consumer.ID, Name: RedactedValue},
+		},
+		{
+			name:     "usage totals",
+			value:    []ConsumerUsageTotal{{Consumer: consumer.ID, Name: "Jane Doe", Total: 5}},
+			expected: []ConsumerUsageTotal{{Consumer: consumer.ID, Name: RedactedValue, Total: 5}},
+		},
+		{
+			name: "audited changes",
+			value: AuditEntry{Action: "update-consumer", Changes: &Map{
+				"name":        map[string]interface{}{"old": "Jane Doe", "new": "Jane Roe"},
+				"description": map[string]interface{}{"old": nil, "new": "household"},
+			}},
+			expected: AuditEntry{Action: "update-consumer", Changes: &Map{
+				"name":        map[string]interface{}{"old": RedactedValue, "new": RedactedValue},
+				"description": map[string]interface{}{"old": nil, "new": "household"},
+			}},
+		},
+		{
+			name:     "value without personal data",
+			value:    UsageType{Name: "households"},
+			expected: UsageType{Name: "households"},
+		},
+		{
+			name:     "nil",
+			value:    nil,
+			expected: nil,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			redacted := redaction.Redact(test.value)
+			if !reflect.DeepEqual(redacted, test.expected) {
+				t.Fatalf("expected %#v, got %#v", test.expected, redacted)
+			}
+		})
+	}
+}
+
+func TestRedactionRedactKeepsOriginal(t *testing.T) {
+	consumer := testConsumer()
+	consumers := []*Consumer{&consumer}
+	Redaction{GridSize: 0.01, SensitiveProperties: []string{"phone"}}.Redact(consumers)
+
+	if !reflect.DeepEqual(consumer, testConsumer()) {
+		t.Fatalf("expected the original consumer to be unchanged, got %#v", consumer)
+	}
+}
+
+func TestRedactionRedactLocation(t *testing.T) {
+	polygon := geojson.NewPolygonGeometry([][][]float64{{{0, 0}, {1, 0}, {1, 1}, {0, 0}}})
+	point := geojson.NewPointGeometry([]float64{8.123456, 53.987654})
+
+	tests := []struct {
+		name      string
+		redaction Redaction
+		location  *geojson.Geometry
+		expected  *geojson.Geometry
+	}{
+		{name: "point snapped to the grid", redaction: Redaction{GridSize: 0.1}, location: point, expected: geojson.NewPointGeometry([]float64{8.1, 54})},
+		{name: "polygon removed", redaction: Redaction{GridSize: 0.1}, location: polygon, expected: nil},
+		{name: "grid disabled", redaction: Redaction{}, location: point, expected: point},
+		{name: "missing location", redaction: Redaction{GridSize: 0.1}, location: nil, expected: nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			redacted := test.redaction.Redact(Consumer{Location: test.location}).(Consumer)
+			if test.expected == nil {
+				if redacted.Location != nil {
+					t.Fatalf("expected no location, got %#v", redacted.Location)
+				}
+				return
+			}
+			if redacted.Location == nil || !redacted.Location.IsPoint() {
+				t.Fatalf("expected a point, got %#v", redacted.Location)
+			}
+			for idx, coordinate := range redacted.Location.Point {
+				if diff := coordinate - test.expected.Point[idx]; diff > 1e-9 || diff < -1e-9 {
+					t.Fatalf("expected %v, got %v", test.expected.Point, redacted.Location.Point)
+				}
+			}
+		})
+	}
+}
+
+func TestRedactionFailsClosed(t *testing.T) {
+	// fields with an unknown redaction or an unsupported type are removed
+	type unsupported struct {
+		Known    string  `redact:"mask"`
+		Unknown  string  `redact:"hash"`
+		Number   float64 `redact:"mask"`
+		Location string  `redact:"location"`
+	}
+
+	redacted := Redaction{}.Redact(unsupported{Known: "a", Unknown: "b", Number: 1, Location: "c"})
+	expected := unsupported{Known: RedactedValue}
+	if !reflect.DeepEqual(redacted, expected) {
+		t.Fatalf("expected %#v, got %#v", expected, redacted)
+	}
+}
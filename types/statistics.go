@@ -0,0 +1,61 @@
+package types
+
+import (
+	"github.com/google/uuid"
+)
+
+// ConsumerUsageTotal contains the total usage of a single consumer in the
+// time window used for calculating the statistics
+type ConsumerUsageTotal struct {
+	// Consumer contains the identifier of the consumer
+	Consumer uuid.UUID `db:"consumer" json:"consumer"`
+
+	// Name contains the name of the consumer
+	Name string `db:"name" json:"name" redact:"mask"`
+
+	// UsageType contains the usage type that the consumer has been assigned to
+	UsageType *uuid.UUID `db:"usage_type" json:"usageType"`
+
+	// Total contains the sum of all usages recorded for the consumer
+	Total float64 `db:"total" json:"total"`
+}
+
+// UsageTypeDistribution contains the number of consumers and their total
+// usage for a single usage type
+type UsageTypeDistribution struct {
+	// UsageType contains the usage type. consumers without a usage type are
+	// grouped using null
+	UsageType *uuid.UUID `db:"usage_type" json:"usageType"`
+
+	// Consumers contains the number of consumers assigned to the usage type
+	Consumers int `db:"consumers" json:"consumers"`
+
+	// Total contains the sum of all usages recorded for the consumers assigned
+	// to the usage type
+	Total float64 `db:"total" json:"total"`
+}
+
+// UsageStatistics contains the statistics about the usages of the consumers
+// in an area and time window
+type UsageStatistics struct {
+	// Consumers contains the number of consumers that have been included in
+	// the statistics
+	Consumers int `json:"consumers"`
+
+	// TotalUsage contains the sum of all usages recorded for the consumers
+	TotalUsage float64 `json:"totalUsage"`
+
+	// MeanUsage contains the mean of the total usages per consumer
+	MeanUsage *float64 `json:"meanUsage"`
+
+	// Percentiles contains the percentiles of the total usages per consumer
+	// keyed by the percentile (e.g., p50 for the median)
+	Percentiles map[string]float64 `json:"percentiles"`
+
+	// TopConsumers contains the consumers with the largest total usages in
+	// descending order
+	TopConsumers []ConsumerUsageTotal `json:"topConsumers"`
+
+	// UsageTypes contains the distribution of the consumers per usage type
+	UsageTypes []UsageTypeDistribution `json:"usageTypes"`
+}
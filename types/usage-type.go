@@ -0,0 +1,26 @@
+package types
+
+import (
+	"github.com/google/uuid"
+)
+
+// UsageType contains a usage type which may be assigned to consumers and
+// usage records
+type UsageType struct {
+	// ID contains the identifier of the usage type
+	ID uuid.UUID `db:"id" json:"id"`
+
+	// Name contains the human-readable name of the usage type
+	Name string `db:"name" json:"name"`
+
+	// Description contains an optional description of the usage type
+	Description *string `db:"description" json:"description"`
+
+	// ExternalCode contains an optional code of the usage type in an external
+	// classification (e.g., the German WZ 2008 or NACE)
+	ExternalCode *string `db:"external_code" json:"externalCode"`
+
+	// Parent contains the identifier of the parent usage type which allows
+	// building a hierarchy of usage types
+	Parent *uuid.UUID `db:"parent" json:"parent"`
+}
@@ -0,0 +1,75 @@
+package types
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UsageRecord contains a single meter reading that has been recorded for a
+// consumer
+type UsageRecord struct {
+	// ID contains the identifier of the usage record
+	ID uuid.UUID `db:"id" json:"id"`
+
+	// Consumer contains the identifier of the consumer the usage has been
+	// recorded for
+	Consumer uuid.UUID `db:"consumer" json:"consumer"`
+
+	// Municipality contains the key of the municipality the usage has been
+	// recorded in
+	Municipality *string `db:"municipality" json:"municipality"`
+
+	// Date contains the point in time the usage has been recorded at
+	Date time.Time `db:"date" json:"date"`
+
+	// UsageType contains the usage type that the recorded usage is
+	// attributed to
+	UsageType *uuid.UUID `db:"usage_type" json:"usageType"`
+
+	// Amount contains the amount of water that has been used
+	Amount float64 `db:"amount" json:"amount"`
+}
+
+// UsageAggregate contains the aggregated usages of a single time bucket
+type UsageAggregate struct {
+	// Bucket contains the start of the time bucket
+	Bucket time.Time `db:"bucket" json:"bucket"`
+
+	// Total contains the sum of all usages recorded in the time bucket
+	Total float64 `db:"total" json:"total"`
+
+	// Average contains the mean of the usages recorded in the time bucket.
+	// If no usages have been recorded in the time bucket, the average is null
+	Average *float64 `db:"average" json:"average"`
+
+	// Records contains the number of usage records in the time bucket
+	Records int `db:"records" json:"records"`
+}
+
+// UsageForecast contains the forecasted usages of a consumer or an area
+type UsageForecast struct {
+	// Method contains the forecasting method that has been used
+	Method string `json:"method"`
+
+	// Level contains the confidence level of the prediction intervals
+	Level float64 `json:"level"`
+
+	// Predictions contains the forecasted usages per month
+	Predictions []ForecastedUsage `json:"predictions"`
+}
+
+// ForecastedUsage contains the forecasted usage of a single month
+type ForecastedUsage struct {
+	// Period contains the start of the forecasted month
+	Period time.Time `json:"period"`
+
+	// Value contains the point forecast of the usage
+	Value float64 `json:"value"`
+
+	// Lower contains the lower bound of the prediction interval
+	Lower float64 `json:"lower"`
+
+	// Upper contains the upper bound of the prediction interval
+	Upper float64 `json:"upper"`
+}
@@ -0,0 +1,73 @@
+package types
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Webhook contains a subscription of an external system to the consumer
+// events
+type Webhook struct {
+	// ID contains the identifier of the webhook
+	ID uuid.UUID `db:"id" json:"id"`
+
+	// URL contains the url the events are sent to
+	URL string `db:"url" json:"url"`
+
+	// Secret contains the secret used for signing the deliveries. It is only
+	// returned while creating the webhook
+	Secret *string `db:"-" json:"secret,omitempty"`
+
+	// EventTypes contains the event types the webhook is subscribed to. If
+	// no event types are set, the webhook is subscribed to every event type
+	EventTypes pq.StringArray `db:"event_types" json:"eventTypes"`
+
+	// Active indicates if events are sent to the webhook
+	Active bool `db:"active" json:"active"`
+
+	// CreatedAt contains the point in time the webhook has been created at
+	CreatedAt time.Time `db:"created_at" json:"createdAt"`
+}
+
+// WebhookDelivery contains the delivery of a single event to a webhook
+type WebhookDelivery struct {
+	// ID contains the identifier of the delivery
+	ID uuid.UUID `db:"id" json:"id"`
+
+	// Webhook contains the identifier of the webhook the event is sent to
+	Webhook uuid.UUID `db:"webhook" json:"webhook"`
+
+	// EventID contains the identifier of the delivered event
+	EventID uuid.UUID `db:"event_id" json:"eventID"`
+
+	// EventType contains the type of the delivered event
+	EventType string `db:"event_type" json:"eventType"`
+
+	// Consumer contains the identifier of the consumer the event belongs to
+	Consumer *uuid.UUID `db:"consumer" json:"consumer"`
+
+	// Status contains the status of the delivery (either "pending",
+	// "delivered" or "dead")
+	Status string `db:"status" json:"status"`
+
+	// Attempts contains the number of delivery attempts
+	Attempts int `db:"attempts" json:"attempts"`
+
+	// NextAttemptAt contains the point in time of the next delivery attempt
+	NextAttemptAt time.Time `db:"next_attempt_at" json:"nextAttemptAt"`
+
+	// LastAttemptAt contains the point in time of the last delivery attempt
+	LastAttemptAt *time.Time `db:"last_attempt_at" json:"lastAttemptAt"`
+
+	// LastStatusCode contains the http status code returned by the webhook
+	// on the last delivery attempt
+	LastStatusCode *int `db:"last_status_code" json:"lastStatusCode"`
+
+	// LastError contains the error that occurred on the last delivery attempt
+	LastError *string `db:"last_error" json:"lastError"`
+
+	// CreatedAt contains the point in time the delivery has been created at
+	CreatedAt time.Time `db:"created_at" json:"createdAt"`
+}